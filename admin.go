@@ -0,0 +1,259 @@
+package log
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/weitrue/log/level"
+)
+
+// AdminHandler 日志管理接口，用于在运行时查看全局管理器中的 logger，以及调整日志等级，不需要重新部署服务。
+// 挂载时需要去掉路由前缀，比如：
+//  mux.Handle("/debug/log/", http.StripPrefix("/debug/log", log.NewAdminHandler()))
+//
+// 支持的接口：
+//  GET  /loggers            获取所有 logger 的 ID、名称、等级以及输出源
+//  GET  /loggers/{id}       获取指定 logger 的信息
+//  PUT  /loggers/{id}       调整日志等级，请求数据为 {"level":"DEBUG","ttl":"10m"}，
+//                           ttl 不为空时，到期后恢复为调整前的设置；ttl 为空时永久生效
+//  POST /loggers/{id}/sync  同步指定 logger
+//  POST /sync               同步全局 logger 以及全局管理器中的所有 logger
+//
+// 与 SetNamedLevel 相同，调整的是 logger 名称对应的等级，会覆盖之前为该名称设置的等级，
+// 并影响同名的 logger 以及通过 Named 创建的子 logger；ConfigWatcher 重新加载配置后仍然生效。
+// 没有名称的 logger 不能调整。返回的 level 为 logger 实际生效的等级。
+type AdminHandler struct {
+    mu sync.Mutex
+    // overrides 设置了 ttl 的临时调整，key 为 logger 名称
+    overrides map[string]*levelOverride
+}
+
+// levelOverride 临时调整的日志等级，到期后恢复为调整前通过 SetNamedLevel 设置的等级，没有设置时取消设置
+type levelOverride struct {
+    level       level.Level
+    previous    level.Level
+    hasPrevious bool
+    // original 调整前生效的等级
+    original  level.Level
+    expiresAt time.Time
+    timer     *time.Timer
+}
+
+// LoggerInfo AdminHandler 返回的 logger 信息
+type LoggerInfo struct {
+    ID      string   `json:"id"`
+    Name    string   `json:"name"`
+    Level   string   `json:"level,omitempty"`
+    Outputs []string `json:"outputs"`
+    // OriginalLevel 临时调整等级时，调整前生效的等级
+    OriginalLevel string `json:"originalLevel,omitempty"`
+    // ExpiresAt 临时调整等级时，恢复为 OriginalLevel 的时间
+    ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// NewAdminHandler 创建日志管理接口
+func NewAdminHandler() *AdminHandler {
+    return &AdminHandler{overrides: make(map[string]*levelOverride)}
+}
+
+type levelRequest struct {
+    Level string `json:"level"`
+    TTL   string `json:"ttl"`
+}
+
+type errorResponse struct {
+    Error string `json:"error"`
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+    switch {
+    case len(parts) == 1 && parts[0] == "sync":
+        if r.Method != http.MethodPost {
+            h.methodNotAllowed(w, http.MethodPost)
+            return
+        }
+        h.sync(w, SyncAll())
+    case len(parts) == 1 && parts[0] == "loggers":
+        if r.Method != http.MethodGet {
+            h.methodNotAllowed(w, http.MethodGet)
+            return
+        }
+        loggers := ListLoggers()
+        infos := make([]LoggerInfo, 0, len(loggers))
+        for _, l := range loggers {
+            infos = append(infos, h.info(l))
+        }
+        h.write(w, http.StatusOK, infos)
+    case len(parts) == 2 && parts[0] == "loggers":
+        l, ok := h.logger(w, parts[1])
+        if !ok {
+            return
+        }
+        switch r.Method {
+        case http.MethodGet:
+            h.write(w, http.StatusOK, h.info(l))
+        case http.MethodPut:
+            h.setLevel(w, r, l)
+        default:
+            h.methodNotAllowed(w, http.MethodGet+", "+http.MethodPut)
+        }
+    case len(parts) == 3 && parts[0] == "loggers" && parts[2] == "sync":
+        if r.Method != http.MethodPost {
+            h.methodNotAllowed(w, http.MethodPost)
+            return
+        }
+        l, ok := h.logger(w, parts[1])
+        if !ok {
+            return
+        }
+        h.sync(w, l.Sync())
+    default:
+        h.write(w, http.StatusNotFound, errorResponse{Error: "not found"})
+    }
+}
+
+func (h *AdminHandler) logger(w http.ResponseWriter, id string) (*Logger, bool) {
+    l, ok := GetLogger(id)
+    if !ok {
+        h.write(w, http.StatusNotFound, errorResponse{Error: fmt.Sprintf("logger %q not found", id)})
+    }
+    return l, ok
+}
+
+func (h *AdminHandler) info(l *Logger) LoggerInfo {
+    info := LoggerInfo{
+        ID:      l.ID,
+        Name:    l.Name,
+        Outputs: l.Outputs(),
+    }
+    if l.Level().IsZero() {
+        return info
+    }
+    info.Level = level.Level2CapitalName(effectiveLevel(l))
+
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    o, ok := h.overrides[l.Name]
+    if !ok {
+        return info
+    }
+    // 期间通过 SetNamedLevel 修改了设置时，到期后不会恢复，不再返回
+    if cur, ok := exactNamedLevel(l.Name); ok && cur == o.level {
+        expiresAt := o.expiresAt
+        info.OriginalLevel = level.Level2CapitalName(o.original)
+        info.ExpiresAt = &expiresAt
+    }
+    return info
+}
+
+// effectiveLevel 返回 logger 实际生效的等级，通过 SetNamedLevel 设置了等级时以设置为准，否则为 Config.Level
+func effectiveLevel(l *Logger) level.Level {
+    if lvl, ok := NamedLevel(l.Name); ok {
+        return lvl
+    }
+    return l.Level().Level()
+}
+
+func (h *AdminHandler) setLevel(w http.ResponseWriter, r *http.Request, l *Logger) {
+    var req levelRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        h.write(w, http.StatusBadRequest, errorResponse{Error: "decode request: " + err.Error()})
+        return
+    }
+    newLevel, ok := level.Name2Level(req.Level)
+    if !ok {
+        h.write(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("unknown level %q", req.Level)})
+        return
+    }
+    var ttl time.Duration
+    if req.TTL != "" {
+        var err error
+        ttl, err = time.ParseDuration(req.TTL)
+        if err != nil || ttl <= 0 {
+            h.write(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid ttl %q", req.TTL)})
+            return
+        }
+    }
+    if l.Level().IsZero() {
+        h.write(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("logger %q level is not adjustable", l.ID)})
+        return
+    }
+    if l.Name == "" {
+        h.write(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("logger %q has no name, level is not adjustable", l.ID)})
+        return
+    }
+
+    h.mu.Lock()
+    original := effectiveLevel(l)
+    var previous level.Level
+    var hasPrevious bool
+    updateNamedLevel(l.Name, func(cur level.Level, ok bool) (level.Level, bool) {
+        previous, hasPrevious = cur, ok
+        return newLevel, true
+    })
+    if o, ok := h.overrides[l.Name]; ok {
+        o.timer.Stop()
+        delete(h.overrides, l.Name)
+        // 连续临时调整时，到期后恢复为第一次调整前的设置
+        if hasPrevious && previous == o.level {
+            original, previous, hasPrevious = o.original, o.previous, o.hasPrevious
+        }
+    }
+    if ttl > 0 {
+        o := &levelOverride{
+            level:       newLevel,
+            previous:    previous,
+            hasPrevious: hasPrevious,
+            original:    original,
+            expiresAt:   time.Now().Add(ttl),
+        }
+        o.timer = time.AfterFunc(ttl, func() {
+            h.revert(l.Name, o)
+        })
+        h.overrides[l.Name] = o
+    }
+    h.mu.Unlock()
+
+    h.write(w, http.StatusOK, h.info(l))
+}
+
+// revert 临时调整到期，恢复调整前的设置
+func (h *AdminHandler) revert(name string, o *levelOverride) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    if h.overrides[name] != o {
+        return
+    }
+    delete(h.overrides, name)
+    updateNamedLevel(name, func(cur level.Level, ok bool) (level.Level, bool) {
+        if !ok || cur != o.level {
+            // 期间通过 SetNamedLevel 修改了设置，保留修改后的设置
+            return cur, ok
+        }
+        return o.previous, o.hasPrevious
+    })
+}
+
+func (h *AdminHandler) sync(w http.ResponseWriter, err error) {
+    if err != nil {
+        h.write(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) methodNotAllowed(w http.ResponseWriter, allow string) {
+    w.Header().Set("Allow", allow)
+    h.write(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+}
+
+func (h *AdminHandler) write(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    _ = json.NewEncoder(w).Encode(v)
+}
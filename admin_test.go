@@ -0,0 +1,266 @@
+package log
+
+import (
+    "bytes"
+    "encoding/json"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/weitrue/log/config"
+    "github.com/weitrue/log/level"
+    "github.com/weitrue/log/writer"
+)
+
+// newAdminTestLogger 创建并注册 ID 为 id、名称为 id-name 的 logger，测试结束后注销并取消该名称的等级设置
+func newAdminTestLogger(t *testing.T, id string, buf *bytes.Buffer) *Logger {
+    t.Helper()
+    l, err := New(config.Config{
+        ID:            id,
+        Name:          id + "-name",
+        ForceReplace:  true,
+        Level:         level.NewAtomicLevelAt(INFO),
+        Encoding:      "json",
+        EncoderConfig: config.EncoderConfig{MessageKey: "msg"},
+        Writer:        writer.AddSync(buf),
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() {
+        DeRegisterLogger(l)
+        UnsetNamedLevel(l.Name)
+    })
+    return l
+}
+
+func serveAdmin(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+    rec := httptest.NewRecorder()
+    h.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+    return rec
+}
+
+// decodeInfo 解析 AdminHandler 返回的 logger 信息
+func decodeInfo(t *testing.T, rec *httptest.ResponseRecorder) LoggerInfo {
+    t.Helper()
+    if rec.Code != http.StatusOK {
+        t.Fatalf("status %d, body %q", rec.Code, rec.Body.String())
+    }
+    var info LoggerInfo
+    if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+        t.Fatal(err)
+    }
+    return info
+}
+
+func TestAdminHandler(t *testing.T) {
+    var buf bytes.Buffer
+    l := newAdminTestLogger(t, "admin-a", &buf)
+    h := NewAdminHandler()
+
+    rec := serveAdmin(h, http.MethodGet, "/loggers", "")
+    var infos []LoggerInfo
+    if err := json.Unmarshal(rec.Body.Bytes(), &infos); err != nil {
+        t.Fatalf("list: %v, body %q", err, rec.Body.String())
+    }
+    found := false
+    for _, info := range infos {
+        if info.ID == "admin-a" {
+            found = info.Name == "admin-a-name" && info.Level == "INFO"
+        }
+    }
+    if !found {
+        t.Errorf("admin-a not listed correctly: %+v", infos)
+    }
+
+    if info := decodeInfo(t, serveAdmin(h, http.MethodGet, "/loggers/admin-a", "")); info.Level != "INFO" {
+        t.Errorf("get: level %q, want INFO", info.Level)
+    }
+
+    // 调整等级后立即生效，等级名称不区分大小写
+    info := decodeInfo(t, serveAdmin(h, http.MethodPut, "/loggers/admin-a", `{"level":"debug"}`))
+    if info.Level != "DEBUG" || info.ExpiresAt != nil {
+        t.Errorf("put: got %+v, want a permanent DEBUG level", info)
+    }
+    l.Debug("debug")
+    if !strings.Contains(buf.String(), `"msg":"debug"`) {
+        t.Errorf("level change not applied: %q", buf.String())
+    }
+
+    if rec = serveAdmin(h, http.MethodPost, "/loggers/admin-a/sync", ""); rec.Code != http.StatusNoContent {
+        t.Errorf("sync: status %d, body %q", rec.Code, rec.Body.String())
+    }
+}
+
+func TestAdminHandlerErrors(t *testing.T) {
+    newAdminTestLogger(t, "admin-b", &bytes.Buffer{})
+    noname, err := New(config.Config{
+        ID:           "admin-noname",
+        ForceReplace: true,
+        Level:        level.NewAtomicLevelAt(INFO),
+        Encoding:     "json",
+        Writer:       writer.AddSync(&bytes.Buffer{}),
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer DeRegisterLogger(noname)
+    h := NewAdminHandler()
+
+    tests := []struct {
+        method, path, body string
+        status             int
+        want               string
+    }{
+        {http.MethodGet, "/loggers/none", "", http.StatusNotFound, `logger \"none\" not found`},
+        {http.MethodPut, "/loggers/admin-b", `{"level":"LOUD"}`, http.StatusBadRequest, `unknown level \"LOUD\"`},
+        {http.MethodPut, "/loggers/admin-b", `{"level":"DEBUG","ttl":"soon"}`, http.StatusBadRequest, `invalid ttl \"soon\"`},
+        {http.MethodPut, "/loggers/admin-b", `{"level":"DEBUG","ttl":"-1s"}`, http.StatusBadRequest, "invalid ttl"},
+        {http.MethodPut, "/loggers/admin-b", `{`, http.StatusBadRequest, "decode request"},
+        {http.MethodDelete, "/loggers/admin-b", "", http.StatusMethodNotAllowed, "GET, PUT"},
+        {http.MethodGet, "/loggers/admin-b/sync", "", http.StatusMethodNotAllowed, "POST"},
+        {http.MethodGet, "/sync", "", http.StatusMethodNotAllowed, "POST"},
+        {http.MethodPost, "/loggers", "", http.StatusMethodNotAllowed, "GET"},
+        {http.MethodGet, "/levels", "", http.StatusNotFound, "not found"},
+        {http.MethodPut, "/loggers/admin-noname", `{"level":"DEBUG"}`, http.StatusBadRequest, `logger \"admin-noname\" has no name`},
+    }
+    for _, tt := range tests {
+        rec := serveAdmin(h, tt.method, tt.path, tt.body)
+        body := rec.Body.String()
+        if rec.Code == http.StatusMethodNotAllowed {
+            body = rec.Header().Get("Allow")
+        }
+        if rec.Code != tt.status || !strings.Contains(body, tt.want) {
+            t.Errorf("%s %s: status %d %q, want %d %q", tt.method, tt.path, rec.Code, body, tt.status, tt.want)
+        }
+    }
+    for _, name := range []string{"admin-b-name", ""} {
+        if lvl, ok := NamedLevel(name); ok {
+            t.Errorf("rejected requests set the level of %q to %v", name, lvl)
+        }
+    }
+}
+
+func TestAdminHandlerLevelTTL(t *testing.T) {
+    l := newAdminTestLogger(t, "admin-ttl", &bytes.Buffer{})
+    h := NewAdminHandler()
+
+    decodeInfo(t, serveAdmin(h, http.MethodPut, "/loggers/admin-ttl", `{"level":"DEBUG","ttl":"1h"}`))
+    // 连续临时调整时，恢复为第一次调整前的等级
+    info := decodeInfo(t, serveAdmin(h, http.MethodPut, "/loggers/admin-ttl", `{"level":"WARN","ttl":"50ms"}`))
+    if info.Level != "WARN" || info.OriginalLevel != "INFO" || info.ExpiresAt == nil {
+        t.Errorf("got %+v, want level WARN, original INFO and an expiry", info)
+    }
+
+    waitLevel(t, l, INFO)
+    if _, ok := NamedLevel(l.Name); ok {
+        t.Error("expired override should unset the named level")
+    }
+    if info = decodeInfo(t, serveAdmin(h, http.MethodGet, "/loggers/admin-ttl", "")); info.OriginalLevel != "" {
+        t.Errorf("expired override still reported: %+v", info)
+    }
+
+    // 永久调整会取消临时调整
+    serveAdmin(h, http.MethodPut, "/loggers/admin-ttl", `{"level":"DEBUG","ttl":"50ms"}`)
+    serveAdmin(h, http.MethodPut, "/loggers/admin-ttl", `{"level":"ERROR"}`)
+    time.Sleep(100 * time.Millisecond)
+    if got := effectiveLevel(l); got != ERROR {
+        t.Errorf("level %v, want ERROR", got)
+    }
+}
+
+// waitLevel 等待 logger 生效的等级恢复为 want
+func waitLevel(t *testing.T, l *Logger, want level.Level) {
+    t.Helper()
+    deadline := time.Now().Add(2 * time.Second)
+    for effectiveLevel(l) != want && time.Now().Before(deadline) {
+        time.Sleep(10 * time.Millisecond)
+    }
+    if got := effectiveLevel(l); got != want {
+        t.Fatalf("level %v after ttl, want %v", got, want)
+    }
+}
+
+func TestAdminHandlerNamedLevel(t *testing.T) {
+    var buf bytes.Buffer
+    l := newAdminTestLogger(t, "admin-named", &buf)
+    SetNamedLevel(l.Name, WARN)
+    h := NewAdminHandler()
+
+    if info := decodeInfo(t, serveAdmin(h, http.MethodGet, "/loggers/admin-named", "")); info.Level != "WARN" {
+        t.Errorf("get: level %q, want the named level WARN", info.Level)
+    }
+    // 通过 SetNamedLevel 设置了等级时，调整同样生效，子 logger 也会输出
+    info := decodeInfo(t, serveAdmin(h, http.MethodPut, "/loggers/admin-named", `{"level":"DEBUG","ttl":"50ms"}`))
+    if info.Level != "DEBUG" || info.OriginalLevel != "WARN" {
+        t.Errorf("put: got %+v, want level DEBUG and original WARN", info)
+    }
+    l.Named("child").Debug("child debug")
+    if !strings.Contains(buf.String(), `"msg":"child debug"`) {
+        t.Errorf("override not applied to the named logger: %q", buf.String())
+    }
+
+    // 到期后恢复为原来的设置
+    waitLevel(t, l, WARN)
+    if lvl, ok := NamedLevel(l.Name); !ok || lvl != WARN {
+        t.Errorf("named level %v %v after ttl, want WARN", lvl, ok)
+    }
+
+    // 期间通过 SetNamedLevel 修改的设置不会被覆盖
+    serveAdmin(h, http.MethodPut, "/loggers/admin-named", `{"level":"DEBUG","ttl":"50ms"}`)
+    SetNamedLevel(l.Name, ERROR)
+    time.Sleep(100 * time.Millisecond)
+    if lvl, _ := NamedLevel(l.Name); lvl != ERROR {
+        t.Errorf("named level %v after ttl, want ERROR set during the override", lvl)
+    }
+}
+
+func TestAdminHandlerReload(t *testing.T) {
+    dir := t.TempDir()
+    cfgPath, logPath := filepath.Join(dir, "log.yaml"), filepath.Join(dir, "a.log")
+    writeLevelConfig := func(lvl string) {
+        cfg := "id: admin-reload\nname: admin-reload\nlevel: " + lvl + "\nencoderConfig:\n  messageKey: msg\noutputs:\n  - type: file\n    path: " + logPath + "\n"
+        if err := ioutil.WriteFile(cfgPath, []byte(cfg), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    writeLevelConfig("info")
+    w, err := NewConfigWatcher(cfgPath)
+    if err != nil {
+        t.Fatal(err)
+    }
+    w.DrainDelay = 0
+    defer UnsetNamedLevel("admin-reload")
+    defer DeRegisterLogger(w.Logger())
+    defer w.Logger().Close()
+    h := NewAdminHandler()
+
+    decodeInfo(t, serveAdmin(h, http.MethodPut, "/loggers/admin-reload", `{"level":"DEBUG","ttl":"200ms"}`))
+    writeLevelConfig("warn")
+    if _, err = w.Reload(); err != nil {
+        t.Fatal(err)
+    }
+
+    // 重新加载之后调整仍然生效
+    l, _ := GetLogger("admin-reload")
+    if info := decodeInfo(t, serveAdmin(h, http.MethodGet, "/loggers/admin-reload", "")); info.Level != "DEBUG" || info.ExpiresAt == nil {
+        t.Errorf("get after reload: got %+v, want a temporary DEBUG level", info)
+    }
+    l.Debug("debug after reload")
+    _ = l.Sync()
+    if !strings.Contains(readFile(t, logPath), `"msg":"debug after reload"`) {
+        t.Errorf("override lost after reload: %q", readFile(t, logPath))
+    }
+
+    // 到期后使用新配置的等级，而不是重新加载之前的等级
+    waitLevel(t, l, WARN)
+    l.Info("info after ttl")
+    _ = l.Sync()
+    if strings.Contains(readFile(t, logPath), "info after ttl") {
+        t.Errorf("reverted to a stale level: %q", readFile(t, logPath))
+    }
+}
@@ -65,6 +65,30 @@ func NewProductionWithESConfig(writers ...writer.WriteSyncer) config.Config {
 
     return cfg
 }
+// NewProductionECSConfig 针对 ES/kibana 使用 Elastic Common Schema（ECS）格式输出日志，
+// 编码器为 "ecs"，编码器配置参考 encoder.NewECSEncoderConfig，
+// serviceName 不为空时，每条日志都会记录 service.name 字段
+func NewProductionECSConfig(serviceName string, writers ...writer.WriteSyncer) config.Config {
+    cfg := config.Config{
+        Level:         level.NewAtomicLevelAt(INFO),
+        Development:   false,
+        EnableCaller:  true,
+        Encoding:      encoder.EcsEncoding,
+        EncoderConfig: encoder.NewECSEncoderConfig(),
+    }
+    if serviceName != "" {
+        cfg.InitialFields = map[string]interface{}{
+            "service.name": serviceName,
+        }
+    }
+
+    if len(writers) > 0 {
+        cfg.Writer = writer.NewMultiWriteSyncer(writers...)
+    }
+
+    return cfg
+}
+
 // NewProductionConfig is a reasonable production logging configuration.
 // Logging is enabled at InfoLevel and above.
 //
@@ -1,6 +1,9 @@
 package config
 
 import (
+    "time"
+
+    "github.com/weitrue/log/entry"
     "github.com/weitrue/log/level"
     "github.com/weitrue/log/writer"
     "go.uber.org/zap/zapcore"
@@ -27,9 +30,10 @@ type Config struct {
     Level level.AtomicLevel `json:"level" yaml:"level"`
 
     // CustomLevelEnabler 自定义 LevelEnabler 函数，用于判断允许什么等级的日志进行输出。
-    // 默认使用 Level 对象的 Enabled 函数（大于等于该级别才允许输出），如果设置该字段函数，则替换使用。
+    // 默认使用 Level 对象的 Enabled 函数（大于等于该级别才允许输出），如果设置该字段函数，
+    // 则日志等级需要同时满足 Level 和该函数才允许输出，比如只允许 FIXED 以及 ERROR 以上的日志。
     // 且在Level进行重置时，该判断函数不会被覆盖。
-    // CustomLevelEnabler level.LevelEnablerFunc
+    CustomLevelEnabler level.LevelEnablerFunc `json:"-" yaml:"-"`
     // Development 调整 log 为开发模式，主要调整 异常栈捕获流程和 Critical 的行为。
     // 当设置为 true 时， Critical 会触发 panic 操作
     Development bool `json:"development" yaml:"development"`
@@ -41,11 +45,10 @@ type Config struct {
     // 在 production 场景中则是捕获 ErrorLevel 级别以上的堆栈信息。
     EnableStacktrace bool `json:"enableStacktrace" yaml:"enableStacktrace"`
 
-    // Sampling 统计重复日志，并优化.
-    // 关闭该功能,TODO: 后续移植过来
-    // Sampling *SamplingConfig `json:"sampling" yaml:"sampling"`
+    // Sampling 统计重复日志，并进行采样，为 nil 时不进行采样.
+    Sampling *SamplingConfig `json:"sampling" yaml:"sampling"`
 
-    // Encoding 设置日志编码器. 默认设置有 "json" 和 "console",
+    // Encoding 设置日志编码器. 默认设置有 "json"、"console"、"logfmt"、"ecs" 和 "gelf",
     // 通过 RegisterEncoder 设置自定义编码器.
     Encoding string `json:"encoding" yaml:"encoding"`
     // EncoderConfig sets options for the chosen encoder. See
@@ -58,6 +61,38 @@ type Config struct {
 
     // Writer 自定义 writer，用于日志数据输出，可以实现多数据通道数据
     Writer writer.WriteSyncer
+
+    // Sinks 按日志等级区分的输出源，每个输出源可以单独设置等级范围、编码器和 writer，
+    // 与 Writer 一起合并为一个 core，Writer 为空时只输出到 Sinks 中。
+    Sinks []Sink `json:"-" yaml:"-"`
 }
 
+// Sink 日志输出源，用于将不同等级的日志输出到不同的地方，
+// 比如 DEBUG 到 INFO 的日志以 console 格式输出到 stdout，ERROR 以上的日志以 json 格式输出到 syslog。
+type Sink struct {
+    // Level 该输出源的最低允许记录等级，未设置时使用 Config.Level，
+    // 单独设置时不受 log.SetNamedLevel 的影响
+    Level level.AtomicLevel
+    // MaxLevel 该输出源的最高允许记录等级，未设置时不限制
+    MaxLevel level.AtomicLevel
+    // Encoding 该输出源使用的编码器，为空时使用 Config.Encoding
+    Encoding string
+    // EncoderConfig 该输出源使用的编码器配置，为空时使用 Config.EncoderConfig
+    EncoderConfig *EncoderConfig
+    // Writer 日志数据输出
+    Writer writer.WriteSyncer
+}
 
+// SamplingConfig 日志采样配置，用于限制短时间内大量重复日志的输出数量。
+// 每个 Tick 时间内，相同等级和消息的日志，先输出 Initial 条，之后每 Thereafter 条输出一条。
+type SamplingConfig struct {
+    Initial    int `json:"initial" yaml:"initial"`
+    Thereafter int `json:"thereafter" yaml:"thereafter"`
+    // Tick 采样统计周期，默认为 1 秒
+    Tick time.Duration `json:"tick" yaml:"tick"`
+    // ExemptLevels 不参与采样的日志等级，为 nil 时默认为 DefaultSamplingExemptLevels（CRITICAL、PANIC、FATAL 和 FIXED），
+    // 设置为空切片时，所有等级都参与采样
+    ExemptLevels []level.Level `json:"exemptLevels" yaml:"exemptLevels"`
+    // Hook 日志被采样丢弃时调用，dropped 为累计丢弃的日志数量
+    Hook func(ent entry.Entry, dropped uint64) `json:"-" yaml:"-"`
+}
@@ -0,0 +1,100 @@
+package log
+
+import (
+    "context"
+    "sync"
+    "sync/atomic"
+
+    "github.com/weitrue/log/field"
+)
+
+// ContextExtractor 从 context 中提取日志字段，比如 trace id、user id 等，
+// 没有需要记录的数据时返回 nil
+type ContextExtractor func(ctx context.Context) []field.Field
+
+var (
+    // contextExtractors 已注册的 []ContextExtractor，注册时复制后替换，读取时不需要加锁
+    contextExtractors atomic.Value
+    extractorsMu      sync.Mutex
+)
+
+// RegisterContextExtractor 注册 context 字段提取函数，Logger.Ctx 会按注册顺序调用所有提取函数。
+// 一般在程序初始化时注册。
+func RegisterContextExtractor(extractors ...ContextExtractor) {
+    extractorsMu.Lock()
+    defer extractorsMu.Unlock()
+
+    old, _ := contextExtractors.Load().([]ContextExtractor)
+    newExtractors := make([]ContextExtractor, 0, len(old)+len(extractors))
+    newExtractors = append(newExtractors, old...)
+    for _, e := range extractors {
+        if e != nil {
+            newExtractors = append(newExtractors, e)
+        }
+    }
+    contextExtractors.Store(newExtractors)
+}
+
+// ContextValueExtractor 创建一个提取函数，将 ctx.Value(key) 的值记录到 fieldName 字段中，值为 nil 时不记录
+func ContextValueExtractor(key interface{}, fieldName string) ContextExtractor {
+    return func(ctx context.Context) []field.Field {
+        v := ctx.Value(key)
+        if v == nil {
+            return nil
+        }
+        return []field.Field{Any(fieldName, v)}
+    }
+}
+
+// ContextFields 调用所有已注册的提取函数，返回 ctx 中需要记录的日志字段
+func ContextFields(ctx context.Context) []field.Field {
+    if ctx == nil {
+        return nil
+    }
+    extractors, _ := contextExtractors.Load().([]ContextExtractor)
+    var fields []field.Field
+    for _, e := range extractors {
+        fields = append(fields, e(ctx)...)
+    }
+    return fields
+}
+
+// Ctx 返回附加了 ctx 中日志字段的子 logger，没有需要记录的字段时返回 l 本身
+//  logger.Ctx(ctx).Info("request done", log.Int("status", 200))
+func (l *Logger) Ctx(ctx context.Context) *Logger {
+    return l.With(ContextFields(ctx)...)
+}
+
+// Ctx 返回附加了 ctx 中日志字段的子 logger，参考 Logger.Ctx
+func (l *WLogger) Ctx(ctx context.Context) *WLogger {
+    fields := ContextFields(ctx)
+    if len(fields) == 0 {
+        return l
+    }
+    return &WLogger{base: l.base.With(fields...), Level: l.Level}
+}
+
+type loggerContextKey struct{}
+
+// NewContext 返回携带 logger 的 context，通过 FromContext 获取
+func NewContext(ctx context.Context, l *Logger) context.Context {
+    return context.WithValue(ctx, loggerContextKey{}, l)
+}
+
+// FromContext 获取通过 NewContext 保存的 logger，不存在时返回全局默认 logger。
+// 返回的 logger 没有附加 ctx 中的日志字段，需要时调用 Ctx：
+//  log.FromContext(ctx).Ctx(ctx).Info("request done")
+func FromContext(ctx context.Context) *Logger {
+    if ctx != nil {
+        if l, ok := ctx.Value(loggerContextKey{}).(*Logger); ok && l != nil {
+            return l
+        }
+    }
+    if l, ok := getGlobalLog().(*Logger); ok {
+        return l
+    }
+    if l, ok := GetLogger(DefaultName); ok {
+        return l
+    }
+    return NewNop()
+}
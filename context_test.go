@@ -0,0 +1,83 @@
+package log
+
+import (
+    "bytes"
+    "context"
+    "strings"
+    "testing"
+
+    "github.com/weitrue/log/config"
+    "github.com/weitrue/log/field"
+    "github.com/weitrue/log/level"
+    "github.com/weitrue/log/writer"
+)
+
+// newBufferLogger 创建输出到 buf 的 json logger，只输出日志消息和字段
+func newBufferLogger(t *testing.T, buf *bytes.Buffer) *Logger {
+    t.Helper()
+    l, err := New(config.Config{
+        Level:         level.NewAtomicLevelAt(DEBUG),
+        Encoding:      "json",
+        EncoderConfig: config.EncoderConfig{MessageKey: "msg"},
+        Writer:        writer.AddSync(buf),
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    return l
+}
+
+type traceKey struct{}
+
+type userKey struct{}
+
+func TestLoggerCtx(t *testing.T) {
+    RegisterContextExtractor(
+        ContextValueExtractor(traceKey{}, "trace"),
+        nil,
+        func(ctx context.Context) []field.Field {
+            if u, ok := ctx.Value(userKey{}).(string); ok {
+                return []field.Field{String("user", u), Bool("login", true)}
+            }
+            return nil
+        },
+    )
+
+    var buf bytes.Buffer
+    l := newBufferLogger(t, &buf)
+
+    ctx := context.WithValue(context.Background(), traceKey{}, "t-1")
+    ctx = context.WithValue(ctx, userKey{}, "bob")
+    l.Ctx(ctx).Info("done", Int("status", 200))
+    l.Switch().Ctx(ctx).Infof("done %d", 2)
+    want := `{"msg":"done","trace":"t-1","user":"bob","login":true,"status":200}` + "\n" +
+        `{"msg":"done 2","trace":"t-1","user":"bob","login":true}` + "\n"
+    if buf.String() != want {
+        t.Errorf("got %q, want %q", buf.String(), want)
+    }
+
+    // 没有需要记录的字段时返回原来的 logger
+    if l.Ctx(context.Background()) != l {
+        t.Error("Ctx without fields should return the logger itself")
+    }
+    if fs := ContextFields(nil); fs != nil {
+        t.Errorf("ContextFields(nil) = %v, want nil", fs)
+    }
+    wl := l.Switch()
+    if wl.Ctx(context.Background()) != wl {
+        t.Error("WLogger.Ctx without fields should return the logger itself")
+    }
+}
+
+func TestFromContext(t *testing.T) {
+    var buf bytes.Buffer
+    l := newBufferLogger(t, &buf)
+    ctx := NewContext(context.Background(), l)
+    FromContext(ctx).Info("from ctx")
+    if !strings.Contains(buf.String(), `"msg":"from ctx"`) {
+        t.Errorf("logger stored by NewContext not returned: %q", buf.String())
+    }
+    if FromContext(context.Background()) == nil {
+        t.Error("FromContext should fall back to a default logger")
+    }
+}
@@ -8,13 +8,34 @@ import (
 )
 
 // NewCore creates a Core that writes logs to a WriteSyncer.
-func NewCore(enc encoder.Encoder, ws writer.WriteSyncer, enab level.LevelEnabler) Core {
+// ws 实现了 writer.LevelWriteSyncer 时，通过 WriteLevel 写入日志。
+// customs 为自定义的等级判断函数，日志等级需要同时满足 enab 和所有 customs 才允许输出，
+// 因此 enab 为 AtomicLevel 时，调用 SetLevel 调整等级后，customs 依旧生效。
+func NewCore(enc encoder.Encoder, ws writer.WriteSyncer, enab level.LevelEnabler, customs ...level.LevelEnablerFunc) Core {
     if enc == nil || ws == nil{
         return nil
     }
+    for _, custom := range customs {
+        if custom != nil {
+            enab = customEnabler{LevelEnabler: enab, custom: custom}
+        }
+    }
+    if lws, ok := ws.(writer.LevelWriteSyncer); ok {
+        return &levelWriterCore{LevelEnabler: enab, enc: enc, out: lws}
+    }
     return zapcore.NewCore(enc,ws,enab)
 }
 
+// customEnabler 组合 LevelEnabler 和自定义的等级判断函数
+type customEnabler struct {
+    level.LevelEnabler
+    custom level.LevelEnablerFunc
+}
+
+func (e customEnabler) Enabled(lvl level.Level) bool {
+    return e.LevelEnabler.Enabled(lvl) && e.custom(lvl)
+}
+
 var NewNopCore = zapcore.NewNopCore
 var NewTee = zapcore.NewTee
 type Core = zapcore.Core
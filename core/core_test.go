@@ -0,0 +1,56 @@
+package core
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/weitrue/log/config"
+    "github.com/weitrue/log/encoder"
+    "github.com/weitrue/log/level"
+    "github.com/weitrue/log/writer"
+)
+
+func TestNewCoreCustomEnabler(t *testing.T) {
+    var buf bytes.Buffer
+    lvl := level.NewAtomicLevelAt(level.InfoLevel)
+    // 只允许 FIXED 以及 ERROR 以上的日志，nil 函数会被忽略
+    custom := func(l level.Level) bool { return l == level.FixedLevel || l >= level.ErrorLevel }
+    enc := encoder.NewJSONEncoder(config.EncoderConfig{MessageKey: "msg", LevelKey: "level", EncodeLevel: encoder.CapitalLevelEncoder})
+    c := NewCore(enc, writer.AddSync(&buf), lvl, nil, custom)
+
+    logAll := func() {
+        for _, l := range []level.Level{level.DebugLevel, level.InfoLevel, level.WarnLevel, level.ErrorLevel, level.CriticalLevel, level.FixedLevel} {
+            write(c, l, "m", time.Now())
+        }
+    }
+    logAll()
+    if got := strings.Count(buf.String(), "\n"); got != 3 {
+        t.Errorf("got %d entries, want ERROR, CRITICAL and FIXED: %q", got, buf.String())
+    }
+    if strings.Contains(buf.String(), "INFO") {
+        t.Errorf("custom enabler not applied: %q", buf.String())
+    }
+
+    // 调整 AtomicLevel 之后自定义函数依旧生效
+    buf.Reset()
+    lvl.SetLevel(level.CriticalLevel)
+    logAll()
+    if got := strings.Count(buf.String(), "\n"); got != 2 {
+        t.Errorf("got %d entries after SetLevel, want CRITICAL and FIXED: %q", got, buf.String())
+    }
+    if strings.Contains(buf.String(), "ERROR") {
+        t.Errorf("level change not applied: %q", buf.String())
+    }
+}
+
+func TestNewCoreNilArguments(t *testing.T) {
+    enc := encoder.NewJSONEncoder(config.EncoderConfig{MessageKey: "msg"})
+    if c := NewCore(nil, writer.AddSync(&bytes.Buffer{}), level.InfoLevel); c != nil {
+        t.Error("NewCore without an encoder should return nil")
+    }
+    if c := NewCore(enc, nil, level.InfoLevel); c != nil {
+        t.Error("NewCore without a writer should return nil")
+    }
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+    "errors"
+    "strings"
+
+    "github.com/weitrue/log/entry"
+    "github.com/weitrue/log/field"
+    "go.uber.org/multierr"
+)
+
+// Hook 日志写入前调用，ent 为即将写入的日志数据，可以直接修改，比如改写消息内容；
+// fields 为本次调用时传入的字段（不包括通过 With 附加的字段）。
+// 返回需要写入的字段，可以在 fields 的基础上追加；返回 false 时丢弃该条日志，后续的 Hook 也不再调用。
+// 也可以只用于统计 CRITICAL 日志数量、发送告警等操作，此时原样返回 fields 和 true 即可。
+type Hook func(ent *entry.Entry, fields []field.Field) ([]field.Field, bool)
+
+type hookCore struct {
+    Core
+    hooks []Hook
+}
+
+// NewHookCore 创建一个在写入前依次调用 hooks 的 Core，hooks 在 CheckedEntry.Write 时调用，
+// 被 Logger.Check 过滤掉的日志不会调用 hooks。
+func NewHookCore(c Core, hooks ...Hook) Core {
+    if len(hooks) == 0 {
+        return c
+    }
+    if hc, ok := c.(*hookCore); ok {
+        // 合并多次设置的 hooks，保持调用顺序
+        merged := make([]Hook, 0, len(hc.hooks)+len(hooks))
+        merged = append(merged, hc.hooks...)
+        merged = append(merged, hooks...)
+        return &hookCore{Core: hc.Core, hooks: merged}
+    }
+    return &hookCore{Core: c, hooks: hooks}
+}
+
+func (h *hookCore) With(fields []field.Field) Core {
+    return &hookCore{
+        Core:  h.Core.With(fields),
+        hooks: h.hooks,
+    }
+}
+
+func (h *hookCore) Check(ent entry.Entry, ce *CheckedEntry) *CheckedEntry {
+    if h.Enabled(ent.Level) {
+        return ce.AddCore(ent, h)
+    }
+    return ce
+}
+
+func (h *hookCore) Write(ent entry.Entry, fields []field.Field) error {
+    for _, hook := range h.hooks {
+        var ok bool
+        if fields, ok = hook(&ent, fields); !ok {
+            return nil
+        }
+    }
+    // hooks 可能修改了日志等级，需要由内部 core 重新判断
+    ce := h.Core.Check(ent, nil)
+    if ce == nil {
+        return nil
+    }
+    return WriteCheckedEntry(ce, fields...)
+}
+
+// WriteCheckedEntry 写入 ce，CheckedEntry.Write 本身不返回错误，只输出到 ErrorOutput，
+// 这里收集输出的错误信息并作为 error 返回，用于在 Core.Write 中再次 Check 内部 core 的情况。
+func WriteCheckedEntry(ce *CheckedEntry, fields ...field.Field) error {
+    recorder := &errorRecorder{}
+    ce.ErrorOutput = recorder
+    ce.Write(fields...)
+    return recorder.err
+}
+
+// errorRecorder 记录 CheckedEntry.Write 输出的错误信息，并转换为 error 返回
+type errorRecorder struct {
+    err error
+}
+
+func (r *errorRecorder) Write(p []byte) (int, error) {
+    r.err = multierr.Append(r.err, errors.New(strings.TrimSpace(string(p))))
+    return len(p), nil
+}
+
+func (r *errorRecorder) Sync() error {
+    return nil
+}
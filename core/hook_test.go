@@ -0,0 +1,79 @@
+package core
+
+import (
+    "bytes"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/weitrue/log/config"
+    "github.com/weitrue/log/encoder"
+    "github.com/weitrue/log/entry"
+    "github.com/weitrue/log/field"
+    "github.com/weitrue/log/level"
+)
+
+// failWriter 写入总是失败
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+func (failWriter) Sync() error { return nil }
+
+func TestHookCore(t *testing.T) {
+    var buf bytes.Buffer
+    var calls []string
+    redact := func(ent *entry.Entry, fields []field.Field) ([]field.Field, bool) {
+        calls = append(calls, "redact")
+        ent.Message = strings.Replace(ent.Message, "secret", "***", -1)
+        return append(fields, field.String("redacted", "yes")), true
+    }
+    dropHealth := func(ent *entry.Entry, fields []field.Field) ([]field.Field, bool) {
+        calls = append(calls, "drop")
+        return fields, ent.Message != "health"
+    }
+    // 多次设置的 hooks 按顺序合并
+    c := NewHookCore(NewHookCore(newBufferCore(&buf, level.InfoLevel), dropHealth), redact)
+    c = c.With([]field.Field{field.String("k", "v")})
+
+    write(c, level.InfoLevel, "health", time.Now())
+    write(c, level.InfoLevel, "token secret", time.Now())
+    write(c, level.DebugLevel, "debug", time.Now())
+
+    if got, want := buf.String(), `{"msg":"token ***","k":"v","redacted":"yes"}`+"\n"; got != want {
+        t.Errorf("got %q, want %q", got, want)
+    }
+    // 被丢弃的日志不再调用后续 hook，被等级过滤的日志不调用 hook
+    if got, want := strings.Join(calls, ","), "drop,drop,redact"; got != want {
+        t.Errorf("hooks called %s, want %s", got, want)
+    }
+}
+
+func TestHookCoreChangeLevel(t *testing.T) {
+    var buf bytes.Buffer
+    demote := func(ent *entry.Entry, fields []field.Field) ([]field.Field, bool) {
+        ent.Level = level.DebugLevel
+        return fields, true
+    }
+    c := NewHookCore(newBufferCore(&buf, level.InfoLevel), demote)
+    write(c, level.ErrorLevel, "demoted", time.Now())
+    if buf.Len() != 0 {
+        t.Errorf("entry demoted below the core level should be dropped, got %q", buf.String())
+    }
+}
+
+func TestHookCoreWriteError(t *testing.T) {
+    noop := func(ent *entry.Entry, fields []field.Field) ([]field.Field, bool) { return fields, true }
+    enc := encoder.NewJSONEncoder(config.EncoderConfig{MessageKey: "msg"})
+    c := NewHookCore(NewCore(enc, failWriter{}, level.InfoLevel), noop)
+    err := c.Write(entry.Entry{Level: level.InfoLevel, Message: "m", Time: time.Now()}, nil)
+    if err == nil || !strings.Contains(err.Error(), "disk full") {
+        t.Errorf("Write() error = %v, want the writer error", err)
+    }
+
+    inner := newBufferCore(&bytes.Buffer{}, level.InfoLevel)
+    if NewHookCore(inner) != inner {
+        t.Error("NewHookCore without hooks should return the core itself")
+    }
+}
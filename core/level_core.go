@@ -0,0 +1,59 @@
+package core
+
+import (
+    "github.com/weitrue/log/encoder"
+    "github.com/weitrue/log/entry"
+    "github.com/weitrue/log/field"
+    "github.com/weitrue/log/level"
+    "github.com/weitrue/log/writer"
+)
+
+// levelWriterCore 与 zapcore.NewCore 创建的 core 相同，只是通过 WriteLevel 写入日志并传入日志等级和时间，
+// 使 writer 可以根据每条日志的等级和时间进行处理，比如 syslog 的 severity 和 RFC 5424 的 TIMESTAMP。
+type levelWriterCore struct {
+    level.LevelEnabler
+    enc encoder.Encoder
+    out writer.LevelWriteSyncer
+}
+
+func (c *levelWriterCore) With(fields []field.Field) Core {
+    clone := c.clone()
+    encoder.AddFields(clone.enc, fields)
+    return clone
+}
+
+func (c *levelWriterCore) Check(ent entry.Entry, ce *CheckedEntry) *CheckedEntry {
+    if c.Enabled(ent.Level) {
+        return ce.AddCore(ent, c)
+    }
+    return ce
+}
+
+func (c *levelWriterCore) Write(ent entry.Entry, fields []field.Field) error {
+    buf, err := c.enc.EncodeEntry(ent, fields)
+    if err != nil {
+        return err
+    }
+    _, err = c.out.WriteLevel(ent.Level, ent.Time, buf.Bytes())
+    buf.Free()
+    if err != nil {
+        return err
+    }
+    if ent.Level > level.ErrorLevel {
+        // 与 zapcore 一致，ERROR 以上的日志需要立即同步，避免程序退出时丢失
+        _ = c.Sync()
+    }
+    return nil
+}
+
+func (c *levelWriterCore) Sync() error {
+    return c.out.Sync()
+}
+
+func (c *levelWriterCore) clone() *levelWriterCore {
+    return &levelWriterCore{
+        LevelEnabler: c.LevelEnabler,
+        enc:          c.enc.Clone(),
+        out:          c.out,
+    }
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+    "bytes"
+    "testing"
+    "time"
+
+    "github.com/weitrue/log/config"
+    "github.com/weitrue/log/encoder"
+    "github.com/weitrue/log/field"
+    "github.com/weitrue/log/level"
+    "github.com/weitrue/log/writer"
+)
+
+// levelRecorder 记录通过 WriteLevel 写入的日志等级和时间
+type levelRecorder struct {
+    bytes.Buffer
+    levels []level.Level
+    times  []time.Time
+    syncs  int
+}
+
+func (r *levelRecorder) WriteLevel(lvl level.Level, t time.Time, p []byte) (int, error) {
+    r.levels = append(r.levels, lvl)
+    r.times = append(r.times, t)
+    return r.Write(p)
+}
+
+func (r *levelRecorder) Sync() error {
+    r.syncs++
+    return nil
+}
+
+func TestLevelWriterCore(t *testing.T) {
+    rec := &levelRecorder{}
+    enc := encoder.NewJSONEncoder(config.EncoderConfig{MessageKey: "msg"})
+    c := NewCore(enc, rec, level.InfoLevel).With([]field.Field{field.String("k", "v")})
+
+    write(c, level.DebugLevel, "debug", time.Now())
+    write(c, level.InfoLevel, "info", time.Now())
+    write(c, level.ErrorLevel, "error", time.Now())
+    if got, want := rec.String(), `{"msg":"info","k":"v"}`+"\n"+`{"msg":"error","k":"v"}`+"\n"; got != want {
+        t.Errorf("got %q, want %q", got, want)
+    }
+    if len(rec.levels) != 2 || rec.levels[0] != level.InfoLevel || rec.levels[1] != level.ErrorLevel {
+        t.Errorf("WriteLevel called with %v, want [info error]", rec.levels)
+    }
+    if rec.syncs != 0 {
+        t.Errorf("synced %d times, want no sync up to ERROR", rec.syncs)
+    }
+
+    // 与 zapcore 一致，ERROR 以上的日志立即同步
+    write(c, level.CriticalLevel, "critical", time.Now())
+    if rec.syncs != 1 {
+        t.Errorf("synced %d times after CRITICAL, want 1", rec.syncs)
+    }
+}
+
+func TestLevelWriterCoreMultiWriter(t *testing.T) {
+    rec := &levelRecorder{}
+    var plain bytes.Buffer
+    enc := encoder.NewJSONEncoder(config.EncoderConfig{MessageKey: "msg"})
+    c := NewCore(enc, writer.NewMultiWriteSyncer(rec, writer.AddSync(&plain)), level.InfoLevel)
+
+    ts := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+    write(c, level.WarnLevel, "warn", ts)
+    if len(rec.levels) != 1 || rec.levels[0] != level.WarnLevel || !rec.times[0].Equal(ts) {
+        t.Errorf("multi writer should pass the level and entry time through, got %v %v", rec.levels, rec.times)
+    }
+    if plain.String() != rec.String() {
+        t.Errorf("plain writer got %q, want %q", plain.String(), rec.String())
+    }
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+    "time"
+
+    "github.com/weitrue/log/entry"
+    "github.com/weitrue/log/field"
+    "github.com/weitrue/log/level"
+    "go.uber.org/atomic"
+)
+
+/*
+根据 zapcore 中的 sampler 进行改造，主要调整：
+  1、支持自定义的 FIXED 等日志等级；
+  2、支持设置不参与采样的日志等级；
+  3、日志被丢弃时，通过 SamplerHook 通知丢弃数量。
+*/
+
+const (
+    _numLevels        = level.FixedLevel - level.DebugLevel + 1
+    _countersPerLevel = 4096
+)
+
+type counter struct {
+    resetAt atomic.Int64
+    counter atomic.Uint64
+}
+
+type counters [_numLevels][_countersPerLevel]counter
+
+func newCounters() *counters {
+    return &counters{}
+}
+
+func (cs *counters) get(lvl level.Level, key string) *counter {
+    i := lvl - level.DebugLevel
+    j := fnv32a(key) % _countersPerLevel
+    return &cs[i][j]
+}
+
+// fnv32a, adapted from "hash/fnv", but without a []byte(string) alloc
+func fnv32a(s string) uint32 {
+    const (
+        offset32 = 2166136261
+        prime32  = 16777619
+    )
+    hash := uint32(offset32)
+    for i := 0; i < len(s); i++ {
+        hash ^= uint32(s[i])
+        hash *= prime32
+    }
+    return hash
+}
+
+func (c *counter) IncCheckReset(t time.Time, tick time.Duration) uint64 {
+    tn := t.UnixNano()
+    resetAfter := c.resetAt.Load()
+    if resetAfter > tn {
+        return c.counter.Inc()
+    }
+
+    c.counter.Store(1)
+
+    newResetAfter := tn + tick.Nanoseconds()
+    if !c.resetAt.CAS(resetAfter, newResetAfter) {
+        // We raced with another goroutine trying to reset, and it also reset
+        // the counter to 1, so we need to reincrement the counter.
+        return c.counter.Inc()
+    }
+
+    return 1
+}
+
+// SamplerHook 日志被采样丢弃时调用，dropped 为该采样器（包括 With 复制出来的对象）累计丢弃的日志数量
+type SamplerHook func(ent entry.Entry, dropped uint64)
+
+type sampler struct {
+    Core
+
+    counts            *counters
+    tick              time.Duration
+    first, thereafter uint64
+    exempt            level.LevelEnabler
+    hook              SamplerHook
+    dropped           *atomic.Uint64
+}
+
+// NewSampler 创建一个采样 Core，用于限制短时间内相同日志的输出数量，避免日志洪峰压垮输出源。
+// 每个 tick 时间内，相同等级和消息的日志，先输出 first 条，之后每 thereafter 条输出一条，
+// thereafter 小于等于 0 时，超过 first 条的日志全部丢弃。
+// exempt 不为空时，其允许的日志等级不参与采样；hook 不为空时，每丢弃一条日志调用一次。
+//
+// 相同消息的判断基于 hash，hash 冲突时不同消息可能会被一起采样。
+func NewSampler(c Core, tick time.Duration, first, thereafter int, exempt level.LevelEnabler, hook SamplerHook) Core {
+    if thereafter < 0 {
+        thereafter = 0
+    }
+    return &sampler{
+        Core:       c,
+        tick:       tick,
+        counts:     newCounters(),
+        first:      uint64(first),
+        thereafter: uint64(thereafter),
+        exempt:     exempt,
+        hook:       hook,
+        dropped:    atomic.NewUint64(0),
+    }
+}
+
+func (s *sampler) With(fields []field.Field) Core {
+    return &sampler{
+        Core:       s.Core.With(fields),
+        tick:       s.tick,
+        counts:     s.counts,
+        first:      s.first,
+        thereafter: s.thereafter,
+        exempt:     s.exempt,
+        hook:       s.hook,
+        dropped:    s.dropped,
+    }
+}
+
+func (s *sampler) Check(ent entry.Entry, ce *CheckedEntry) *CheckedEntry {
+    if !s.Enabled(ent.Level) {
+        return ce
+    }
+    if s.exempt != nil && s.exempt.Enabled(ent.Level) {
+        return s.Core.Check(ent, ce)
+    }
+    // 超出范围的自定义等级不参与采样
+    if ent.Level < level.DebugLevel || ent.Level > level.FixedLevel {
+        return s.Core.Check(ent, ce)
+    }
+
+    counter := s.counts.get(ent.Level, ent.Message)
+    n := counter.IncCheckReset(ent.Time, s.tick)
+    if n > s.first && (s.thereafter == 0 || (n-s.first)%s.thereafter != 0) {
+        dropped := s.dropped.Inc()
+        if s.hook != nil {
+            s.hook(ent, dropped)
+        }
+        return ce
+    }
+    return s.Core.Check(ent, ce)
+}
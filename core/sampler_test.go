@@ -0,0 +1,105 @@
+package core
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/weitrue/log/config"
+    "github.com/weitrue/log/encoder"
+    "github.com/weitrue/log/entry"
+    "github.com/weitrue/log/field"
+    "github.com/weitrue/log/level"
+    "github.com/weitrue/log/writer"
+)
+
+// newBufferCore 创建一个输出到 buf 的 json Core，只输出日志消息
+func newBufferCore(buf *bytes.Buffer, enab level.LevelEnabler) Core {
+    return NewCore(encoder.NewJSONEncoder(config.EncoderConfig{MessageKey: "msg"}), writer.AddSync(buf), enab)
+}
+
+// write 模拟 Logger 写入一条日志
+func write(c Core, lvl level.Level, msg string, t time.Time) {
+    ent := entry.Entry{Level: lvl, Message: msg, Time: t}
+    if ce := c.Check(ent, nil); ce != nil {
+        ce.Write()
+    }
+}
+
+func TestSampler(t *testing.T) {
+    var buf bytes.Buffer
+    var drops []uint64
+    s := NewSampler(newBufferCore(&buf, level.DebugLevel), time.Minute, 2, 3, nil, func(ent entry.Entry, dropped uint64) {
+        drops = append(drops, dropped)
+    })
+
+    now := time.Now()
+    for i := 0; i < 10; i++ {
+        write(s, level.InfoLevel, "a", now)
+    }
+    // 不同的消息和等级分开计数
+    write(s, level.InfoLevel, "b", now)
+    write(s, level.WarnLevel, "a", now)
+
+    // 先输出 2 条，之后每 3 条输出一条：第 1、2、5、8 条
+    if n := strings.Count(buf.String(), `"msg":"a"`); n != 5 {
+        t.Errorf("got %d entries of a, want 4 info and 1 warn: %q", n, buf.String())
+    }
+    if n := strings.Count(buf.String(), `"msg":"b"`); n != 1 {
+        t.Errorf("got %d entries of b, want 1", n)
+    }
+    if len(drops) != 6 || drops[5] != 6 {
+        t.Errorf("hook called with %v, want the running count of 6 drops", drops)
+    }
+
+    // tick 结束后重新计数
+    buf.Reset()
+    write(s, level.InfoLevel, "a", now.Add(time.Minute))
+    write(s, level.InfoLevel, "a", now.Add(time.Minute))
+    if n := strings.Count(buf.String(), `"msg":"a"`); n != 2 {
+        t.Errorf("got %d entries after the tick, want 2", n)
+    }
+}
+
+func TestSamplerWithSharesCounters(t *testing.T) {
+    var buf bytes.Buffer
+    var dropped uint64
+    s := NewSampler(newBufferCore(&buf, level.DebugLevel), time.Minute, 1, 0, nil, func(ent entry.Entry, n uint64) {
+        dropped = n
+    })
+    child := s.With([]field.Field{field.String("k", "v")})
+
+    now := time.Now()
+    write(s, level.InfoLevel, "a", now)
+    write(child, level.InfoLevel, "a", now)
+    write(child, level.InfoLevel, "a", now)
+    write(s, level.InfoLevel, "a", now)
+
+    // thereafter 为 0 时，超过 first 的日志全部丢弃
+    if n := strings.Count(buf.String(), `"msg":"a"`); n != 1 {
+        t.Errorf("got %d entries, want 1: %q", n, buf.String())
+    }
+    if dropped != 3 {
+        t.Errorf("dropped %d, want 3 counted across With", dropped)
+    }
+}
+
+func TestSamplerExempt(t *testing.T) {
+    var buf bytes.Buffer
+    exempt := level.LevelEnablerFunc(func(l level.Level) bool { return l >= level.ErrorLevel })
+    s := NewSampler(newBufferCore(&buf, level.InfoLevel), time.Minute, 1, 0, exempt, nil)
+
+    now := time.Now()
+    for i := 0; i < 5; i++ {
+        write(s, level.ErrorLevel, "e", now)
+        write(s, level.FixedLevel, "f", now)
+        write(s, level.InfoLevel, "i", now)
+        write(s, level.DebugLevel, "d", now)
+    }
+    for msg, want := range map[string]int{"e": 5, "f": 5, "i": 1, "d": 0} {
+        if n := strings.Count(buf.String(), `"msg":"`+msg+`"`); n != want {
+            t.Errorf("got %d entries of %s, want %d", n, msg, want)
+        }
+    }
+}
@@ -0,0 +1,164 @@
+package encoder
+
+import (
+	"github.com/weitrue/log/config"
+	"github.com/weitrue/log/entry"
+	"github.com/weitrue/log/field"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+/*
+EcsEncoder 按 Elastic Common Schema（ECS）格式输出 json 日志，ES/kibana 可以直接识别，不需要额外的 ingest pipeline：
+  1、caller 拆分为 CallerKey + ".file.name" 和 CallerKey + ".file.line" 两个字段，比如 log.origin.file.name、log.origin.file.line；
+  2、堆栈信息（包括使用 STACK_TYPE_INT 标记的 stack 字段）输出到 StacktraceKey 中，比如 error.stack_trace，
+     ES 会将其与 error.message 一起作为错误详情展示，stack 字段本身不再作为普通字段输出；
+  3、顶层 key 为 "error" 的字段（一般为 field.Error，包括通过 With 附加的字段）输出到 error.message 中，
+     避免 error 同时作为字符串和 error.stack_trace 的父对象，导致 ES mapping 冲突；
+  4、每条日志都会输出 ecs.version。
+其余字段与 JsonEncoder 一致，key 使用 EncoderConfig 中的设置，一般与 NewECSEncoderConfig 一起使用。
+*/
+
+const (
+	EcsEncoding = "ecs"
+	// EcsVersion 输出的 ecs.version
+	EcsVersion = "1.6.0"
+
+	_ecsVersionKey   = "ecs.version"
+	_ecsErrorKey     = "error"
+	_ecsErrorMessage = "error.message"
+)
+
+type EcsEncoder struct {
+	*JsonEncoder
+}
+
+// NewECSEncoder 创建 ECS 格式的 json 编码器
+func NewECSEncoder(cfg config.EncoderConfig) Encoder {
+	return EcsEncoder{newJSONEncoder(cfg, false)}
+}
+
+// NewECSEncoderConfig 返回 ECS 格式对应的编码器配置，
+// 时间使用 RFC3339 格式（毫秒），日志等级使用小写字符串，caller 只保留包名和文件名
+func NewECSEncoderConfig() config.EncoderConfig {
+	return config.EncoderConfig{
+		TimeKey:        "@timestamp",
+		LevelKey:       "log.level",
+		NameKey:        "log.logger",
+		CallerKey:      "log.origin",
+		MessageKey:     "message",
+		StacktraceKey:  "error.stack_trace",
+		LineEnding:     DefaultLineEnding,
+		EncodeLevel:    LowercaseLevelEncoder,
+		EncodeTime:     RFC3339TimeEncoder,
+		EncodeDuration: zapcore.NanosDurationEncoder,
+		EncodeCaller:   ShortCallerEncoder,
+	}
+}
+
+func (e EcsEncoder) Clone() Encoder {
+	return EcsEncoder{e.JsonEncoder.Clone().(*JsonEncoder)}
+}
+
+// AddString field.Error 通过 AddString 输出错误信息，顶层的 "error" 改为 error.message
+func (e EcsEncoder) AddString(key, val string) {
+	e.JsonEncoder.AddString(e.key(key), val)
+}
+
+func (e EcsEncoder) AddReflected(key string, obj interface{}) error {
+	return e.JsonEncoder.AddReflected(e.key(key), obj)
+}
+
+func (e EcsEncoder) key(key string) string {
+	if key == _ecsErrorKey && e.openNamespaces == 0 {
+		return _ecsErrorMessage
+	}
+	return key
+}
+
+func (e EcsEncoder) EncodeEntry(ent entry.Entry, fields []field.Field) (*buffer.Buffer, error) {
+	final := e.clone()
+	final.Buf.AppendByte('{')
+
+	if final.TimeKey != "" {
+		final.AddTime(final.TimeKey, ent.Time)
+	}
+	if final.LevelKey != "" {
+		final.addKey(final.LevelKey)
+		cur := final.Buf.Len()
+		final.EncodeLevel(ent.Level, final)
+		if cur == final.Buf.Len() {
+			final.AppendString(ent.Level.String())
+		}
+	}
+	if ent.LoggerName != "" && final.NameKey != "" {
+		final.addKey(final.NameKey)
+		cur := final.Buf.Len()
+		nameEncoder := final.EncodeName
+		if nameEncoder == nil {
+			nameEncoder = FullNameEncoder
+		}
+		nameEncoder(ent.LoggerName, final)
+		if cur == final.Buf.Len() {
+			final.AppendString(ent.LoggerName)
+		}
+	}
+	if ent.Caller.Defined && final.CallerKey != "" {
+		// EncodeCaller 的结果为 file:line 格式，这里只使用其中的 file 部分，line 单独输出为数字
+		final.addKey(final.CallerKey + ".file.name")
+		cur := final.Buf.Len()
+		if final.EncodeCaller != nil {
+			final.EncodeCaller(ent.Caller, ecsCallerFile{final})
+		}
+		if cur == final.Buf.Len() {
+			final.AppendString(ent.Caller.File)
+		}
+		final.AddInt(final.CallerKey+".file.line", ent.Caller.Line)
+	}
+	if final.MessageKey != "" {
+		final.addKey(final.MessageKey)
+		final.AppendString(ent.Message)
+	}
+	final.AddString(_ecsVersionKey, EcsVersion)
+	if e.Buf.Len() > 0 {
+		final.addElementSeparator()
+		final.Buf.Write(e.Buf.Bytes())
+	}
+	for _, f := range fields {
+		if f.Integer == field.STACK_TYPE_INT && f.Type == zapcore.StringType {
+			ent.Stack = f.String
+		} else {
+			f.AddTo(EcsEncoder{final})
+		}
+	}
+	final.closeOpenNamespaces()
+	if ent.Stack != "" && final.StacktraceKey != "" {
+		final.AddString(final.StacktraceKey, ent.Stack)
+	}
+	final.Buf.AppendByte('}')
+	if final.LineEnding != "" {
+		final.Buf.AppendString(final.LineEnding)
+	} else {
+		final.Buf.AppendString(DefaultLineEnding)
+	}
+
+	ret := final.Buf
+	putJSONEncoder(final)
+	return ret, nil
+}
+
+// ecsCallerFile 去掉 EncodeCaller 输出末尾的 ":行号"，只保留文件部分
+type ecsCallerFile struct {
+	*JsonEncoder
+}
+
+func (c ecsCallerFile) AppendString(s string) {
+	i := len(s) - 1
+	for i >= 0 && s[i] >= '0' && s[i] <= '9' {
+		i--
+	}
+	if i >= 0 && i < len(s)-1 && s[i] == ':' {
+		s = s[:i]
+	}
+	c.JsonEncoder.AppendString(s)
+}
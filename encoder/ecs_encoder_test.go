@@ -0,0 +1,63 @@
+package encoder
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weitrue/log/entry"
+	"github.com/weitrue/log/field"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestEcsErrorField(t *testing.T) {
+	boom := errors.New("boom")
+	tests := []struct {
+		name   string
+		with   []field.Field
+		fields []field.Field
+		want   string
+	}{
+		{"field", nil, []field.Field{field.Error(boom)}, `"error.message":"boom"`},
+		{"with", []field.Field{field.Error(boom)}, nil, `"error.message":"boom"`},
+		{"string key", []field.Field{field.String("error", "boom")}, nil, `"error.message":"boom"`},
+		{"named error", nil, []field.Field{field.NamedError("cause", boom)}, `"cause":"boom"`},
+		{"namespace", []field.Field{field.Namespace("req"), field.Error(boom)}, nil, `"req":{"error":"boom"}`},
+		{"namespace field", []field.Field{field.Namespace("req")}, []field.Field{field.Error(boom)}, `"req":{"error":"boom"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := NewECSEncoder(NewECSEncoderConfig())
+			if len(tt.with) > 0 {
+				enc = enc.Clone()
+				AddFields(enc, tt.with)
+			}
+			buf, err := enc.EncodeEntry(entry.Entry{Time: time.Unix(0, 0), Message: "failed"}, tt.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := buf.String()
+			buf.Free()
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if strings.Contains(tt.want, "error.message") && strings.Contains(got, `"error":`) {
+				t.Errorf("error should only be written as error.message: %s", got)
+			}
+		})
+	}
+}
+
+func TestEcsStack(t *testing.T) {
+	enc := NewECSEncoder(NewECSEncoderConfig())
+	stack := field.Field{Key: "stack", Type: zapcore.StringType, Integer: field.STACK_TYPE_INT, String: "main.go:1"}
+	buf, err := enc.EncodeEntry(entry.Entry{Time: time.Unix(0, 0), Message: "failed"}, []field.Field{stack})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buf.Free()
+	if got := buf.String(); !strings.HasSuffix(got, `"error.stack_trace":"main.go:1"}`+"\n") || strings.Contains(got, `"stack"`) {
+		t.Errorf("unexpected stack output %s", got)
+	}
+}
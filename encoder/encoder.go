@@ -27,14 +27,23 @@ var (
         JsonEncoding: func(encoderConfig config.EncoderConfig) (Encoder, error) {
             return NewJSONEncoder(encoderConfig), nil
         },
+        LogfmtEncoding: func(encoderConfig config.EncoderConfig) (Encoder, error) {
+            return NewLogfmtEncoder(encoderConfig), nil
+        },
+        EcsEncoding: func(encoderConfig config.EncoderConfig) (Encoder, error) {
+            return NewECSEncoder(encoderConfig), nil
+        },
+        GelfEncoding: func(encoderConfig config.EncoderConfig) (Encoder, error) {
+            return NewGelfEncoder(encoderConfig), nil
+        },
     }
     _encoderMutex sync.RWMutex
 
 )
 
 // RegisterEncoder registers an encoder constructor, which the Config struct
-// can then reference. By default, the "json" and "console" encoders are
-// registered.
+// can then reference. By default, the "json", "console", "logfmt", "ecs" and "gelf"
+// encoders are registered.
 //
 // Attempting to register an encoder whose name is already taken returns an
 // error.
@@ -0,0 +1,270 @@
+package encoder
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/weitrue/log/config"
+	"github.com/weitrue/log/entry"
+	"github.com/weitrue/log/field"
+	"github.com/weitrue/log/level"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+/*
+GELF 1.1 格式的编码器，用于将日志发送到 Graylog，一般与 writer/gelf 一起使用，输出格式为：
+  {"version":"1.1","host":"web-1","short_message":"request done","timestamp":1550814085.123,"level":6,"_level":"INFO","_log":"api","_caller":"api/main.go:12","_req.method":"GET"}
+
+  1、日志消息输出到 short_message，堆栈信息输出到 full_message，日志等级转换为 syslog 的 severity 输出到 level；
+  2、其余字段都作为附加字段，key 增加 "_" 前缀，LevelKey、NameKey、CallerKey 为空时不输出对应的附加字段；
+  3、GELF 的附加字段只支持字符串和数字，嵌套的对象以及 Namespace 展开为以 "." 连接的 key，数组以及通过反射编码的数据序列化为 json 字符串；
+  4、堆栈信息（包括使用 STACK_TYPE_INT 标记的 stack 字段）只在 StacktraceKey 不为空时输出到 full_message，
+     StacktraceKey 只用于开关，不作为附加字段的 key。
+*/
+
+const (
+	GelfEncoding = "gelf"
+	GelfVersion  = "1.1"
+)
+
+var _gelfPool = sync.Pool{New: func() interface{} {
+	return &GelfEncoder{}
+}}
+
+func getGelfEncoder() *GelfEncoder {
+	return _gelfPool.Get().(*GelfEncoder)
+}
+
+func putGelfEncoder(enc *GelfEncoder) {
+	enc.JsonEncoder = nil
+	enc.host = ""
+	enc.prefix = ""
+	_gelfPool.Put(enc)
+}
+
+// GelfEncoder 将日志数据编码为 GELF 1.1 格式的 json 数据
+type GelfEncoder struct {
+	*JsonEncoder
+	host string
+	// prefix 当前字段 key 的前缀，由外层对象的 key 以及通过 OpenNamespace 打开的命名空间组成
+	prefix string
+}
+
+// NewGelfEncoder 创建 GELF 编码器，host 使用 os.Hostname 获取的主机名
+func NewGelfEncoder(cfg config.EncoderConfig) Encoder {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return &GelfEncoder{JsonEncoder: newJSONEncoder(cfg, false), host: host}
+}
+
+// GelfLevel 将日志等级转换为 syslog 的 severity：
+// DEBUG 为 7，INFO 为 6，WARN 为 4，ERROR 为 3，CRITICAL 为 2，PANIC、FIXED 为 1，FATAL 为 0
+func GelfLevel(l level.Level) int {
+	switch l {
+	case level.DebugLevel:
+		return 7
+	case level.InfoLevel:
+		return 6
+	case level.WarnLevel:
+		return 4
+	case level.ErrorLevel:
+		return 3
+	case level.CriticalLevel:
+		return 2
+	case level.PanicLevel, level.FixedLevel:
+		return 1
+	case level.FatalLevel:
+		return 0
+	}
+	if l < level.DebugLevel {
+		return 7
+	}
+	return 1
+}
+
+// gelfKey 生成附加字段的 key，GELF 的 key 只允许字母、数字、下划线、"-" 和 "."，其他字符替换为 "_"，
+// 同时 "_id" 为保留字段，替换为 "__id"
+func (enc *GelfEncoder) gelfKey(key string) string {
+	key = enc.prefix + key
+	b := make([]byte, 0, len(key)+1)
+	b = append(b, '_')
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '-', c == '.':
+			b = append(b, c)
+		default:
+			b = append(b, '_')
+		}
+	}
+	if string(b) == "_id" {
+		return "__id"
+	}
+	return string(b)
+}
+
+func (enc *GelfEncoder) AddArray(key string, arr ArrayMarshaler) error {
+	tmp := newJSONEncoder(*enc.EncoderConfig, false)
+	defer tmp.Buf.Free()
+	err := tmp.AppendArray(arr)
+	enc.JsonEncoder.AddString(enc.gelfKey(key), tmp.Buf.String())
+	return err
+}
+
+func (enc *GelfEncoder) AddObject(key string, obj ObjectMarshaler) error {
+	old := enc.prefix
+	enc.prefix = old + key + "."
+	err := obj.MarshalLogObject(enc)
+	enc.prefix = old
+	return err
+}
+
+func (enc *GelfEncoder) AddReflected(key string, obj interface{}) error {
+	enc.resetReflectBuf()
+	if err := enc.reflectEnc.Encode(obj); err != nil {
+		return err
+	}
+	enc.reflectBuf.TrimNewline()
+	enc.JsonEncoder.AddString(enc.gelfKey(key), enc.reflectBuf.String())
+	return nil
+}
+
+func (enc *GelfEncoder) OpenNamespace(key string) {
+	enc.prefix += key + "."
+}
+
+func (enc *GelfEncoder) AddBinary(k string, v []byte) { enc.JsonEncoder.AddBinary(enc.gelfKey(k), v) }
+func (enc *GelfEncoder) AddByteString(k string, v []byte) {
+	enc.JsonEncoder.AddByteString(enc.gelfKey(k), v)
+}
+func (enc *GelfEncoder) AddBool(k string, v bool) { enc.JsonEncoder.AddBool(enc.gelfKey(k), v) }
+func (enc *GelfEncoder) AddComplex128(k string, v complex128) {
+	enc.JsonEncoder.AddComplex128(enc.gelfKey(k), v)
+}
+func (enc *GelfEncoder) AddComplex64(k string, v complex64) {
+	enc.JsonEncoder.AddComplex128(enc.gelfKey(k), complex128(v))
+}
+func (enc *GelfEncoder) AddDuration(k string, v time.Duration) {
+	enc.JsonEncoder.AddDuration(enc.gelfKey(k), v)
+}
+func (enc *GelfEncoder) AddFloat64(k string, v float64) {
+	enc.JsonEncoder.AddFloat64(enc.gelfKey(k), v)
+}
+func (enc *GelfEncoder) AddFloat32(k string, v float32) {
+	enc.JsonEncoder.AddFloat32(enc.gelfKey(k), v)
+}
+func (enc *GelfEncoder) AddInt(k string, v int)     { enc.JsonEncoder.AddInt64(enc.gelfKey(k), int64(v)) }
+func (enc *GelfEncoder) AddInt64(k string, v int64) { enc.JsonEncoder.AddInt64(enc.gelfKey(k), v) }
+func (enc *GelfEncoder) AddInt32(k string, v int32) {
+	enc.JsonEncoder.AddInt64(enc.gelfKey(k), int64(v))
+}
+func (enc *GelfEncoder) AddInt16(k string, v int16) {
+	enc.JsonEncoder.AddInt64(enc.gelfKey(k), int64(v))
+}
+func (enc *GelfEncoder) AddInt8(k string, v int8)      { enc.JsonEncoder.AddInt64(enc.gelfKey(k), int64(v)) }
+func (enc *GelfEncoder) AddString(k, v string)         { enc.JsonEncoder.AddString(enc.gelfKey(k), v) }
+func (enc *GelfEncoder) AddTime(k string, v time.Time) { enc.JsonEncoder.AddTime(enc.gelfKey(k), v) }
+func (enc *GelfEncoder) AddUint(k string, v uint) {
+	enc.JsonEncoder.AddUint64(enc.gelfKey(k), uint64(v))
+}
+func (enc *GelfEncoder) AddUint64(k string, v uint64) { enc.JsonEncoder.AddUint64(enc.gelfKey(k), v) }
+func (enc *GelfEncoder) AddUint32(k string, v uint32) {
+	enc.JsonEncoder.AddUint64(enc.gelfKey(k), uint64(v))
+}
+func (enc *GelfEncoder) AddUint16(k string, v uint16) {
+	enc.JsonEncoder.AddUint64(enc.gelfKey(k), uint64(v))
+}
+func (enc *GelfEncoder) AddUint8(k string, v uint8) {
+	enc.JsonEncoder.AddUint64(enc.gelfKey(k), uint64(v))
+}
+func (enc *GelfEncoder) AddUintptr(k string, v uintptr) {
+	enc.JsonEncoder.AddUint64(enc.gelfKey(k), uint64(v))
+}
+
+func (enc *GelfEncoder) Clone() Encoder {
+	clone := enc.clone()
+	clone.Buf.Write(enc.Buf.Bytes())
+	return clone
+}
+
+func (enc *GelfEncoder) clone() *GelfEncoder {
+	clone := getGelfEncoder()
+	clone.JsonEncoder = enc.JsonEncoder.clone()
+	clone.host = enc.host
+	clone.prefix = enc.prefix
+	return clone
+}
+
+func (enc *GelfEncoder) EncodeEntry(ent entry.Entry, fields []field.Field) (*buffer.Buffer, error) {
+	final := enc.clone()
+	// 元数据不使用 prefix
+	final.prefix = ""
+	final.Buf.AppendByte('{')
+
+	final.JsonEncoder.AddString("version", GelfVersion)
+	final.JsonEncoder.AddString("host", final.host)
+	final.JsonEncoder.AddString("short_message", ent.Message)
+	final.JsonEncoder.AddFloat64("timestamp", float64(ent.Time.UnixNano()/int64(time.Millisecond))/1000)
+	final.JsonEncoder.AddInt64("level", int64(GelfLevel(ent.Level)))
+
+	if final.LevelKey != "" {
+		final.addKey(final.gelfKey(final.LevelKey))
+		cur := final.Buf.Len()
+		final.EncodeLevel(ent.Level, final)
+		if cur == final.Buf.Len() {
+			final.AppendString(ent.Level.String())
+		}
+	}
+	if ent.LoggerName != "" && final.NameKey != "" {
+		final.addKey(final.gelfKey(final.NameKey))
+		cur := final.Buf.Len()
+		nameEncoder := final.EncodeName
+		if nameEncoder == nil {
+			nameEncoder = FullNameEncoder
+		}
+		nameEncoder(ent.LoggerName, final)
+		if cur == final.Buf.Len() {
+			final.AppendString(ent.LoggerName)
+		}
+	}
+	if ent.Caller.Defined && final.CallerKey != "" {
+		final.addKey(final.gelfKey(final.CallerKey))
+		cur := final.Buf.Len()
+		if final.EncodeCaller != nil {
+			final.EncodeCaller(ent.Caller, final)
+		}
+		if cur == final.Buf.Len() {
+			final.AppendString(ent.Caller.String())
+		}
+	}
+	if enc.Buf.Len() > 0 {
+		final.addElementSeparator()
+		final.Buf.Write(enc.Buf.Bytes())
+	}
+	final.prefix = enc.prefix
+	for _, f := range fields {
+		if f.Integer == field.STACK_TYPE_INT && f.Type == zapcore.StringType {
+			ent.Stack = f.String
+		} else {
+			f.AddTo(final)
+		}
+	}
+	if ent.Stack != "" && final.StacktraceKey != "" {
+		final.JsonEncoder.AddString("full_message", ent.Stack)
+	}
+	final.Buf.AppendByte('}')
+	if final.LineEnding != "" {
+		final.Buf.AppendString(final.LineEnding)
+	} else {
+		final.Buf.AppendString(DefaultLineEnding)
+	}
+
+	ret := final.Buf
+	putJSONEncoder(final.JsonEncoder)
+	putGelfEncoder(final)
+	return ret, nil
+}
@@ -0,0 +1,45 @@
+package encoder
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weitrue/log/config"
+	"github.com/weitrue/log/entry"
+	"github.com/weitrue/log/field"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestGelfFullMessage(t *testing.T) {
+	stack := field.Field{Key: "stack", Type: zapcore.StringType, Integer: field.STACK_TYPE_INT, String: "main.go:1"}
+	tests := []struct {
+		name          string
+		stacktraceKey string
+		fields        []field.Field
+		ent           entry.Entry
+		want          bool
+	}{
+		{"stack field", "stacktrace", []field.Field{stack}, entry.Entry{}, true},
+		{"entry stack", "stacktrace", nil, entry.Entry{Stack: "main.go:1"}, true},
+		{"no stacktrace key", "", []field.Field{stack}, entry.Entry{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := NewGelfEncoder(config.EncoderConfig{MessageKey: "msg", StacktraceKey: tt.stacktraceKey})
+			tt.ent.Time, tt.ent.Message = time.Unix(0, 0), "failed"
+			buf, err := enc.EncodeEntry(tt.ent, tt.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer buf.Free()
+			got := buf.String()
+			if strings.Contains(got, `"full_message":"main.go:1"`) != tt.want {
+				t.Errorf("full_message written = %v, want %v: %s", !tt.want, tt.want, got)
+			}
+			if strings.Contains(got, "_stack") {
+				t.Errorf("stack field should not be an additional field: %s", got)
+			}
+		})
+	}
+}
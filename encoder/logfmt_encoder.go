@@ -0,0 +1,463 @@
+package encoder
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/weitrue/log/bufferpool"
+	"github.com/weitrue/log/config"
+	"github.com/weitrue/log/entry"
+	"github.com/weitrue/log/field"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+/*
+logfmt 格式的编码器，输出格式为：
+  time=2019-02-22T11:48:32.509+08:00 level=INFO log=api caller=api/main.go:12 msg="request done" req.method=GET tags.0=a
+
+  1、字段之间使用空格分隔，值中包含空格、等号、双引号或者控制字符时，使用双引号包裹并转义；
+  2、嵌套的对象、数组以及 Namespace 展开为以 "." 连接的 key，数组使用下标作为 key；
+  3、堆栈信息（包括使用 STACK_TYPE_INT 标记的 stack 字段）在所有字段之后以 StacktraceKey 输出，比如 stack="..."，
+     多行的堆栈按规则 1 转义为一行，StacktraceKey 为空时不输出。
+*/
+
+const LogfmtEncoding = "logfmt"
+
+var _logfmtPool = sync.Pool{New: func() interface{} {
+	return &LogfmtEncoder{}
+}}
+
+func getLogfmtEncoder() *LogfmtEncoder {
+	return _logfmtPool.Get().(*LogfmtEncoder)
+}
+
+func putLogfmtEncoder(enc *LogfmtEncoder) {
+	if enc.reflectBuf != nil {
+		enc.reflectBuf.Free()
+	}
+	enc.EncoderConfig = nil
+	enc.Buf = nil
+	enc.prefix = ""
+	enc.reflectBuf = nil
+	enc.reflectEnc = nil
+	_logfmtPool.Put(enc)
+}
+
+// LogfmtEncoder 将日志数据编码为 logfmt 格式的字符串数据
+type LogfmtEncoder struct {
+	*config.EncoderConfig
+	Buf *buffer.Buffer
+	// prefix 当前字段 key 的前缀，由外层对象的 key 以及通过 OpenNamespace 打开的命名空间组成
+	prefix string
+
+	// for encoding generic values by reflection
+	reflectBuf *buffer.Buffer
+	reflectEnc *json.Encoder
+}
+
+// NewLogfmtEncoder 创建 logfmt 格式的编码器，EncoderConfig 中 key 为空字符串的元数据不会输出
+func NewLogfmtEncoder(cfg config.EncoderConfig) Encoder {
+	return &LogfmtEncoder{
+		EncoderConfig: &cfg,
+		Buf:           bufferpool.Get(),
+	}
+}
+
+func (enc *LogfmtEncoder) AddArray(key string, arr ArrayMarshaler) error {
+	return arr.MarshalLogArray(&logfmtArrayEncoder{enc: enc, key: key, indexed: true})
+}
+
+func (enc *LogfmtEncoder) AddObject(key string, obj ObjectMarshaler) error {
+	old := enc.prefix
+	enc.prefix = old + key + "."
+	err := obj.MarshalLogObject(enc)
+	enc.prefix = old
+	return err
+}
+
+func (enc *LogfmtEncoder) AddBinary(key string, val []byte) {
+	enc.AddString(key, base64.StdEncoding.EncodeToString(val))
+}
+
+func (enc *LogfmtEncoder) AddByteString(key string, val []byte) {
+	enc.addKey(key)
+	enc.appendByteString(val)
+}
+
+func (enc *LogfmtEncoder) AddBool(key string, val bool) {
+	enc.addKey(key)
+	enc.Buf.AppendBool(val)
+}
+
+func (enc *LogfmtEncoder) AddComplex128(key string, val complex128) {
+	enc.addKey(key)
+	// Cast to a platform-independent, fixed-size type.
+	r, i := float64(real(val)), float64(imag(val))
+	enc.Buf.AppendFloat(r, 64)
+	enc.Buf.AppendByte('+')
+	enc.Buf.AppendFloat(i, 64)
+	enc.Buf.AppendByte('i')
+}
+
+func (enc *LogfmtEncoder) AddDuration(key string, val time.Duration) {
+	enc.encodeValue(key, func(arr PrimitiveArrayEncoder) bool {
+		if enc.EncodeDuration == nil {
+			return false
+		}
+		enc.EncodeDuration(val, arr)
+		return true
+	}, func() {
+		enc.AddString(key, val.String())
+	})
+}
+
+func (enc *LogfmtEncoder) AddFloat64(key string, val float64) {
+	enc.addKey(key)
+	enc.appendFloat(val, 64)
+}
+
+func (enc *LogfmtEncoder) AddInt64(key string, val int64) {
+	enc.addKey(key)
+	enc.Buf.AppendInt(val)
+}
+
+func (enc *LogfmtEncoder) resetReflectBuf() {
+	if enc.reflectBuf == nil {
+		enc.reflectBuf = bufferpool.Get()
+		enc.reflectEnc = json.NewEncoder(enc.reflectBuf)
+	} else {
+		enc.reflectBuf.Reset()
+	}
+}
+
+// AddReflected 使用 json 序列化，并作为字符串值输出
+func (enc *LogfmtEncoder) AddReflected(key string, obj interface{}) error {
+	enc.resetReflectBuf()
+	err := enc.reflectEnc.Encode(obj)
+	if err != nil {
+		return err
+	}
+	enc.reflectBuf.TrimNewline()
+	enc.addKey(key)
+	enc.appendByteString(enc.reflectBuf.Bytes())
+	return nil
+}
+
+func (enc *LogfmtEncoder) OpenNamespace(key string) {
+	enc.prefix += key + "."
+}
+
+func (enc *LogfmtEncoder) AddString(key, val string) {
+	enc.addKey(key)
+	enc.appendString(val)
+}
+
+func (enc *LogfmtEncoder) AddTime(key string, val time.Time) {
+	enc.encodeValue(key, func(arr PrimitiveArrayEncoder) bool {
+		if enc.EncodeTime == nil {
+			return false
+		}
+		enc.EncodeTime(val, arr)
+		return true
+	}, func() {
+		enc.AddInt64(key, val.UnixNano())
+	})
+}
+
+func (enc *LogfmtEncoder) AddUint64(key string, val uint64) {
+	enc.addKey(key)
+	enc.Buf.AppendUint(val)
+}
+
+func (enc *LogfmtEncoder) AddComplex64(k string, v complex64) { enc.AddComplex128(k, complex128(v)) }
+func (enc *LogfmtEncoder) AddFloat32(k string, v float32) {
+	enc.addKey(k)
+	enc.appendFloat(float64(v), 32)
+}
+func (enc *LogfmtEncoder) AddInt(k string, v int)         { enc.AddInt64(k, int64(v)) }
+func (enc *LogfmtEncoder) AddInt32(k string, v int32)     { enc.AddInt64(k, int64(v)) }
+func (enc *LogfmtEncoder) AddInt16(k string, v int16)     { enc.AddInt64(k, int64(v)) }
+func (enc *LogfmtEncoder) AddInt8(k string, v int8)       { enc.AddInt64(k, int64(v)) }
+func (enc *LogfmtEncoder) AddUint(k string, v uint)       { enc.AddUint64(k, uint64(v)) }
+func (enc *LogfmtEncoder) AddUint32(k string, v uint32)   { enc.AddUint64(k, uint64(v)) }
+func (enc *LogfmtEncoder) AddUint16(k string, v uint16)   { enc.AddUint64(k, uint64(v)) }
+func (enc *LogfmtEncoder) AddUint8(k string, v uint8)     { enc.AddUint64(k, uint64(v)) }
+func (enc *LogfmtEncoder) AddUintptr(k string, v uintptr) { enc.AddUint64(k, uint64(v)) }
+
+func (enc *LogfmtEncoder) Clone() Encoder {
+	clone := enc.clone()
+	clone.prefix = enc.prefix
+	clone.Buf.Write(enc.Buf.Bytes())
+	return clone
+}
+
+func (enc *LogfmtEncoder) clone() *LogfmtEncoder {
+	clone := getLogfmtEncoder()
+	clone.EncoderConfig = enc.EncoderConfig
+	clone.Buf = bufferpool.Get()
+	return clone
+}
+
+func (enc *LogfmtEncoder) EncodeEntry(ent entry.Entry, fields []field.Field) (*buffer.Buffer, error) {
+	final := enc.clone()
+
+	// 元数据不使用 With 中打开的命名空间作为前缀
+	if final.TimeKey != "" {
+		final.AddTime(final.TimeKey, ent.Time)
+	}
+	if final.LevelKey != "" {
+		final.encodeValue(final.LevelKey, func(arr PrimitiveArrayEncoder) bool {
+			if final.EncodeLevel == nil {
+				return false
+			}
+			final.EncodeLevel(ent.Level, arr)
+			return true
+		}, func() {
+			final.AddString(final.LevelKey, ent.Level.String())
+		})
+	}
+	if ent.LoggerName != "" && final.NameKey != "" {
+		nameEncoder := final.EncodeName
+		// if no name encoder provided, fall back to FullNameEncoder for backwards
+		// compatibility
+		if nameEncoder == nil {
+			nameEncoder = FullNameEncoder
+		}
+		final.encodeValue(final.NameKey, func(arr PrimitiveArrayEncoder) bool {
+			nameEncoder(ent.LoggerName, arr)
+			return true
+		}, func() {
+			final.AddString(final.NameKey, ent.LoggerName)
+		})
+	}
+	if ent.Caller.Defined && final.CallerKey != "" {
+		final.encodeValue(final.CallerKey, func(arr PrimitiveArrayEncoder) bool {
+			if final.EncodeCaller == nil {
+				return false
+			}
+			final.EncodeCaller(ent.Caller, arr)
+			return true
+		}, func() {
+			final.AddString(final.CallerKey, ent.Caller.String())
+		})
+	}
+	if final.MessageKey != "" {
+		final.AddString(final.MessageKey, ent.Message)
+	}
+	if enc.Buf.Len() > 0 {
+		final.addSeparator()
+		final.Buf.Write(enc.Buf.Bytes())
+	}
+
+	final.prefix = enc.prefix
+	for _, f := range fields {
+		// 处理 stack
+		if f.Integer == field.STACK_TYPE_INT && f.Type == zapcore.StringType {
+			ent.Stack = f.String
+		} else {
+			f.AddTo(final)
+		}
+	}
+	final.prefix = ""
+	if ent.Stack != "" && final.StacktraceKey != "" {
+		final.AddString(final.StacktraceKey, ent.Stack)
+	}
+	if final.LineEnding != "" {
+		final.Buf.AppendString(final.LineEnding)
+	} else {
+		final.Buf.AppendString(DefaultLineEnding)
+	}
+
+	ret := final.Buf
+	putLogfmtEncoder(final)
+	return ret, nil
+}
+
+// encodeValue 使用 EncodeTime 等自定义编码器输出 key 的值，编码器为空或者没有输出数据时，调用 fallback
+func (enc *LogfmtEncoder) encodeValue(key string, encode func(PrimitiveArrayEncoder) bool, fallback func()) {
+	cur := enc.Buf.Len()
+	if !encode(&logfmtArrayEncoder{enc: enc, key: key}) || cur == enc.Buf.Len() {
+		fallback()
+	}
+}
+
+func (enc *LogfmtEncoder) addSeparator() {
+	if enc.Buf.Len() > 0 {
+		enc.Buf.AppendByte(' ')
+	}
+}
+
+// addKey 输出 key=，key 中的空格、等号、双引号以及控制字符替换为下划线
+func (enc *LogfmtEncoder) addKey(key string) {
+	enc.addSeparator()
+	key = enc.prefix + key
+	if key == "" {
+		enc.Buf.AppendByte('_')
+	}
+	for i := 0; i < len(key); i++ {
+		b := key[i]
+		if b <= ' ' || b == '=' || b == '"' || b == 0x7f {
+			b = '_'
+		}
+		enc.Buf.AppendByte(b)
+	}
+	enc.Buf.AppendByte('=')
+}
+
+func (enc *LogfmtEncoder) appendFloat(val float64, bitSize int) {
+	switch {
+	case math.IsNaN(val):
+		enc.Buf.AppendString("NaN")
+	case math.IsInf(val, 1):
+		enc.Buf.AppendString("+Inf")
+	case math.IsInf(val, -1):
+		enc.Buf.AppendString("-Inf")
+	default:
+		enc.Buf.AppendFloat(val, bitSize)
+	}
+}
+
+func (enc *LogfmtEncoder) appendString(s string) {
+	if !logfmtNeedsQuote(s) {
+		enc.Buf.AppendString(s)
+		return
+	}
+	enc.Buf.AppendByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		enc.appendEscapedRune(r, size, s[i:i+size])
+		i += size
+	}
+	enc.Buf.AppendByte('"')
+}
+
+// appendByteString is no-alloc equivalent of appendString(string(s)) for s []byte.
+func (enc *LogfmtEncoder) appendByteString(s []byte) {
+	if !logfmtNeedsQuoteBytes(s) {
+		enc.Buf.Write(s)
+		return
+	}
+	enc.Buf.AppendByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRune(s[i:])
+		enc.appendEscapedRune(r, size, string(s[i:i+size]))
+		i += size
+	}
+	enc.Buf.AppendByte('"')
+}
+
+func (enc *LogfmtEncoder) appendEscapedRune(r rune, size int, raw string) {
+	if r == utf8.RuneError && size == 1 {
+		enc.Buf.AppendString("\ufffd")
+		return
+	}
+	if r >= utf8.RuneSelf {
+		enc.Buf.AppendString(raw)
+		return
+	}
+	b := byte(r)
+	switch b {
+	case '\\', '"':
+		enc.Buf.AppendByte('\\')
+		enc.Buf.AppendByte(b)
+	case '\n':
+		enc.Buf.AppendString(`\n`)
+	case '\r':
+		enc.Buf.AppendString(`\r`)
+	case '\t':
+		enc.Buf.AppendString(`\t`)
+	default:
+		if b < 0x20 || b == 0x7f {
+			enc.Buf.AppendString(`\u00`)
+			enc.Buf.AppendByte(_hex[b>>4])
+			enc.Buf.AppendByte(_hex[b&0xF])
+			return
+		}
+		enc.Buf.AppendByte(b)
+	}
+}
+
+func logfmtNeedsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		if logfmtSpecialByte(s[i]) {
+			return true
+		}
+	}
+	return !utf8.ValidString(s)
+}
+
+func logfmtNeedsQuoteBytes(s []byte) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for _, b := range s {
+		if logfmtSpecialByte(b) {
+			return true
+		}
+	}
+	return !utf8.Valid(s)
+}
+
+func logfmtSpecialByte(b byte) bool {
+	return b <= ' ' || b == '=' || b == '"' || b == '\\' || b == 0x7f
+}
+
+// logfmtArrayEncoder 将数组元素展开为 key.下标=value；indexed 为 false 时，
+// 用于 EncodeTime 等自定义编码器，直接输出为 key=value
+type logfmtArrayEncoder struct {
+	enc     *LogfmtEncoder
+	key     string
+	indexed bool
+	i       int
+}
+
+func (a *logfmtArrayEncoder) nextKey() string {
+	if !a.indexed {
+		return a.key
+	}
+	key := a.key + "." + strconv.Itoa(a.i)
+	a.i++
+	return key
+}
+
+func (a *logfmtArrayEncoder) AppendArray(v ArrayMarshaler) error {
+	return a.enc.AddArray(a.nextKey(), v)
+}
+
+func (a *logfmtArrayEncoder) AppendObject(v ObjectMarshaler) error {
+	return a.enc.AddObject(a.nextKey(), v)
+}
+
+func (a *logfmtArrayEncoder) AppendReflected(v interface{}) error {
+	return a.enc.AddReflected(a.nextKey(), v)
+}
+
+func (a *logfmtArrayEncoder) AppendBool(v bool)              { a.enc.AddBool(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendByteString(v []byte)      { a.enc.AddByteString(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendComplex128(v complex128)  { a.enc.AddComplex128(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendComplex64(v complex64)    { a.enc.AddComplex64(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendDuration(v time.Duration) { a.enc.AddDuration(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendFloat64(v float64)        { a.enc.AddFloat64(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendFloat32(v float32)        { a.enc.AddFloat32(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendInt(v int)                { a.enc.AddInt(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendInt64(v int64)            { a.enc.AddInt64(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendInt32(v int32)            { a.enc.AddInt32(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendInt16(v int16)            { a.enc.AddInt16(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendInt8(v int8)              { a.enc.AddInt8(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendString(v string)          { a.enc.AddString(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendTime(v time.Time)         { a.enc.AddTime(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendUint(v uint)              { a.enc.AddUint(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendUint64(v uint64)          { a.enc.AddUint64(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendUint32(v uint32)          { a.enc.AddUint32(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendUint16(v uint16)          { a.enc.AddUint16(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendUint8(v uint8)            { a.enc.AddUint8(a.nextKey(), v) }
+func (a *logfmtArrayEncoder) AppendUintptr(v uintptr)        { a.enc.AddUintptr(a.nextKey(), v) }
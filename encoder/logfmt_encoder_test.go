@@ -0,0 +1,36 @@
+package encoder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weitrue/log/config"
+	"github.com/weitrue/log/entry"
+	"github.com/weitrue/log/field"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogfmtStack(t *testing.T) {
+	stack := field.Field{Key: "stack", Type: zapcore.StringType, Integer: field.STACK_TYPE_INT, String: "main.main\n\tmain.go:1"}
+	tests := []struct {
+		name          string
+		stacktraceKey string
+		want          string
+	}{
+		{"stack key", "stack", `msg=failed k=v stack="main.main\n\tmain.go:1"` + "\n"},
+		{"no stack key", "", "msg=failed k=v\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := NewLogfmtEncoder(config.EncoderConfig{MessageKey: "msg", StacktraceKey: tt.stacktraceKey})
+			buf, err := enc.EncodeEntry(entry.Entry{Time: time.Unix(0, 0), Message: "failed"}, []field.Field{stack, field.String("k", "v")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer buf.Free()
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
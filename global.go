@@ -52,6 +52,18 @@ func Error(msg string, fields ...field.Field){
 func Critical(msg string, fields ...field.Field){
     getGlobalLog().Critical(msg,fields...)
 }
+func Panic(msg string, fields ...field.Field){
+    getGlobalLog().Panic(msg,fields...)
+}
+func Panicf(template string, args ...interface{}){
+    getGlobalLog().Panicf(template,args...)
+}
+func Fatal(msg string, fields ...field.Field){
+    getGlobalLog().Fatal(msg,fields...)
+}
+func Fatalf(template string, args ...interface{}){
+    getGlobalLog().Fatalf(template,args...)
+}
 func Fixed(msg string, fields ...field.Field){
     getGlobalLog().Fixed(msg,fields...)
 }
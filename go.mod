@@ -3,11 +3,15 @@ module github.com/weitrue/log
 go 1.17
 
 require (
-    github.com/pkg/errors v0.8.1 // indirect
-    github.com/stretchr/testify v1.7.0 // indirect
 	github.com/weitrue/go.uuid v1.3.0
 	go.uber.org/atomic v1.4.0
 	go.uber.org/multierr v1.1.0
 	go.uber.org/zap v1.10.0
 	golang.org/x/sys v0.0.0-20200113162924-86b910548bc1
-)
\ No newline at end of file
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/pkg/errors v0.8.1 // indirect
+	github.com/stretchr/testify v1.7.0 // indirect
+)
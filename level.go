@@ -7,6 +7,8 @@ var INFO = level.InfoLevel
 var WARN = level.WarnLevel
 var ERROR = level.ErrorLevel
 var CRITICAL = level.CriticalLevel
+var PANIC = level.PanicLevel
+var FATAL = level.FatalLevel
 var FIXED = level.FixedLevel
 
 var Name2Level = level.Name2Level
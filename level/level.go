@@ -22,12 +22,10 @@ const (
     ErrorLevel
     // CriticalLevel 程序异常级别。一般用于程序异常时输出错误信息和堆栈信息。
     CriticalLevel
-    // PanicLevel logs a message, then panics.
-    // 占位，日志等级无需这么多等级。
-    _PanicLevel
-    // FatalLevel logs a message, then calls os.Exit(1).
-    // 占位，日志等级无需这么多等级。
-    _FatalLevel
+    // PanicLevel 记录日志后触发 panic。
+    PanicLevel
+    // FatalLevel 记录日志后退出程序。
+    FatalLevel
     // FixedLevel 固定信息级别。主要特点是最高日志等级的正常日志。用于记录服务运行信息或其他加载信息等与普通信息区分开来的正常信息。
     FixedLevel
 
@@ -83,6 +81,11 @@ func (lvl AtomicLevel) MarshalText() (text []byte, err error) {
     return StringToSliceByte(Level2CapitalName(lvl.Level())),nil
 }
 
+// IsZero 判断 AtomicLevel 是否未通过 NewAtomicLevelAt 或者反序列化进行初始化
+func (lvl AtomicLevel) IsZero() bool {
+    return lvl.AtomicLevel == zap.AtomicLevel{}
+}
+
 type LevelEnabler = zapcore.LevelEnabler
 
 // NewAtomicLevelAt 方便创建 AtomicLevel
@@ -100,16 +103,16 @@ func Name2Level(l string) (Level,bool) {
         return DebugLevel,true
     case "INFO":
         return InfoLevel,true
-    case "WARNING":
+    case "WARN","WARNING":
         return WarnLevel,true
     case "ERROR":
         return ErrorLevel,true
     case "CRITICAL":
         return CriticalLevel,true
     case "PANIC":
-        return _PanicLevel,true
+        return PanicLevel,true
     case "FATAL":
-        return _FatalLevel,true
+        return FatalLevel,true
     case "FIXED":
         return FixedLevel,true
     default:
@@ -150,10 +153,16 @@ func Level2Name(l Level,capital bool) string {
             return "CRITICAL"
         }
         return  "critical"
-    case _PanicLevel:
-        return "PANIC"
-    case _FatalLevel:
-        return "FATAL"
+    case PanicLevel:
+        if capital{
+            return "PANIC"
+        }
+        return  "panic"
+    case FatalLevel:
+        if capital{
+            return "FATAL"
+        }
+        return  "fatal"
     case FixedLevel:
         if capital{
             return "FIXED"
@@ -180,9 +189,9 @@ func Level2CapitalName(l Level) string {
             return "ERROR"
     case CriticalLevel:
             return "CRITICAL"
-    case _PanicLevel:
+    case PanicLevel:
         return "PANIC"
-    case _FatalLevel:
+    case FatalLevel:
         return "FATAL"
     case FixedLevel:
             return "FIXED"
@@ -0,0 +1,725 @@
+package log
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/weitrue/log/config"
+    "github.com/weitrue/log/encoder"
+    "github.com/weitrue/log/level"
+    "github.com/weitrue/log/writer"
+    "github.com/weitrue/log/writer/flumefilewriter"
+    "github.com/weitrue/log/writer/gelf"
+    "github.com/weitrue/log/writer/syslog"
+    "go.uber.org/zap/zapcore"
+    "gopkg.in/yaml.v3"
+)
+
+// 配置文件中支持的输出源类型
+const (
+    OutputStdout = "stdout"
+    OutputStderr = "stderr"
+    OutputFile   = "file"
+    OutputSyslog = "syslog"
+    OutputFlume  = "flume"
+    OutputGelf   = "gelf"
+)
+
+// FileConfig 日志配置文件结构，支持 yaml 和 json 格式。
+// 与 config.Config 不同的是，输出源通过 Outputs 声明，每个输出源可以单独设置编码器和日志等级。
+//
+// 示例：
+//  name: mylog
+//  id: mylog
+//  level: INFO
+//  enableCaller: true
+//  encoding: json
+//  encoderConfig:
+//    preset: es
+//  outputs:
+//    - type: stdout
+//      encoding: console
+//      level: DEBUG
+//    - type: syslog
+//      addr: 127.0.0.1:514
+//      level: ERROR
+type FileConfig struct {
+    // Name 日志名称，参考 config.Config.Name
+    Name string `json:"name" yaml:"name"`
+    // ID 日志 ID，参考 config.Config.ID
+    ID string `json:"id" yaml:"id"`
+    // ForceReplace 参考 config.Config.ForceReplace
+    ForceReplace bool `json:"forceReplace" yaml:"forceReplace"`
+    // Level 最低允许记录等级，未设置时默认为 INFO
+    Level level.AtomicLevel `json:"level" yaml:"level"`
+    // CustomLevelEnabler 参考 config.Config.CustomLevelEnabler，无法在配置文件中设置，对所有输出源生效
+    CustomLevelEnabler level.LevelEnablerFunc `json:"-" yaml:"-"`
+    // Development 参考 config.Config.Development
+    Development bool `json:"development" yaml:"development"`
+    // EnableCaller 参考 config.Config.EnableCaller
+    EnableCaller bool `json:"enableCaller" yaml:"enableCaller"`
+    // EnableStacktrace 参考 config.Config.EnableStacktrace
+    EnableStacktrace bool `json:"enableStacktrace" yaml:"enableStacktrace"`
+    // Encoding 默认编码器，未设置时为 "json"，输出源没有单独设置编码器时使用
+    Encoding string `json:"encoding" yaml:"encoding"`
+    // EncoderConfig 默认编码器配置，输出源没有单独设置时使用
+    EncoderConfig EncoderFileConfig `json:"encoderConfig" yaml:"encoderConfig"`
+    // InitialFields 参考 config.Config.InitialFields
+    InitialFields map[string]interface{} `json:"initialFields" yaml:"initialFields"`
+    // Sampling 日志采样配置，为空时不进行采样
+    Sampling *SamplingFileConfig `json:"sampling" yaml:"sampling"`
+    // Outputs 输出源列表，为空时输出到标准输出
+    Outputs []OutputConfig `json:"outputs" yaml:"outputs"`
+}
+
+// EncoderFileConfig 配置文件中的编码器配置。
+// 先根据 Preset 选择默认配置，再使用非空字段进行覆盖。
+// Key 类字段设置为空字符串时，表示不输出该字段数据。
+type EncoderFileConfig struct {
+    // Preset 默认配置：production（默认）、development、es、ecs（需要同时设置 encoding 为 ecs）
+    Preset string `json:"preset" yaml:"preset"`
+
+    TimeKey       *string `json:"timeKey" yaml:"timeKey"`
+    LevelKey      *string `json:"levelKey" yaml:"levelKey"`
+    NameKey       *string `json:"nameKey" yaml:"nameKey"`
+    CallerKey     *string `json:"callerKey" yaml:"callerKey"`
+    MessageKey    *string `json:"messageKey" yaml:"messageKey"`
+    StacktraceKey *string `json:"stacktraceKey" yaml:"stacktraceKey"`
+    LineEnding    *string `json:"lineEnding" yaml:"lineEnding"`
+
+    // LevelEncoder 日志等级编码器：capital、lowercase
+    LevelEncoder string `json:"levelEncoder" yaml:"levelEncoder"`
+    // TimeEncoder 时间编码器：rfc3339、iso8601、epoch、epochMillis
+    TimeEncoder string `json:"timeEncoder" yaml:"timeEncoder"`
+    // DurationEncoder duration 编码器：string、seconds、nanos
+    DurationEncoder string `json:"durationEncoder" yaml:"durationEncoder"`
+    // CallerEncoder caller 编码器：short、full
+    CallerEncoder string `json:"callerEncoder" yaml:"callerEncoder"`
+}
+
+// SamplingFileConfig 配置文件中的采样配置，参数含义参考 config.SamplingConfig
+type SamplingFileConfig struct {
+    Initial    int `json:"initial" yaml:"initial"`
+    Thereafter int `json:"thereafter" yaml:"thereafter"`
+    // Tick 采样统计周期，使用 time.ParseDuration 的格式，默认为 1s
+    Tick string `json:"tick" yaml:"tick"`
+    // ExemptLevels 不参与采样的日志等级，未设置时默认为 CRITICAL、PANIC、FATAL 和 FIXED，设置为 [] 时所有等级都参与采样
+    ExemptLevels []string `json:"exemptLevels" yaml:"exemptLevels"`
+}
+
+// OutputConfig 配置文件中的输出源配置
+type OutputConfig struct {
+    // Type 输出源类型：stdout、stderr、file、syslog、flume、gelf
+    Type string `json:"type" yaml:"type"`
+    // Encoding 该输出源使用的编码器，为空时使用 FileConfig.Encoding，gelf 类型输出为空时使用 "gelf"
+    Encoding string `json:"encoding" yaml:"encoding"`
+    // EncoderConfig 该输出源使用的编码器配置，为空时使用 FileConfig.EncoderConfig
+    EncoderConfig *EncoderFileConfig `json:"encoderConfig" yaml:"encoderConfig"`
+    // Level 该输出源的最低允许记录等级，为空时使用 FileConfig.Level
+    Level *level.AtomicLevel `json:"level" yaml:"level"`
+    // MaxLevel 该输出源的最高允许记录等级，为空时不限制
+    MaxLevel *level.AtomicLevel `json:"maxLevel" yaml:"maxLevel"`
+
+    // Path file 类型输出的文件路径
+    Path string `json:"path" yaml:"path"`
+    // Addr syslog、gelf 类型输出的服务地址，unix、unixgram 传输时为 socket 文件路径
+    Addr string `json:"addr" yaml:"addr"`
+    // Facility syslog 类型输出的 facility，比如 local0（默认）、daemon，日志的 severity 根据每条日志的等级计算
+    Facility string `json:"facility" yaml:"facility"`
+    // Syslog syslog 类型输出的配置，为空时使用 tcp 发送
+    Syslog *SyslogOutputConfig `json:"syslog" yaml:"syslog"`
+    // Flume flume 类型输出的配置
+    Flume *FlumeOutputConfig `json:"flume" yaml:"flume"`
+    // Gelf gelf 类型输出的配置，为空时使用 tcp 发送
+    Gelf *GelfOutputConfig `json:"gelf" yaml:"gelf"`
+}
+
+// SyslogOutputConfig 配置文件中的 syslog 输出配置，通过 syslog.DialByLevel 创建 V2 版本的 writer
+type SyslogOutputConfig struct {
+    // Network tcp（默认）、udp、unix、unixgram 或 tls
+    Network string `json:"network" yaml:"network"`
+    // CAFile、CertFile、KeyFile tls 传输时使用的证书文件，参考 syslog.NewTLSConfig，都为空时使用系统根证书校验服务端
+    CAFile   string `json:"caFile" yaml:"caFile"`
+    CertFile string `json:"certFile" yaml:"certFile"`
+    KeyFile  string `json:"keyFile" yaml:"keyFile"`
+    // MaxDatagramSize udp、unixgram 传输时单条消息的最大长度，默认为 syslog.DefaultMaxDatagramSize
+    MaxDatagramSize int `json:"maxDatagramSize" yaml:"maxDatagramSize"`
+
+    // 以下字段参考 syslog.Settings，为空时使用环境变量，时间间隔使用 time.ParseDuration 的格式
+    BufferDir        string `json:"bufferDir" yaml:"bufferDir"`
+    Timeout          string `json:"timeout" yaml:"timeout"`
+    ConnLifeTime     string `json:"connLifeTime" yaml:"connLifeTime"`
+    CommitBufferSize int64  `json:"commitBufferSize" yaml:"commitBufferSize"`
+    CacheQuota       int64  `json:"cacheQuota" yaml:"cacheQuota"`
+    // Overflow 缓存达到 cacheQuota 时的处理策略：dropnewest（默认）、dropoldest、dropbylevel、block，
+    // BlockTimeout block 策略时 Write 的最长等待时间，参考 syslog.Overflow
+    Overflow     string `json:"overflow" yaml:"overflow"`
+    BlockTimeout string `json:"blockTimeout" yaml:"blockTimeout"`
+    // Gzip 本地缓存是否使用 gzip 压缩，参考 syslog.Gzip
+    Gzip bool `json:"gzip" yaml:"gzip"`
+
+    // Endpoints 备用地址，OutputConfig.Addr 为第一个地址，参考 syslog.Endpoints
+    Endpoints []SyslogEndpointConfig `json:"endpoints" yaml:"endpoints"`
+    // Policy 多个地址时的选择策略：failover（默认）、roundrobin、weighted
+    Policy string `json:"policy" yaml:"policy"`
+    // HealthCheckInterval、MaxBackoff 不可用地址的健康检查间隔，参考 syslog.HealthCheck
+    HealthCheckInterval string `json:"healthCheckInterval" yaml:"healthCheckInterval"`
+    MaxBackoff          string `json:"maxBackoff" yaml:"maxBackoff"`
+    // FailureThreshold、OpenTimeout、MaxOpenTimeout 熔断器设置，参考 syslog.BreakerConfig
+    FailureThreshold int    `json:"failureThreshold" yaml:"failureThreshold"`
+    OpenTimeout      string `json:"openTimeout" yaml:"openTimeout"`
+    MaxOpenTimeout   string `json:"maxOpenTimeout" yaml:"maxOpenTimeout"`
+}
+
+// SyslogEndpointConfig 配置文件中的 syslog 备用地址，Weight 只在 weighted 策略时生效
+type SyslogEndpointConfig struct {
+    Addr   string `json:"addr" yaml:"addr"`
+    Weight int    `json:"weight" yaml:"weight"`
+}
+
+// GelfOutputConfig 配置文件中的 gelf 输出配置，参数含义参考 gelf.Dial
+type GelfOutputConfig struct {
+    // Network tcp（默认） 或 udp
+    Network string `json:"network" yaml:"network"`
+    // Compression udp 发送时的压缩方式：none（默认）、gzip、zlib
+    Compression string `json:"compression" yaml:"compression"`
+    // ChunkSize udp 分片大小，默认为 gelf.DefaultChunkSize
+    ChunkSize int `json:"chunkSize" yaml:"chunkSize"`
+}
+
+// FlumeOutputConfig 配置文件中的 flume 输出配置，参数含义参考 flumefilewriter.NewWriteHandle
+// 时间间隔使用 time.ParseDuration 的格式，比如 "5m"
+type FlumeOutputConfig struct {
+    RootPath     string `json:"rootPath" yaml:"rootPath"`
+    TempFilePath string `json:"tempFilePath" yaml:"tempFilePath"`
+    TableName    string `json:"tableName" yaml:"tableName"`
+    // SendingMode multiplexing（默认） 或 replicating
+    SendingMode string `json:"sendingMode" yaml:"sendingMode"`
+    // SelectorType es（默认）、hdfs1、hdfs2
+    SelectorType string `json:"selectorType" yaml:"selectorType"`
+    IsFile       bool   `json:"isFile" yaml:"isFile"`
+    IsJson       bool   `json:"isJson" yaml:"isJson"`
+
+    WriteFileTime     string `json:"writeFileTime" yaml:"writeFileTime"`
+    FlashSliceDirTime string `json:"flashSliceDirTime" yaml:"flashSliceDirTime"`
+    MoveTempFileTime  string `json:"moveTempFileTime" yaml:"moveTempFileTime"`
+    MaxFileCount      int    `json:"maxFileCount" yaml:"maxFileCount"`
+    MaxLogCount       int    `json:"maxLogCount" yaml:"maxLogCount"`
+    MoveTempFile      bool   `json:"moveTempFile" yaml:"moveTempFile"`
+    // Location 文件名日期使用的时区，比如 Asia/Shanghai
+    Location string `json:"location" yaml:"location"`
+    // Gzip 日志文件是否使用 gzip 压缩，GzipLevel 为压缩级别，参考 flumefilewriter.Gzip
+    Gzip      bool `json:"gzip" yaml:"gzip"`
+    GzipLevel int  `json:"gzipLevel" yaml:"gzipLevel"`
+}
+
+// ParseFileConfig 解析配置数据，format 支持 "yaml"、"yml" 和 "json"
+func ParseFileConfig(data []byte, format string) (*FileConfig, error) {
+    fc := &FileConfig{}
+    var err error
+    switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+    case "json":
+        err = json.Unmarshal(data, fc)
+    case "yaml", "yml":
+        err = yaml.Unmarshal(data, fc)
+    default:
+        return nil, fmt.Errorf("unsupported config format %q", format)
+    }
+    if err != nil {
+        return nil, err
+    }
+    return fc, nil
+}
+
+// LoadFileConfig 读取配置文件，根据文件后缀判断格式，.json 以外的文件都按 yaml 解析
+func LoadFileConfig(path string) (*FileConfig, error) {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    format := "yaml"
+    if strings.EqualFold(filepath.Ext(path), ".json") {
+        format = "json"
+    }
+    return ParseFileConfig(data, format)
+}
+
+// NewFromFile 读取配置文件，并创建 Logger
+func NewFromFile(path string, options ...Option) (*Logger, error) {
+    fc, err := LoadFileConfig(path)
+    if err != nil {
+        return nil, err
+    }
+    return fc.Build(options...)
+}
+
+// Build 根据配置创建各个输出源，并调用 New 创建 Logger。
+// 创建失败时，已经创建的输出源会被关闭；创建成功后，输出源由 Logger.Close 负责关闭。
+func (fc *FileConfig) Build(options ...Option) (*Logger, error) {
+    cfg, err := fc.config()
+    if err != nil {
+        return nil, err
+    }
+
+    outputs := fc.Outputs
+    if len(outputs) == 0 {
+        outputs = []OutputConfig{{Type: OutputStdout}}
+    }
+
+    cfg.Sinks = make([]config.Sink, 0, len(outputs))
+    closers := make([]io.Closer, 0, len(outputs))
+    for i := range outputs {
+        sink, closer, err := outputs[i].build()
+        if closer != nil {
+            closers = append(closers, closer)
+        }
+        if err != nil {
+            closeAll(closers)
+            return nil, fmt.Errorf("output[%d] %s: %v", i, outputs[i].Type, err)
+        }
+        cfg.Sinks = append(cfg.Sinks, sink)
+    }
+    options = append([]Option{Closers(closers...)}, options...)
+
+    l, err := New(cfg, options...)
+    if err != nil {
+        closeAll(closers)
+        return nil, err
+    }
+    return l, nil
+}
+
+func (fc *FileConfig) config() (config.Config, error) {
+    cfg := config.Config{
+        Name:               fc.Name,
+        ID:                 fc.ID,
+        ForceReplace:       fc.ForceReplace,
+        Level:              fc.Level,
+        CustomLevelEnabler: fc.CustomLevelEnabler,
+        Development:        fc.Development,
+        EnableCaller:       fc.EnableCaller,
+        EnableStacktrace:   fc.EnableStacktrace,
+        Encoding:           fc.Encoding,
+        InitialFields:      fc.InitialFields,
+    }
+    if cfg.Level.IsZero() {
+        cfg.Level = level.NewAtomicLevelAt(INFO)
+    }
+    if cfg.Encoding == "" {
+        cfg.Encoding = encoder.JsonEncoding
+    }
+    ec, err := fc.EncoderConfig.build()
+    if err != nil {
+        return cfg, err
+    }
+    cfg.EncoderConfig = ec
+    if fc.Sampling != nil {
+        cfg.Sampling, err = fc.Sampling.build()
+        if err != nil {
+            return cfg, err
+        }
+    }
+    return cfg, nil
+}
+
+func (sc *SamplingFileConfig) build() (*config.SamplingConfig, error) {
+    cfg := &config.SamplingConfig{
+        Initial:    sc.Initial,
+        Thereafter: sc.Thereafter,
+    }
+    if sc.Tick != "" {
+        tick, err := time.ParseDuration(sc.Tick)
+        if err != nil {
+            return nil, err
+        }
+        cfg.Tick = tick
+    }
+    if sc.ExemptLevels != nil {
+        cfg.ExemptLevels = make([]level.Level, 0, len(sc.ExemptLevels))
+        for _, name := range sc.ExemptLevels {
+            lvl, ok := level.Name2Level(name)
+            if !ok {
+                return nil, fmt.Errorf("unknown sampling exempt level %q", name)
+            }
+            cfg.ExemptLevels = append(cfg.ExemptLevels, lvl)
+        }
+    }
+    return cfg, nil
+}
+
+// build 创建输出源对应的 Sink，返回的 closer 不为空时，需要由调用方负责关闭
+func (oc *OutputConfig) build() (config.Sink, io.Closer, error) {
+    sink := config.Sink{Encoding: oc.Encoding}
+    if sink.Encoding == "" && strings.ToLower(oc.Type) == OutputGelf {
+        sink.Encoding = encoder.GelfEncoding
+    }
+    if oc.EncoderConfig != nil {
+        ec, err := oc.EncoderConfig.build()
+        if err != nil {
+            return sink, nil, err
+        }
+        sink.EncoderConfig = &ec
+    }
+    if oc.Level != nil {
+        sink.Level = *oc.Level
+    }
+    if oc.MaxLevel != nil {
+        sink.MaxLevel = *oc.MaxLevel
+    }
+
+    ws, closer, err := oc.open()
+    if err != nil {
+        return sink, closer, err
+    }
+    sink.Writer = ws
+    return sink, closer, nil
+}
+
+func (oc *OutputConfig) open() (writer.WriteSyncer, io.Closer, error) {
+    switch strings.ToLower(oc.Type) {
+    case OutputStdout:
+        return writer.Lock(os.Stdout), nil, nil
+    case OutputStderr:
+        return writer.Lock(os.Stderr), nil, nil
+    case OutputFile:
+        if oc.Path == "" {
+            return nil, nil, fmt.Errorf("empty file path")
+        }
+        f, err := os.OpenFile(oc.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+        if err != nil {
+            return nil, nil, err
+        }
+        return writer.Lock(f), f, nil
+    case OutputSyslog:
+        if oc.Addr == "" {
+            return nil, nil, fmt.Errorf("empty syslog addr")
+        }
+        return oc.Syslog.open(oc.Addr, oc.Facility)
+    case OutputFlume:
+        if oc.Flume == nil {
+            return nil, nil, fmt.Errorf("empty flume config")
+        }
+        return oc.Flume.open()
+    case OutputGelf:
+        if oc.Addr == "" {
+            return nil, nil, fmt.Errorf("empty gelf addr")
+        }
+        return oc.Gelf.open(oc.Addr)
+    default:
+        return nil, nil, fmt.Errorf("unknown output type %q", oc.Type)
+    }
+}
+
+func (sc *SyslogOutputConfig) open(addr, facility string) (writer.WriteSyncer, io.Closer, error) {
+    if sc == nil {
+        sc = &SyslogOutputConfig{}
+    }
+    network := strings.ToLower(sc.Network)
+    if network == "" {
+        network = syslog.NetworkTCP
+    }
+    var opts []syslog.OptionFunc
+    if facility != "" {
+        f, err := syslog.ParseFacility(facility)
+        if err != nil {
+            return nil, nil, err
+        }
+        opts = append(opts, syslog.Facility(f))
+    }
+    if sc.CAFile != "" || sc.CertFile != "" || sc.KeyFile != "" {
+        cfg, err := syslog.NewTLSConfig(sc.CAFile, sc.CertFile, sc.KeyFile)
+        if err != nil {
+            return nil, nil, err
+        }
+        opts = append(opts, syslog.TLS(cfg))
+    }
+    if sc.MaxDatagramSize > 0 {
+        opts = append(opts, syslog.MaxDatagramSize(sc.MaxDatagramSize))
+    }
+    if sc.BufferDir != "" {
+        opts = append(opts, syslog.BufferDir(sc.BufferDir))
+    }
+    durations := []struct {
+        value  string
+        option func(time.Duration) syslog.OptionFunc
+    }{
+        {sc.Timeout, syslog.Timeout},
+        {sc.ConnLifeTime, syslog.ConnLifeTime},
+    }
+    for _, d := range durations {
+        if d.value == "" {
+            continue
+        }
+        t, err := time.ParseDuration(d.value)
+        if err != nil {
+            return nil, nil, err
+        }
+        opts = append(opts, d.option(t))
+    }
+    if sc.CommitBufferSize > 0 {
+        opts = append(opts, syslog.CommitBufferSize(sc.CommitBufferSize))
+    }
+    if sc.CacheQuota > 0 {
+        opts = append(opts, syslog.CacheQuota(sc.CacheQuota))
+    }
+    if sc.Overflow != "" || sc.BlockTimeout != "" {
+        policy, err := syslog.ParseOverflowPolicy(sc.Overflow)
+        if err != nil {
+            return nil, nil, err
+        }
+        var blockTimeout time.Duration
+        if sc.BlockTimeout != "" {
+            if blockTimeout, err = time.ParseDuration(sc.BlockTimeout); err != nil {
+                return nil, nil, err
+            }
+        }
+        opts = append(opts, syslog.Overflow(policy, blockTimeout))
+    }
+    if sc.Gzip {
+        opts = append(opts, syslog.Gzip())
+    }
+    if len(sc.Endpoints) > 0 || sc.Policy != "" {
+        policy, err := syslog.ParsePolicy(sc.Policy)
+        if err != nil {
+            return nil, nil, err
+        }
+        endpoints := make([]syslog.Endpoint, 0, len(sc.Endpoints))
+        for _, ep := range sc.Endpoints {
+            if ep.Addr == "" {
+                return nil, nil, fmt.Errorf("empty syslog endpoint addr")
+            }
+            endpoints = append(endpoints, syslog.Endpoint{Addr: ep.Addr, Weight: ep.Weight})
+        }
+        opts = append(opts, syslog.Endpoints(policy, endpoints...))
+    }
+    if sc.HealthCheckInterval != "" || sc.MaxBackoff != "" {
+        var interval, maxBackoff time.Duration
+        var err error
+        if sc.HealthCheckInterval != "" {
+            if interval, err = time.ParseDuration(sc.HealthCheckInterval); err != nil {
+                return nil, nil, err
+            }
+        }
+        if sc.MaxBackoff != "" {
+            if maxBackoff, err = time.ParseDuration(sc.MaxBackoff); err != nil {
+                return nil, nil, err
+            }
+        }
+        opts = append(opts, syslog.HealthCheck(interval, maxBackoff))
+    }
+    if sc.FailureThreshold != 0 || sc.OpenTimeout != "" || sc.MaxOpenTimeout != "" {
+        cfg := syslog.BreakerConfig{FailureThreshold: sc.FailureThreshold}
+        var err error
+        if sc.OpenTimeout != "" {
+            if cfg.OpenTimeout, err = time.ParseDuration(sc.OpenTimeout); err != nil {
+                return nil, nil, err
+            }
+        }
+        if sc.MaxOpenTimeout != "" {
+            if cfg.MaxOpenTimeout, err = time.ParseDuration(sc.MaxOpenTimeout); err != nil {
+                return nil, nil, err
+            }
+        }
+        opts = append(opts, syslog.CircuitBreaker(cfg))
+    }
+
+    w, err := syslog.DialByLevel(2, network, addr, "INFO", opts...)
+    if err != nil {
+        // DialByLevel 出错时已经自行释放资源
+        return nil, nil, err
+    }
+    return w, w, nil
+}
+
+func (gc *GelfOutputConfig) open(addr string) (writer.WriteSyncer, io.Closer, error) {
+    if gc == nil {
+        gc = &GelfOutputConfig{}
+    }
+    network := strings.ToLower(gc.Network)
+    if network == "" {
+        network = "tcp"
+    }
+    var opts []gelf.OptionFunc
+    switch strings.ToLower(gc.Compression) {
+    case "", "none":
+    case "gzip":
+        opts = append(opts, gelf.Compression(gelf.CompressGzip))
+    case "zlib":
+        opts = append(opts, gelf.Compression(gelf.CompressZlib))
+    default:
+        return nil, nil, fmt.Errorf("unknown gelf compression %q", gc.Compression)
+    }
+    if gc.ChunkSize > 0 {
+        opts = append(opts, gelf.ChunkSize(gc.ChunkSize))
+    }
+
+    w, err := gelf.Dial(network, addr, opts...)
+    if err != nil {
+        return nil, nil, err
+    }
+    return w, w, nil
+}
+
+func (fo *FlumeOutputConfig) open() (writer.WriteSyncer, io.Closer, error) {
+    var sendingMode flumefilewriter.SendMode
+    switch strings.ToLower(fo.SendingMode) {
+    case "", "multiplexing":
+        sendingMode = flumefilewriter.Multiplexing
+    case "replicating":
+        sendingMode = flumefilewriter.Replicating
+    default:
+        return nil, nil, fmt.Errorf("unknown flume sendingMode %q", fo.SendingMode)
+    }
+
+    var selectorType flumefilewriter.SelectorType
+    switch strings.ToLower(fo.SelectorType) {
+    case "", "es":
+        selectorType = flumefilewriter.ES
+    case "hdfs1":
+        selectorType = flumefilewriter.HDFS1
+    case "hdfs2":
+        selectorType = flumefilewriter.HDFS2
+    default:
+        return nil, nil, fmt.Errorf("unknown flume selectorType %q", fo.SelectorType)
+    }
+
+    var opts []flumefilewriter.DialOption
+    durations := []struct {
+        value  string
+        option func(time.Duration) flumefilewriter.DialOption
+    }{
+        {fo.WriteFileTime, flumefilewriter.WriteFileTime},
+        {fo.FlashSliceDirTime, flumefilewriter.FlashSliceDirTime},
+        {fo.MoveTempFileTime, flumefilewriter.MoveTempFileTime},
+    }
+    for _, d := range durations {
+        if d.value == "" {
+            continue
+        }
+        t, err := time.ParseDuration(d.value)
+        if err != nil {
+            return nil, nil, err
+        }
+        opts = append(opts, d.option(t))
+    }
+    if fo.MaxFileCount > 0 {
+        opts = append(opts, flumefilewriter.MaxFileCount(fo.MaxFileCount))
+    }
+    if fo.MaxLogCount > 0 {
+        opts = append(opts, flumefilewriter.MaxLogCount(fo.MaxLogCount))
+    }
+    if fo.MoveTempFile {
+        opts = append(opts, flumefilewriter.MoveTempFile())
+    }
+    if fo.Location != "" {
+        loc, err := time.LoadLocation(fo.Location)
+        if err != nil {
+            return nil, nil, err
+        }
+        opts = append(opts, flumefilewriter.Location(loc))
+    }
+    if fo.Gzip {
+        opts = append(opts, flumefilewriter.Gzip(fo.GzipLevel))
+    }
+
+    wh, err := flumefilewriter.NewWriteHandle(fo.RootPath, fo.TempFilePath, fo.TableName,
+        sendingMode, selectorType, fo.IsFile, fo.IsJson, opts...)
+    if err != nil {
+        return nil, nil, err
+    }
+    return wh, wh, nil
+}
+
+func (ec EncoderFileConfig) build() (config.EncoderConfig, error) {
+    var cfg config.EncoderConfig
+    switch strings.ToLower(ec.Preset) {
+    case "", "production":
+        cfg = NewProductionEncoderConfig()
+    case "development":
+        cfg = NewDevelopmentEncoderConfig()
+    case "es":
+        cfg = NewProductionEncoderWithESConfig()
+    case "ecs":
+        cfg = encoder.NewECSEncoderConfig()
+    default:
+        return cfg, fmt.Errorf("unknown encoder preset %q", ec.Preset)
+    }
+
+    keys := []struct {
+        value *string
+        key   *string
+    }{
+        {ec.TimeKey, &cfg.TimeKey},
+        {ec.LevelKey, &cfg.LevelKey},
+        {ec.NameKey, &cfg.NameKey},
+        {ec.CallerKey, &cfg.CallerKey},
+        {ec.MessageKey, &cfg.MessageKey},
+        {ec.StacktraceKey, &cfg.StacktraceKey},
+        {ec.LineEnding, &cfg.LineEnding},
+    }
+    for _, k := range keys {
+        if k.value != nil {
+            *k.key = *k.value
+        }
+    }
+
+    switch strings.ToLower(ec.LevelEncoder) {
+    case "":
+    case "capital":
+        cfg.EncodeLevel = encoder.CapitalLevelEncoder
+    case "lowercase":
+        cfg.EncodeLevel = encoder.LowercaseLevelEncoder
+    default:
+        return cfg, fmt.Errorf("unknown levelEncoder %q", ec.LevelEncoder)
+    }
+
+    switch strings.ToLower(ec.TimeEncoder) {
+    case "":
+    case "rfc3339":
+        cfg.EncodeTime = encoder.RFC3339TimeEncoder
+    case "iso8601":
+        cfg.EncodeTime = encoder.ISO8601TimeEncoder
+    case "epoch":
+        cfg.EncodeTime = encoder.EpochTimeIntEncoder
+    case "epochmillis":
+        cfg.EncodeTime = encoder.EpochMillisTimeIntEncoder
+    default:
+        return cfg, fmt.Errorf("unknown timeEncoder %q", ec.TimeEncoder)
+    }
+
+    switch strings.ToLower(ec.DurationEncoder) {
+    case "":
+    case "string":
+        cfg.EncodeDuration = encoder.StringDurationEncoder
+    case "seconds":
+        cfg.EncodeDuration = zapcore.SecondsDurationEncoder
+    case "nanos":
+        cfg.EncodeDuration = zapcore.NanosDurationEncoder
+    default:
+        return cfg, fmt.Errorf("unknown durationEncoder %q", ec.DurationEncoder)
+    }
+
+    switch strings.ToLower(ec.CallerEncoder) {
+    case "":
+    case "short":
+        cfg.EncodeCaller = encoder.ShortCallerEncoder
+    case "full":
+        cfg.EncodeCaller = zapcore.FullCallerEncoder
+    default:
+        return cfg, fmt.Errorf("unknown callerEncoder %q", ec.CallerEncoder)
+    }
+    return cfg, nil
+}
+
+// closeAll 关闭输出源，忽略错误
+func closeAll(closers []io.Closer) {
+    for _, c := range closers {
+        _ = c.Close()
+    }
+}
@@ -0,0 +1,182 @@
+package log
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// loadOutput 读取输出文件内容
+func loadOutput(t *testing.T, path string) string {
+    t.Helper()
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return string(data)
+}
+
+func TestNewFromFile(t *testing.T) {
+    dir := t.TempDir()
+    all, errs, info := filepath.Join(dir, "all.log"), filepath.Join(dir, "error.log"), filepath.Join(dir, "info.log")
+    cfg := `
+level: info
+encoderConfig:
+  messageKey: m
+  timeKey: ""
+initialFields:
+  app: demo
+outputs:
+  - type: file
+    path: ` + all + `
+  - type: file
+    path: ` + errs + `
+    level: error
+    encoding: console
+    encoderConfig:
+      preset: development
+  - type: file
+    path: ` + info + `
+    maxLevel: warn
+`
+    cfgPath := filepath.Join(dir, "log.yaml")
+    if err := ioutil.WriteFile(cfgPath, []byte(cfg), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    l, err := NewFromFile(cfgPath)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.Debug("debug")
+    l.Info("info")
+    l.Error("boom")
+    if err = l.Sync(); err != nil {
+        t.Fatal(err)
+    }
+
+    data := loadOutput(t, all)
+    if strings.Contains(data, `"debug"`) {
+        t.Errorf("entry below the logger level written: %q", data)
+    }
+    for _, s := range []string{`"m":"info"`, `"m":"boom"`, `"app":"demo"`} {
+        if !strings.Contains(data, s) {
+            t.Errorf("missing %s in %q", s, data)
+        }
+    }
+    if strings.Contains(data, `"generated_time"`) {
+        t.Errorf("timeKey set to empty string should drop the time: %q", data)
+    }
+
+    data = loadOutput(t, errs)
+    if strings.Contains(data, "info") {
+        t.Errorf("output level not applied: %q", data)
+    }
+    if !strings.Contains(data, "ERROR\tboom") {
+        t.Errorf("output should use its own encoder: %q", data)
+    }
+
+    data = loadOutput(t, info)
+    if !strings.Contains(data, `"m":"info"`) || strings.Contains(data, "boom") {
+        t.Errorf("output maxLevel not applied: %q", data)
+    }
+}
+
+func TestFileConfigSampling(t *testing.T) {
+    dir := t.TempDir()
+    path := filepath.Join(dir, "sampled.log")
+    fc, err := ParseFileConfig([]byte(`
+sampling:
+  initial: 1
+  thereafter: 0
+  tick: 1m
+outputs:
+  - type: file
+    path: `+path+`
+`), "yaml")
+    if err != nil {
+        t.Fatal(err)
+    }
+    l, err := fc.Build()
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 5; i++ {
+        l.Info("repeated")
+        // CRITICAL 默认不参与采样
+        l.Critical("critical")
+    }
+    _ = l.Sync()
+
+    data := loadOutput(t, path)
+    if n := strings.Count(data, `"repeated"`); n != 1 {
+        t.Errorf("got %d sampled entries, want 1", n)
+    }
+    if n := strings.Count(data, `"critical"`); n != 5 {
+        t.Errorf("got %d critical entries, want all 5", n)
+    }
+}
+
+func TestParseFileConfigFormats(t *testing.T) {
+    fromYAML, err := ParseFileConfig([]byte("id: a\nlevel: error\noutputs:\n  - type: file\n    path: a.log\n    maxLevel: critical\n"), ".yml")
+    if err != nil {
+        t.Fatal(err)
+    }
+    fromJSON, err := ParseFileConfig([]byte(`{"id":"a","level":"ERROR","outputs":[{"type":"file","path":"a.log","maxLevel":"CRITICAL"}]}`), "JSON")
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, fc := range []*FileConfig{fromYAML, fromJSON} {
+        if fc.ID != "a" || fc.Level.Level() != ERROR || len(fc.Outputs) != 1 || fc.Outputs[0].Path != "a.log" ||
+            fc.Outputs[0].MaxLevel == nil || fc.Outputs[0].MaxLevel.Level() != CRITICAL {
+            t.Errorf("unexpected config %+v", fc)
+        }
+    }
+
+    // 空字符串和未设置需要区分开
+    fc, err := ParseFileConfig([]byte("encoderConfig:\n  callerKey: \"\"\n"), "yaml")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if fc.EncoderConfig.CallerKey == nil || *fc.EncoderConfig.CallerKey != "" {
+        t.Errorf("callerKey should be set to an empty string, got %v", fc.EncoderConfig.CallerKey)
+    }
+    if fc.EncoderConfig.MessageKey != nil {
+        t.Errorf("messageKey should be unset, got %q", *fc.EncoderConfig.MessageKey)
+    }
+
+    if _, err = ParseFileConfig([]byte("name = 'a'"), "toml"); err == nil {
+        t.Error("unsupported format should fail")
+    }
+    if _, err = ParseFileConfig([]byte("level: loud\n"), "yaml"); err == nil {
+        t.Error("unknown level should fail")
+    }
+}
+
+func TestFileConfigBuildErrors(t *testing.T) {
+    tests := []struct {
+        config string
+        err    string
+    }{
+        {"outputs:\n  - type: kafka\n", `output[0] kafka: unknown output type "kafka"`},
+        {"outputs:\n  - type: stdout\n  - type: file\n", "output[1] file: empty file path"},
+        {"outputs:\n  - type: syslog\n", "empty syslog addr"},
+        {"outputs:\n  - type: flume\n", "empty flume config"},
+        {"outputs:\n  - type: gelf\n", "empty gelf addr"},
+        {"encoderConfig:\n  preset: fancy\n", `unknown encoder preset "fancy"`},
+        {"encoderConfig:\n  timeEncoder: unix\n", `unknown timeEncoder "unix"`},
+        {"outputs:\n  - type: stdout\n    encoderConfig:\n      callerEncoder: long\n", `unknown callerEncoder "long"`},
+        {"sampling:\n  tick: soon\n", `invalid duration "soon"`},
+        {"sampling:\n  exemptLevels: [LOUD]\n", `unknown sampling exempt level "LOUD"`},
+    }
+    for _, tt := range tests {
+        fc, err := ParseFileConfig([]byte(tt.config), "yaml")
+        if err != nil {
+            t.Fatal(err)
+        }
+        if _, err = fc.Build(); err == nil || !strings.Contains(err.Error(), tt.err) {
+            t.Errorf("Build() error = %v, want %q", err, tt.err)
+        }
+    }
+}
@@ -2,10 +2,12 @@ package log
 
 import (
     "fmt"
+    "io"
     "io/ioutil"
     "os"
     "runtime"
     "sort"
+    "strings"
     "time"
 
     "github.com/weitrue/log/config"
@@ -15,10 +17,13 @@ import (
     "github.com/weitrue/log/field"
     "github.com/weitrue/log/level"
     "github.com/weitrue/log/writer"
+    "go.uber.org/multierr"
 )
 
 var (
     DefaultLevel = "INFO"
+    // ExitFunc Fatal 系列函数记录日志后调用的退出函数，测试时可以替换，避免程序退出
+    ExitFunc = os.Exit
 )
 
 
@@ -34,6 +39,12 @@ type IBaseLogger interface {
     Error(msg string, fields ...field.Field)
     // Critical 异常信息记录
     Critical(msg string, fields ...field.Field)
+    // Panic 记录日志后触发 panic
+    Panic(msg string, fields ...field.Field)
+    Panicf(template string, args ...interface{})
+    // Fatal 记录日志后调用 ExitFunc 退出程序
+    Fatal(msg string, fields ...field.Field)
+    Fatalf(template string, args ...interface{})
     // Fixed 固定信息记录，用于服务运行状态或者输出加载配置信息等等。
     Fixed(msg string, fields ...field.Field)
 }
@@ -44,17 +55,31 @@ type ILogger interface {
 
     // Sync 同步操作,刷新缓冲区，建议退出或删除 logger 前调用一次。
     Sync() error
-    // Named 日志对象命名
-    Named(s string)
+    // Named 返回以 "." 连接名称的子日志对象
+    Named(s string) *Logger
     // With 附加信息到日志对象，并返回
     With(fields ...field.Field) *Logger
 }
 
 func New(cfg config.Config, options ...Option) (*Logger, error) {
-    // 创建 数据编码器
-    enc, err := encoder.NewEncoder(cfg.Encoding, cfg.EncoderConfig)
-    if err != nil {
-        return nil, err
+    cores := make([]core.Core, 0, len(cfg.Sinks)+1)
+    if cfg.Writer != nil || len(cfg.Sinks) == 0 {
+        // 创建 数据编码器
+        enc, err := encoder.NewEncoder(cfg.Encoding, cfg.EncoderConfig)
+        if err != nil {
+            return nil, err
+        }
+        c := core.NewCore(enc, cfg.Writer, allLevelEnabler, cfg.CustomLevelEnabler)
+        if c = newNamedLevelCore(c, cfg.Level); c != nil {
+            cores = append(cores, c)
+        }
+    }
+    for i := range cfg.Sinks {
+        c, err := newSinkCore(cfg, cfg.Sinks[i])
+        if err != nil {
+            return nil, fmt.Errorf("sinks[%d]: %v", i, err)
+        }
+        cores = append(cores, c)
     }
 
     // 开启 开发模式
@@ -77,11 +102,11 @@ func New(cfg config.Config, options ...Option) (*Logger, error) {
 
     // callerSkip +1 主要是因为这边 logger 套了一层 zap.Logger所以需要 +1
     options = append([]Option{AddCallerSkip(1),AddStacktrace(stackLevel)}, options...)
-    // if cfg.Sampling != nil {
-    //	options = append(options, WrapCore(func(core Core) Core {
-    //		return zapcore.NewSampler(core, time.Second, int(cfg.Sampling.Initial), int(cfg.Sampling.Thereafter))
-    //	}))
-    // }
+    if cfg.Sampling != nil {
+        options = append(options, WrapCore(func(c core.Core) core.Core {
+            return newSampler(c, *cfg.Sampling)
+        }))
+    }
 
     if len(cfg.InitialFields) > 0 {
         fs := make([]field.Field, 0, len(cfg.InitialFields))
@@ -96,17 +121,20 @@ func New(cfg config.Config, options ...Option) (*Logger, error) {
         options = append(options, Fields(fs...))
     }
 
-    var iw writer.WriteSyncer
+    var err error
+    var iCore core.Core
     var l *Logger
-    iw = cfg.Writer
-
-    iCore := core.NewCore(enc, iw, cfg.Level)
-    l = NewWithCore(iCore, options...)
-
-
-    if cfg.Name != ""{
-        l.Named(cfg.Name)
+    switch len(cores) {
+    case 0:
+    case 1:
+        iCore = cores[0]
+    default:
+        iCore = core.NewTee(cores...)
     }
+    l = NewWithCore(iCore, options...)
+    l.Name = cfg.Name
+    l.atomicLevel = cfg.Level
+    l.outputs = describeOutputs(cfg)
     // 注册 logger 到全局管理器中
     if cfg.ID != ""{
         l.ID = cfg.ID
@@ -116,6 +144,88 @@ func New(cfg config.Config, options ...Option) (*Logger, error) {
     return l, err
 }
 
+// describeOutputs 返回 cfg 中所有输出源的描述信息，Sink 单独设置了等级范围时附加在描述信息后面
+func describeOutputs(cfg config.Config) []string {
+    outputs := make([]string, 0, len(cfg.Sinks)+1)
+    if cfg.Writer != nil {
+        outputs = append(outputs, writer.Describe(cfg.Writer))
+    }
+    for _, sink := range cfg.Sinks {
+        desc := writer.Describe(sink.Writer)
+        if !sink.Level.IsZero() || !sink.MaxLevel.IsZero() {
+            var minLevel, maxLevel string
+            if !sink.Level.IsZero() {
+                minLevel = sink.Level.String()
+            }
+            if !sink.MaxLevel.IsZero() {
+                maxLevel = sink.MaxLevel.String()
+            }
+            desc = fmt.Sprintf("%s[%s-%s]", desc, minLevel, maxLevel)
+        }
+        outputs = append(outputs, desc)
+    }
+    return outputs
+}
+
+// newSinkCore 根据 Sink 配置创建 core，未设置的配置使用 cfg 中的值
+func newSinkCore(cfg config.Config, sink config.Sink) (core.Core, error) {
+    if sink.Writer == nil {
+        return nil, fmt.Errorf("empty writer")
+    }
+    encoding := sink.Encoding
+    if encoding == "" {
+        encoding = cfg.Encoding
+    }
+    ec := cfg.EncoderConfig
+    if sink.EncoderConfig != nil {
+        ec = *sink.EncoderConfig
+    }
+    enc, err := encoder.NewEncoder(encoding, ec)
+    if err != nil {
+        return nil, err
+    }
+
+    var maxEnabler level.LevelEnablerFunc
+    if !sink.MaxLevel.IsZero() {
+        maxLevel := sink.MaxLevel
+        maxEnabler = func(lvl level.Level) bool {
+            return lvl <= maxLevel.Level()
+        }
+    }
+    if !sink.Level.IsZero() {
+        return core.NewCore(enc, sink.Writer, sink.Level, maxEnabler, cfg.CustomLevelEnabler), nil
+    }
+    // 使用 Config.Level 的输出源，支持通过 SetNamedLevel 按名称调整等级
+    c := core.NewCore(enc, sink.Writer, allLevelEnabler, maxEnabler, cfg.CustomLevelEnabler)
+    return newNamedLevelCore(c, cfg.Level), nil
+}
+
+// allLevelEnabler 允许所有等级，用于由外层 core 判断等级的场景
+var allLevelEnabler = level.LevelEnablerFunc(func(level.Level) bool { return true })
+
+// DefaultSamplingExemptLevels 默认不参与采样的日志等级
+var DefaultSamplingExemptLevels = []level.Level{CRITICAL, PANIC, FATAL, FIXED}
+
+func newSampler(c core.Core, cfg config.SamplingConfig) core.Core {
+    tick := cfg.Tick
+    if tick <= 0 {
+        tick = time.Second
+    }
+    exemptLevels := cfg.ExemptLevels
+    if exemptLevels == nil {
+        exemptLevels = DefaultSamplingExemptLevels
+    }
+    exempt := level.LevelEnablerFunc(func(lvl level.Level) bool {
+        for _, l := range exemptLevels {
+            if l == lvl {
+                return true
+            }
+        }
+        return false
+    })
+    return core.NewSampler(c, tick, cfg.Initial, cfg.Thereafter, exempt, cfg.Hook)
+}
+
 // NewWithCore 通过 自定义 core 创建 logger
 // 自定义 core 可以实现 输出不同等级日志到不同输入源中
 func NewWithCore(core core.Core, options ...Option) *Logger {
@@ -169,21 +279,27 @@ type Logger struct {
     callerSkip int
     // Location 日志时区，在创建时，注意该配置需要设置，否则将会出现异常。
     Location *time.Location
+    // closers 调用 Close 时需要一并关闭的输出源，比如 syslog、flume 的 writer
+    closers []io.Closer
+    // atomicLevel 创建时使用的 Config.Level，通过 New 创建时才有值
+    atomicLevel level.AtomicLevel
+    // outputs 输出源的描述信息，通过 New 创建时才有值
+    outputs []string
 }
 
-func (l *Logger) Named(s string){
-
-    // if s == "" {
-    //     return l
-    // }
-    // log := l.clone()
-    // if log.Name == "" {
-    //     log.Name = s
-    // } else {
-    //     log.Name = strings.Join([]string{log.Name, s}, ".")
-    // }
-    l.Name = s
-    // return log
+// Named 返回名称为 "l.Name.s" 的子 logger，l.Name 为空时名称为 s，s 为空时返回 l 本身。
+// 可以通过 SetNamedLevel 调整某个名称以及其子 logger 的日志等级。
+func (l *Logger) Named(s string) *Logger {
+    if s == "" {
+        return l
+    }
+    log := l.clone()
+    if log.Name == "" {
+        log.Name = s
+    } else {
+        log.Name = strings.Join([]string{log.Name, s}, ".")
+    }
+    return log
 }
 func (l *Logger) With(fields ...field.Field) *Logger {
     if len(fields) == 0 {
@@ -241,6 +357,50 @@ func (l *Logger) Critical(msg string, fields ...field.Field) {
     return
 }
 
+// Panic 记录日志，并同步当前 logger 以及所有已注册的 logger 后触发 panic。
+// 即使 PANIC 等级的日志没有开启，也会触发 panic。
+func (l *Logger) Panic(msg string, fields ...field.Field) {
+    if ce := l.Check(PANIC, msg); ce != nil {
+        ce.Write(fields...)
+    }
+    _ = l.Sync()
+    _ = SyncAll()
+    panic(msg)
+}
+
+// Panicf uses fmt.Sprintf to log a templated message, then panics.
+func (l *Logger) Panicf(template string, args ...interface{}) {
+    msg := fmt.Sprintf(template, args...)
+    if ce := l.Check(PANIC, msg); ce != nil {
+        ce.Write()
+    }
+    _ = l.Sync()
+    _ = SyncAll()
+    panic(msg)
+}
+
+// Fatal 记录日志，并同步当前 logger 以及所有已注册的 logger 后调用 ExitFunc(1) 退出程序。
+// 即使 FATAL 等级的日志没有开启，也会退出程序。
+func (l *Logger) Fatal(msg string, fields ...field.Field) {
+    if ce := l.Check(FATAL, msg); ce != nil {
+        ce.Write(fields...)
+    }
+    _ = l.Sync()
+    _ = SyncAll()
+    ExitFunc(1)
+}
+
+// Fatalf uses fmt.Sprintf to log a templated message, then calls ExitFunc(1).
+func (l *Logger) Fatalf(template string, args ...interface{}) {
+    msg := fmt.Sprintf(template, args...)
+    if ce := l.Check(FATAL, msg); ce != nil {
+        ce.Write()
+    }
+    _ = l.Sync()
+    _ = SyncAll()
+    ExitFunc(1)
+}
+
 // Fixed 固定消息，主要用于输出服务运行日志等等，等级最高，且不输出堆栈信息
 func (l *Logger) Fixed(msg string, fields ...field.Field) {
     if ce := l.Check(FIXED, msg); ce != nil {
@@ -254,6 +414,29 @@ func (l *Logger) Fixed(msg string, fields ...field.Field) {
 func (l *Logger) Sync() error {
     return l.core.Sync()
 }
+// Close 刷新缓冲区，并关闭通过 Closers 设置的输出源，Sync 的错误会被忽略。
+// 关闭后 logger 不能再继续使用，通过 With 复制出来的 logger 共享相同的输出源。
+func (l *Logger) Close() error {
+    // https://github.com/uber-go/zap/issues/328
+    _ = l.Sync()
+    var err error
+    for _, c := range l.closers {
+        err = multierr.Append(err, c.Close())
+    }
+    return err
+}
+
+// Level 返回创建 logger 时使用的 Config.Level，可以通过 SetLevel 动态调整等级。
+// 不是通过 New 创建的 logger 返回的 AtomicLevel 未初始化，参考 AtomicLevel.IsZero
+func (l *Logger) Level() level.AtomicLevel {
+    return l.atomicLevel
+}
+
+// Outputs 返回 logger 输出源的描述信息，比如 stdout、file:/var/log/app.log、syslog:v2-127.0.0.1:514
+func (l *Logger) Outputs() []string {
+    return append([]string(nil), l.outputs...)
+}
+
 // Core returns the Logger's underlying zapcore.Core.
 func (l *Logger) Core() core.Core {
     return l.core
@@ -0,0 +1,61 @@
+package log
+
+import (
+    "bytes"
+    "testing"
+
+    "github.com/weitrue/log/config"
+    "github.com/weitrue/log/core"
+    "github.com/weitrue/log/encoder"
+    "github.com/weitrue/log/writer"
+)
+
+// syncCounter 记录 Logger.Sync 的调用次数，写入 ERROR 以上的日志时 core 内部的同步不计算在内
+type syncCounter struct {
+    core.Core
+    syncs int
+}
+
+func (c *syncCounter) Sync() error {
+    c.syncs++
+    return c.Core.Sync()
+}
+
+func TestTerminalMethodsSyncLogger(t *testing.T) {
+    exit := ExitFunc
+    defer func() { ExitFunc = exit }()
+    ExitFunc = func(int) {}
+
+    tests := []struct {
+        name string
+        fn   func(l *Logger)
+    }{
+        {"Panic", func(l *Logger) { l.Panic("bye") }},
+        {"Panicf", func(l *Logger) { l.Panicf("%s", "bye") }},
+        {"Fatal", func(l *Logger) { l.Fatal("bye") }},
+        {"Fatalf", func(l *Logger) { l.Fatalf("%s", "bye") }},
+        {"WLogger.Panic", func(l *Logger) { l.Switch().Panic("bye") }},
+        {"WLogger.Panicf", func(l *Logger) { l.Switch().Panicf("%s", "bye") }},
+        {"WLogger.Fatal", func(l *Logger) { l.Switch().Fatal("bye") }},
+        {"WLogger.Fatalf", func(l *Logger) { l.Switch().Fatalf("%s", "bye") }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            // 没有注册到全局管理器的 logger，SyncAll 不会同步
+            var buf bytes.Buffer
+            enc := encoder.NewJSONEncoder(config.EncoderConfig{MessageKey: "msg"})
+            c := &syncCounter{Core: core.NewCore(enc, writer.AddSync(&buf), DEBUG)}
+            l := NewWithCore(c)
+            func() {
+                defer func() { _ = recover() }()
+                tt.fn(l)
+            }()
+            if !bytes.Contains(buf.Bytes(), []byte(`"msg":"bye"`)) {
+                t.Errorf("entry not written: %q", buf.String())
+            }
+            if c.syncs == 0 {
+                t.Error("logger not synced")
+            }
+        })
+    }
+}
@@ -2,24 +2,37 @@ package log
 
 import (
     "errors"
+    "io"
+    "sort"
+    "strings"
+    "sync"
     "sync/atomic"
+
+    "github.com/weitrue/log/core"
+    "github.com/weitrue/log/entry"
+    "github.com/weitrue/log/field"
+    "github.com/weitrue/log/level"
+    "go.uber.org/multierr"
 )
 
 var (
+    // manager 全局管理器，存储 map[string]*Logger，修改时加锁复制后替换，读取时不需要加锁。
+    // 已经存储的 map 不会再被修改，读取方可以放心遍历。
     manager   atomic.Value
+    managerMu sync.Mutex
 
     ErrExistedLogger = errors.New("existed logger")
     ErrEmptyLoggerID = errors.New("empty logger ID")
 )
 
+func loadManager() map[string]*Logger {
+    c, _ := manager.Load().(map[string]*Logger)
+    return c
+}
+
 // GetLogger 获取全局 logger
 func GetLogger(logID string)(*Logger,bool){
-    i := manager.Load()
-    c, ok := i.(map[string]*Logger)
-    if !ok{
-        return nil,ok
-    }
-    l,ok := c[logID]
+    l,ok := loadManager()[logID]
     return l,ok
 }
 
@@ -27,21 +40,19 @@ func registerLogger(l *Logger,force bool)error{
     if l.ID == ""{
         return ErrEmptyLoggerID
     }
-    i := manager.Load()
-    oldManager, ok := i.(map[string]*Logger)
-    newManager := make(map[string]*Logger,len(oldManager)+1)
-    if ok{
-        for k,v := range oldManager{
-            newManager[k]=v
-            delete(oldManager,k)
-        }
-    }
-
-    l2,existed := newManager[l.ID]
+    managerMu.Lock()
+    defer managerMu.Unlock()
 
+    oldManager := loadManager()
+    l2,existed := oldManager[l.ID]
     if existed && !force && l2 != l{
        return ErrExistedLogger
     }
+
+    newManager := make(map[string]*Logger,len(oldManager)+1)
+    for k,v := range oldManager{
+        newManager[k]=v
+    }
     newManager[l.ID] = l
     // 如果是 root logger，则默认更新全局log
     if l.ID == DefaultName{
@@ -53,32 +64,251 @@ func registerLogger(l *Logger,force bool)error{
 
 
 // RegisterLogger 注册 logger 到全局管理器中，方便通过 GetLogger 获取logger
-// 请勿并发操作该函数，避免 manager 出现logger对象存储失败
 func RegisterLogger(l *Logger)(error){
     return registerLogger(l,false)
 }
 
 // ReplaceLogger 替换 全局管理器中的logger 对象
-// 请勿并发操作该函数，避免 manager 出现logger对象存储失败
 func ReplaceLogger(l *Logger)error{
     return registerLogger(l,true)
 }
 
 
 // DeRegisterLogger 注销 全局管理器中 的 logger
-// 请勿并发操作该函数，避免 manager 出现logger对象存储失败
 func DeRegisterLogger(l *Logger){
-    i := manager.Load()
+    managerMu.Lock()
+    defer managerMu.Unlock()
 
-    oldManager, ok := i.(map[string]*Logger)
-    newManager := make(map[string]*Logger,len(oldManager)+1)
-    if ok{
-        for k,v := range oldManager{
-            if k != l.ID{
-                newManager[k]=v
-            }
-            delete(oldManager,k)
+    oldManager := loadManager()
+    if _,ok := oldManager[l.ID]; !ok{
+        return
+    }
+    newManager := make(map[string]*Logger,len(oldManager))
+    for k,v := range oldManager{
+        if k != l.ID{
+            newManager[k]=v
         }
     }
     manager.Store(newManager)
 }
+
+// ListLoggers 获取全局管理器中的所有 logger，按 ID 排序
+func ListLoggers() []*Logger {
+    c := loadManager()
+    ids := make([]string, 0, len(c))
+    for id := range c {
+        ids = append(ids, id)
+    }
+    sort.Strings(ids)
+    loggers := make([]*Logger, 0, len(ids))
+    for _, id := range ids {
+        loggers = append(loggers, c[id])
+    }
+    return loggers
+}
+
+// allLoggers 获取全局 logger 以及全局管理器中的所有 logger，相同的 logger 只返回一次
+func allLoggers() []ILogger {
+    loggers := make([]ILogger, 0, len(loadManager())+1)
+    seen := make(map[ILogger]struct{}, cap(loggers))
+    if gl, ok := g.Load().(ILogger); ok{
+        loggers = append(loggers, gl)
+        seen[gl] = struct{}{}
+    }
+    for _, l := range ListLoggers() {
+        if _, ok := seen[l]; !ok {
+            loggers = append(loggers, l)
+            seen[l] = struct{}{}
+        }
+    }
+    return loggers
+}
+
+// SyncAll 同步全局 logger 以及全局管理器中的所有 logger，返回所有同步错误。
+// 标准输出等输出源的 Sync 错误可以忽略，参考 https://github.com/uber-go/zap/issues/328
+func SyncAll() error {
+    var err error
+    for _, l := range allLoggers() {
+        err = multierr.Append(err, l.Sync())
+    }
+    return err
+}
+
+// CloseAll 关闭全局 logger 以及全局管理器中的所有 logger，参考 Logger.Close，
+// 通过 Closers 设置的 syslog、flume 等输出源会先写完缓存数据再关闭。
+// 一般在程序退出前调用，关闭后的 logger 仍然保留在全局管理器中，但不能再继续使用。
+func CloseAll() error {
+    var err error
+    for _, l := range allLoggers() {
+        if c, ok := l.(io.Closer); ok {
+            err = multierr.Append(err, c.Close())
+        } else {
+            err = multierr.Append(err, l.Sync())
+        }
+    }
+    return err
+}
+
+// namedLevels 按 logger 名称设置的日志等级，修改时复制后替换，读取时不需要加锁
+type namedLevels struct {
+    levels map[string]level.Level
+    // min 所有设置中的最低等级，用于快速判断是否可能有 logger 允许输出
+    min level.Level
+}
+
+var (
+    namedLevelsValue atomic.Value
+    namedLevelsMu    sync.Mutex
+)
+
+func loadNamedLevels() *namedLevels {
+    nl, _ := namedLevelsValue.Load().(*namedLevels)
+    return nl
+}
+
+func storeNamedLevels(levels map[string]level.Level) {
+    if len(levels) == 0 {
+        namedLevelsValue.Store((*namedLevels)(nil))
+        return
+    }
+    nl := &namedLevels{levels: levels, min: level.FixedLevel}
+    for _, lvl := range levels {
+        if lvl < nl.min {
+            nl.min = lvl
+        }
+    }
+    namedLevelsValue.Store(nl)
+}
+
+// SetNamedLevel 设置名称为 name 的 logger 以及其子 logger（通过 Named 创建，名称以 "name." 开头）的日志等级，
+// 子 logger 单独设置了等级时，以子 logger 的设置为准。
+// 例如设置 root.db 为 DEBUG 后，root.db、root.db.pool 都会输出 DEBUG 日志。
+// 只对通过 New 创建的 logger 中使用 Config.Level 的输出源生效，单独设置了 Level 的 Sink 不受影响。
+func SetNamedLevel(name string, lvl level.Level) {
+    namedLevelsMu.Lock()
+    defer namedLevelsMu.Unlock()
+
+    levels := make(map[string]level.Level)
+    if nl := loadNamedLevels(); nl != nil {
+        for k, v := range nl.levels {
+            levels[k] = v
+        }
+    }
+    levels[name] = lvl
+    storeNamedLevels(levels)
+}
+
+// UnsetNamedLevel 取消 SetNamedLevel 的设置，恢复使用上级名称的设置，或者 logger 本身的 Config.Level
+func UnsetNamedLevel(name string) {
+    namedLevelsMu.Lock()
+    defer namedLevelsMu.Unlock()
+
+    nl := loadNamedLevels()
+    if nl == nil {
+        return
+    }
+    if _, ok := nl.levels[name]; !ok {
+        return
+    }
+    levels := make(map[string]level.Level, len(nl.levels))
+    for k, v := range nl.levels {
+        if k != name {
+            levels[k] = v
+        }
+    }
+    storeNamedLevels(levels)
+}
+
+// NamedLevel 获取名称为 name 的 logger 生效的日志等级设置，
+// 依次查找 name 本身以及上级名称（比如 root.db.pool、root.db、root），都没有设置时返回 false
+func NamedLevel(name string) (level.Level, bool) {
+    return loadNamedLevels().lookup(name)
+}
+
+// exactNamedLevel 获取 SetNamedLevel 为 name 本身设置的等级，不查找上级名称
+func exactNamedLevel(name string) (level.Level, bool) {
+    nl := loadNamedLevels()
+    if nl == nil {
+        return level.InfoLevel, false
+    }
+    lvl, ok := nl.levels[name]
+    return lvl, ok
+}
+
+// updateNamedLevel 根据 name 本身当前的设置修改设置，fn 返回新的等级，set 为 false 时取消设置。
+// 读取和修改期间持有锁，不会覆盖同时通过 SetNamedLevel 修改的设置
+func updateNamedLevel(name string, fn func(cur level.Level, ok bool) (lvl level.Level, set bool)) {
+    namedLevelsMu.Lock()
+    defer namedLevelsMu.Unlock()
+
+    cur, ok := exactNamedLevel(name)
+    lvl, set := fn(cur, ok)
+    levels := make(map[string]level.Level)
+    if nl := loadNamedLevels(); nl != nil {
+        for k, v := range nl.levels {
+            levels[k] = v
+        }
+    }
+    if set {
+        levels[name] = lvl
+    } else {
+        delete(levels, name)
+    }
+    storeNamedLevels(levels)
+}
+
+func (nl *namedLevels) lookup(name string) (level.Level, bool) {
+    if nl == nil {
+        return level.InfoLevel, false
+    }
+    for {
+        if lvl, ok := nl.levels[name]; ok {
+            return lvl, true
+        }
+        i := strings.LastIndexByte(name, '.')
+        if i < 0 {
+            return level.InfoLevel, false
+        }
+        name = name[:i]
+    }
+}
+
+// namedLevelCore 根据日志名称使用 SetNamedLevel 设置的等级，没有设置时使用 lvl 判断是否输出，
+// 内部 core 只负责按等级范围、自定义判断函数等进行过滤
+type namedLevelCore struct {
+    core.Core
+    lvl level.LevelEnabler
+}
+
+func newNamedLevelCore(c core.Core, lvl level.LevelEnabler) core.Core {
+    if c == nil {
+        return nil
+    }
+    return &namedLevelCore{Core: c, lvl: lvl}
+}
+
+func (c *namedLevelCore) Enabled(lvl level.Level) bool {
+    if !c.Core.Enabled(lvl) {
+        return false
+    }
+    if c.lvl.Enabled(lvl) {
+        return true
+    }
+    nl := loadNamedLevels()
+    return nl != nil && lvl >= nl.min
+}
+
+func (c *namedLevelCore) With(fields []field.Field) core.Core {
+    return &namedLevelCore{Core: c.Core.With(fields), lvl: c.lvl}
+}
+
+func (c *namedLevelCore) Check(ent entry.Entry, ce *core.CheckedEntry) *core.CheckedEntry {
+    if lvl, ok := loadNamedLevels().lookup(ent.LoggerName); ok {
+        if ent.Level < lvl {
+            return ce
+        }
+    } else if !c.lvl.Enabled(ent.Level) {
+        return ce
+    }
+    return c.Core.Check(ent, ce)
+}
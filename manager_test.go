@@ -0,0 +1,192 @@
+package log
+
+import (
+    "bytes"
+    "errors"
+    "strings"
+    "testing"
+
+    "github.com/weitrue/log/config"
+    "github.com/weitrue/log/level"
+    "github.com/weitrue/log/writer"
+)
+
+// newNamedTestLogger 创建名称为 name、等级为 INFO 的 logger，输出 logger 名称和日志消息
+func newNamedTestLogger(t *testing.T, name string, buf *bytes.Buffer) *Logger {
+    t.Helper()
+    l, err := New(config.Config{
+        Name:          name,
+        Level:         level.NewAtomicLevelAt(INFO),
+        Encoding:      "console",
+        EncoderConfig: config.EncoderConfig{NameKey: "logger", MessageKey: "msg"},
+        Writer:        writer.AddSync(buf),
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    return l
+}
+
+func TestNamed(t *testing.T) {
+    var buf bytes.Buffer
+    root := newNamedTestLogger(t, "svc", &buf)
+    if root.Named("") != root {
+        t.Error("Named with an empty name should return the logger itself")
+    }
+    root.Named("db").Named("pool").Info("a")
+    root.Named("http").Info("b")
+    if got, want := buf.String(), "svc.db.pool\ta\nsvc.http\tb\n"; got != want {
+        t.Errorf("got %q, want %q", got, want)
+    }
+
+    buf.Reset()
+    anonymous := newNamedTestLogger(t, "", &buf)
+    anonymous.Named("db").Info("c")
+    if got, want := buf.String(), "db\tc\n"; got != want {
+        t.Errorf("got %q, want %q", got, want)
+    }
+}
+
+func TestSetNamedLevel(t *testing.T) {
+    defer storeNamedLevels(nil)
+
+    var buf bytes.Buffer
+    root := newNamedTestLogger(t, "svc", &buf)
+    db, pool, http := root.Named("db"), root.Named("db").Named("pool"), root.Named("http")
+    expect := func(want string, write func()) {
+        t.Helper()
+        buf.Reset()
+        write()
+        if got := buf.String(); got != want {
+            t.Errorf("got %q, want %q", got, want)
+        }
+    }
+    debugAll := func() {
+        for _, l := range []*Logger{root, db, pool, http} {
+            l.Debug("d")
+        }
+    }
+    infoAll := func() {
+        for _, l := range []*Logger{root, db, pool, http} {
+            l.Info("i")
+        }
+    }
+
+    expect("", debugAll)
+
+    // 设置 svc.db 之后，子 logger svc.db.pool 也输出 DEBUG 日志，兄弟 logger 不受影响
+    SetNamedLevel("svc.db", DEBUG)
+    expect("svc.db\td\nsvc.db.pool\td\n", debugAll)
+
+    // 子 logger 的设置优先于上级名称的设置
+    SetNamedLevel("svc", ERROR)
+    expect("svc.db\ti\nsvc.db.pool\ti\n", infoAll)
+    if lvl, ok := NamedLevel("svc.http.client"); !ok || lvl != ERROR {
+        t.Errorf("NamedLevel(svc.http.client) = %v, %v, want ERROR, true", lvl, ok)
+    }
+    if _, ok := NamedLevel("svcx"); ok {
+        t.Error("NamedLevel should only match whole name segments")
+    }
+
+    UnsetNamedLevel("svc.db")
+    UnsetNamedLevel("svc.none")
+    expect("", infoAll)
+    if lvl, ok := NamedLevel("svc.db.pool"); !ok || lvl != ERROR {
+        t.Errorf("NamedLevel(svc.db.pool) = %v, %v, want ERROR, true", lvl, ok)
+    }
+
+    // 全部取消后恢复使用 Config.Level
+    UnsetNamedLevel("svc")
+    expect("", debugAll)
+    expect("svc\ti\nsvc.db\ti\nsvc.db.pool\ti\nsvc.http\ti\n", infoAll)
+}
+
+// syncCloseRecorder 记录 Sync 和 Close 的调用次数，并返回指定的错误
+type syncCloseRecorder struct {
+    bytes.Buffer
+    syncs, closes int
+    err           error
+}
+
+func (r *syncCloseRecorder) Sync() error {
+    r.syncs++
+    return r.err
+}
+
+func (r *syncCloseRecorder) Close() error {
+    r.closes++
+    return r.err
+}
+
+func newRegisteredLogger(t *testing.T, id string, w *syncCloseRecorder) *Logger {
+    t.Helper()
+    l, err := New(config.Config{
+        ID:           id,
+        Name:         id,
+        ForceReplace: true,
+        Level:        level.NewAtomicLevelAt(INFO),
+        Encoding:     "json",
+        Writer:       w,
+    }, Closers(w))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { DeRegisterLogger(l) })
+    return l
+}
+
+func TestListLoggers(t *testing.T) {
+    b := newRegisteredLogger(t, "list-b", &syncCloseRecorder{})
+    a := newRegisteredLogger(t, "list-a", &syncCloseRecorder{})
+
+    var ids []string
+    for _, l := range ListLoggers() {
+        if strings.HasPrefix(l.ID, "list-") {
+            ids = append(ids, l.ID)
+        }
+    }
+    if got := strings.Join(ids, ","); got != "list-a,list-b" {
+        t.Errorf("ListLoggers() = %s, want sorted by ID", got)
+    }
+    if err := RegisterLogger(&Logger{ID: "list-a"}); err != ErrExistedLogger {
+        t.Errorf("RegisterLogger() error = %v, want %v", err, ErrExistedLogger)
+    }
+    if err := RegisterLogger(a); err != nil {
+        t.Errorf("registering the same logger again should succeed, got %v", err)
+    }
+
+    DeRegisterLogger(b)
+    if _, ok := GetLogger("list-b"); ok {
+        t.Error("deregistered logger still returned by GetLogger")
+    }
+}
+
+func TestSyncAllCloseAll(t *testing.T) {
+    global := getGlobalLog()
+    defer UpdateGlobalLogWithILogger(global)
+
+    failed := &syncCloseRecorder{err: errors.New("disk full")}
+    ok := &syncCloseRecorder{}
+    l := newRegisteredLogger(t, "all-failed", failed)
+    newRegisteredLogger(t, "all-ok", ok)
+    // 同时是全局 logger 和已注册的 logger，只同步一次
+    UpdateGlobalLog(l)
+
+    if err := SyncAll(); err == nil || !strings.Contains(err.Error(), "disk full") {
+        t.Errorf("SyncAll() error = %v, want the sync error", err)
+    }
+    if failed.syncs != 1 || ok.syncs != 1 {
+        t.Errorf("synced %d and %d times, want once each", failed.syncs, ok.syncs)
+    }
+
+    if err := CloseAll(); err == nil || !strings.Contains(err.Error(), "disk full") {
+        t.Errorf("CloseAll() error = %v, want the close error", err)
+    }
+    if failed.closes != 1 || ok.closes != 1 {
+        t.Errorf("closed %d and %d times, want once each", failed.closes, ok.closes)
+    }
+    // 关闭后仍然保留在全局管理器中
+    if _, found := GetLogger("all-ok"); !found {
+        t.Error("closed logger removed from the registry")
+    }
+}
@@ -1,6 +1,8 @@
 package log
 
 import (
+    "io"
+
     "github.com/weitrue/log/core"
     "github.com/weitrue/log/field"
     "github.com/weitrue/log/level"
@@ -28,6 +30,13 @@ func WrapCore(f func(core.Core) core.Core) Option {
     })
 }
 
+// Hooks 注册日志写入前调用的 hook，按注册顺序调用，可以追加字段、改写消息、丢弃日志，
+// 或者统计日志数量、发送告警等，参考 core.Hook
+func Hooks(hooks ...core.Hook) Option {
+    return optionFunc(func(log *Logger) {
+        log.core = core.NewHookCore(log.core, hooks...)
+    })
+}
 
 // Fields 添加 fields 到 logger 中，类似 With 操作
 func Fields(fs ...field.Field) Option {
@@ -83,7 +92,9 @@ func Location(location *time.Location) Option {
     })
 }
 
-
-
-
-
+// Closers 设置 logger 关闭时需要一并关闭的输出源，参考 Logger.Close
+func Closers(closers ...io.Closer) Option {
+    return optionFunc(func(log *Logger) {
+        log.closers = append(log.closers[:len(log.closers):len(log.closers)], closers...)
+    })
+}
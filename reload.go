@@ -0,0 +1,352 @@
+package log
+
+import (
+    "io"
+    "os"
+    "os/signal"
+    "sync"
+    "sync/atomic"
+    "syscall"
+    "time"
+
+    "github.com/weitrue/log/core"
+    "github.com/weitrue/log/entry"
+    "github.com/weitrue/log/field"
+    "github.com/weitrue/log/level"
+    "github.com/weitrue/log/utils"
+)
+
+var (
+    // DefaultWatchInterval 默认检查配置文件是否变化的间隔时间
+    DefaultWatchInterval = 10 * time.Second
+    // DefaultDrainDelay 默认替换 Logger 后，等待正在写入旧输出源的日志完成的最长时间
+    DefaultDrainDelay = 5 * time.Second
+)
+
+// ConfigWatcher 监听配置文件，在文件变化或者收到 SIGHUP 信号时，重新创建 Logger。
+// 新 Logger 会替换全局管理器中的同 ID 对象，如果旧 Logger 是全局默认 logger，则同时更新全局 logger。
+// ConfigWatcher 返回的 Logger 以及通过 With、Named、Switch 等派生的 logger 每次写入时都使用最新配置创建的输出源，
+// 仍然持有旧 Logger 的地方不会丢失日志，但 Name、EnableCaller、Development 等 Logger 本身的设置保持不变，
+// 需要重新通过 GetLogger 或者 ConfigWatcher.Logger 获取。
+// 旧配置的输出源在正在写入的日志都完成后（最多等待 DrainDelay）关闭，syslog、flume 等输出源会先写完缓存数据再关闭。
+type ConfigWatcher struct {
+    // Interval 检查配置文件是否变化的间隔时间，默认为 DefaultWatchInterval
+    Interval time.Duration
+    // DrainDelay 替换完成后，等待正在写入旧输出源的日志完成的最长时间，默认为 DefaultDrainDelay
+    DrainDelay time.Duration
+    // OnReload 每次重新加载后调用，err 不为空时表示加载失败，继续使用旧 Logger
+    OnReload func(l *Logger, err error)
+
+    path    string
+    options []Option
+
+    // current 当前使用的 *Logger，core 为 reloadCore
+    current atomic.Value
+    // gen 当前配置的 *generation
+    gen     atomic.Value
+    mu      sync.Mutex
+    modTime time.Time
+    size    int64
+
+    startOnce sync.Once
+    stopOnce  sync.Once
+    stop      chan struct{}
+    done      chan struct{}
+}
+
+// NewConfigWatcher 读取配置文件并创建 Logger，需要调用 Start 才开始监听配置变化。
+// options 在每次重新创建 Logger 时都会使用。
+func NewConfigWatcher(path string, options ...Option) (*ConfigWatcher, error) {
+    w := &ConfigWatcher{
+        Interval:   DefaultWatchInterval,
+        DrainDelay: DefaultDrainDelay,
+        path:       path,
+        options:    options,
+        stop:       make(chan struct{}),
+        done:       make(chan struct{}),
+    }
+    info, err := os.Stat(path)
+    if err != nil {
+        return nil, err
+    }
+    fc, err := LoadFileConfig(path)
+    if err != nil {
+        return nil, err
+    }
+    force := fc.ForceReplace
+    l, err := w.build(fc)
+    if err != nil {
+        return nil, err
+    }
+    w.modTime, w.size = info.ModTime(), info.Size()
+    w.gen.Store(&generation{logger: l})
+    if _, err = w.publish(l, force); err != nil {
+        _ = l.Close()
+        return nil, err
+    }
+    return w, nil
+}
+
+// Logger 获取当前使用的 Logger
+func (w *ConfigWatcher) Logger() *Logger {
+    return w.current.Load().(*Logger)
+}
+
+// Start 开始监听配置文件变化和 SIGHUP 信号
+func (w *ConfigWatcher) Start() {
+    w.startOnce.Do(func() {
+        go w.loop()
+    })
+}
+
+// Stop 停止监听，不会关闭当前使用的 Logger
+func (w *ConfigWatcher) Stop() {
+    w.stopOnce.Do(func() {
+        close(w.stop)
+    })
+    w.startOnce.Do(func() {
+        close(w.done)
+    })
+    <-w.done
+}
+
+func (w *ConfigWatcher) loop() {
+    defer close(w.done)
+    defer utils.CatchPanic()
+
+    signals := make(chan os.Signal, 1)
+    signal.Notify(signals, syscall.SIGHUP)
+    defer signal.Stop(signals)
+
+    interval := w.Interval
+    if interval <= 0 {
+        interval = DefaultWatchInterval
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ticker.C:
+            if w.changed() {
+                w.reload()
+            }
+        case <-signals:
+            w.reload()
+        case <-w.stop:
+            return
+        }
+    }
+}
+
+// changed 配置文件的修改时间或者大小发生变化
+func (w *ConfigWatcher) changed() bool {
+    info, err := os.Stat(w.path)
+    if err != nil {
+        _, _ = utils.ErrorOutput("ConfigWatcher stat config: " + err.Error())
+        return false
+    }
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    return !info.ModTime().Equal(w.modTime) || info.Size() != w.size
+}
+
+func (w *ConfigWatcher) reload() {
+    l, err := w.Reload()
+    if err != nil {
+        _, _ = utils.ErrorOutput("ConfigWatcher reload config: " + err.Error())
+    }
+    if w.OnReload != nil {
+        w.OnReload(l, err)
+    }
+}
+
+// Reload 立即重新读取配置文件并替换 Logger，失败时继续使用旧 Logger
+func (w *ConfigWatcher) Reload() (*Logger, error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    info, err := os.Stat(w.path)
+    if err != nil {
+        return w.Logger(), err
+    }
+    // 加载失败时同样记录，避免每次检查都重新解析同一个错误的配置文件
+    w.modTime, w.size = info.ModTime(), info.Size()
+    fc, err := LoadFileConfig(w.path)
+    if err != nil {
+        return w.Logger(), err
+    }
+    l, err := w.build(fc)
+    if err != nil {
+        return w.Logger(), err
+    }
+
+    old := w.gen.Load().(*generation)
+    w.gen.Store(&generation{logger: l})
+    // 之后的写入都使用新的输出源
+    atomic.StoreInt32(&old.retired, 1)
+    // 替换旧 Logger 的注册
+    facade, err := w.publish(l, true)
+    go w.drain(old)
+    return facade, err
+}
+
+// build 根据配置创建 Logger，但不注册到全局管理器中，
+// 该 Logger 的输出源会在配置变化后关闭，只能通过 publish 创建的 Logger 使用
+func (w *ConfigWatcher) build(fc *FileConfig) (*Logger, error) {
+    id := fc.ID
+    fc.ID, fc.ForceReplace = "", false
+    l, err := fc.Build(w.options...)
+    if err != nil {
+        return nil, err
+    }
+    l.ID = id
+    return l, nil
+}
+
+// publish 创建使用 reloadCore 的 Logger 作为当前 Logger，并替换全局管理器和全局 logger 中的旧 Logger，
+// force 参考 config.Config.ForceReplace
+func (w *ConfigWatcher) publish(l *Logger, force bool) (*Logger, error) {
+    facade := l.clone()
+    rc := &reloadCore{w: w}
+    facade.core = rc
+    facade.closers = []io.Closer{rc}
+
+    old, _ := w.current.Load().(*Logger)
+    w.current.Store(facade)
+    var err error
+    if facade.ID != "" {
+        err = registerLogger(facade, force)
+    }
+    if old == nil {
+        return facade, err
+    }
+    if old.ID != "" && old.ID != facade.ID {
+        if registered, ok := GetLogger(old.ID); ok && registered == old {
+            DeRegisterLogger(old)
+        }
+    }
+    if g, ok := getGlobalLog().(*Logger); ok && g == old {
+        UpdateGlobalLog(facade)
+    }
+    return facade, err
+}
+
+// drain 等待正在写入旧输出源的日志完成（最多等待 DrainDelay），再刷新并关闭旧配置的输出源
+func (w *ConfigWatcher) drain(old *generation) {
+    defer utils.CatchPanic()
+    deadline := time.Now().Add(w.DrainDelay)
+    for atomic.LoadInt64(&old.refs) > 0 {
+        if !time.Now().Before(deadline) {
+            _, _ = utils.ErrorOutput("ConfigWatcher close old logger: drain timeout")
+            break
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    if err := old.close(); err != nil {
+        _, _ = utils.ErrorOutput("ConfigWatcher close old logger: " + err.Error())
+    }
+}
+
+// acquire 获取当前配置并增加引用计数，写入完成后需要调用 release
+func (w *ConfigWatcher) acquire() *generation {
+    for {
+        g := w.gen.Load().(*generation)
+        atomic.AddInt64(&g.refs, 1)
+        // retired 在 gen 替换之后设置，此时重新获取的是新的配置
+        if atomic.LoadInt32(&g.retired) == 0 {
+            return g
+        }
+        g.release()
+    }
+}
+
+// generation 一次加载配置创建的 Logger，refs 为正在写入该配置输出源的日志数量
+type generation struct {
+    logger  *Logger
+    refs    int64
+    retired int32
+
+    closeOnce sync.Once
+    closeErr  error
+}
+
+func (g *generation) release() {
+    atomic.AddInt64(&g.refs, -1)
+}
+
+// close 关闭该配置的输出源，只关闭一次，比如调用 Logger.Close 之后配置文件又发生了变化
+func (g *generation) close() error {
+    g.closeOnce.Do(func() {
+        g.closeErr = g.logger.Close()
+    })
+    return g.closeErr
+}
+
+// reloadCore ConfigWatcher 返回的 Logger 以及派生的 logger 使用的 core，每次写入时使用当前配置的 core，
+// 通过 With 附加的字段在配置变化后重新附加到新的 core 上
+type reloadCore struct {
+    w      *ConfigWatcher
+    fields []field.Field
+    // cached 为 fields 附加到某个配置的 core 后的结果，*genCore
+    cached atomic.Value
+}
+
+type genCore struct {
+    gen  *generation
+    core core.Core
+}
+
+func (rc *reloadCore) coreOf(g *generation) core.Core {
+    if gc, ok := rc.cached.Load().(*genCore); ok && gc.gen == g {
+        return gc.core
+    }
+    c := g.logger.core
+    if len(rc.fields) > 0 {
+        c = c.With(rc.fields)
+    }
+    rc.cached.Store(&genCore{gen: g, core: c})
+    return c
+}
+
+func (rc *reloadCore) Enabled(lvl level.Level) bool {
+    return rc.w.gen.Load().(*generation).logger.core.Enabled(lvl)
+}
+
+func (rc *reloadCore) With(fields []field.Field) core.Core {
+    merged := make([]field.Field, 0, len(rc.fields)+len(fields))
+    merged = append(merged, rc.fields...)
+    merged = append(merged, fields...)
+    return &reloadCore{w: rc.w, fields: merged}
+}
+
+func (rc *reloadCore) Check(ent entry.Entry, ce *core.CheckedEntry) *core.CheckedEntry {
+    if rc.Enabled(ent.Level) {
+        return ce.AddCore(ent, rc)
+    }
+    return ce
+}
+
+// Write 在写入期间持有当前配置的引用，旧配置的输出源在写入完成后才会关闭
+func (rc *reloadCore) Write(ent entry.Entry, fields []field.Field) error {
+    g := rc.w.acquire()
+    defer g.release()
+    ce := rc.coreOf(g).Check(ent, nil)
+    if ce == nil {
+        return nil
+    }
+    return core.WriteCheckedEntry(ce, fields...)
+}
+
+func (rc *reloadCore) Sync() error {
+    g := rc.w.acquire()
+    defer g.release()
+    return g.logger.core.Sync()
+}
+
+// Close 关闭当前配置的输出源，参考 Logger.Close
+func (rc *reloadCore) Close() error {
+    g := rc.w.acquire()
+    defer g.release()
+    return g.close()
+}
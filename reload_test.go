@@ -0,0 +1,168 @@
+package log
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+func writeConfig(t *testing.T, path, logPath string) {
+    t.Helper()
+    cfg := "level: info\nencoding: json\noutputs:\n  - type: file\n    path: " + logPath + "\n"
+    if err := ioutil.WriteFile(path, []byte(cfg), 0644); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func countLines(t *testing.T, path, substr string) int {
+    t.Helper()
+    data, err := ioutil.ReadFile(path)
+    if err != nil && !os.IsNotExist(err) {
+        t.Fatal(err)
+    }
+    return strings.Count(string(data), substr)
+}
+
+func TestConfigWatcherDerivedLoggers(t *testing.T) {
+    dir := t.TempDir()
+    cfgPath := filepath.Join(dir, "log.yaml")
+    a, b := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")
+    writeConfig(t, cfgPath, a)
+
+    w, err := NewConfigWatcher(cfgPath)
+    if err != nil {
+        t.Fatal(err)
+    }
+    w.DrainDelay = time.Second
+    defer w.Logger().Close()
+
+    child := w.Logger().Named("child").With(String("k", "v"))
+    wl := child.Switch()
+
+    // 重新加载期间持续写入，日志不能丢失
+    const writers, perWriter = 4, 200
+    var wg sync.WaitGroup
+    for i := 0; i < writers; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for j := 0; j < perWriter; j++ {
+                child.Info("during")
+            }
+        }()
+    }
+    writeConfig(t, cfgPath, b)
+    if _, err = w.Reload(); err != nil {
+        t.Fatal(err)
+    }
+    wg.Wait()
+
+    child.Info("after")
+    wl.Infof("after %s", "switch")
+    _ = w.Logger().Sync()
+
+    if n := countLines(t, a, `"during"`) + countLines(t, b, `"during"`); n != writers*perWriter {
+        t.Errorf("got %d entries written during reload, want %d", n, writers*perWriter)
+    }
+    if n := countLines(t, b, `"after"`); n != 1 {
+        t.Errorf("derived logger should write to the new output, got %d entries", n)
+    }
+    if n := countLines(t, b, `"after switch"`); n != 1 {
+        t.Errorf("switched logger should write to the new output, got %d entries", n)
+    }
+    if !strings.Contains(readFile(t, b), `"k":"v"`) {
+        t.Error("fields added by With should be kept after reload")
+    }
+}
+
+func TestConfigWatcherBadConfig(t *testing.T) {
+    dir := t.TempDir()
+    cfgPath := filepath.Join(dir, "log.yaml")
+    writeConfig(t, cfgPath, filepath.Join(dir, "a.log"))
+
+    w, err := NewConfigWatcher(cfgPath)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer w.Logger().Close()
+    old := w.Logger()
+
+    if err = ioutil.WriteFile(cfgPath, []byte("outputs: [\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if !w.changed() {
+        t.Fatal("config change not detected")
+    }
+    if _, err = w.Reload(); err == nil {
+        t.Fatal("bad config should fail")
+    }
+    if w.Logger() != old {
+        t.Error("failed reload should keep the old logger")
+    }
+    // 同一个错误的配置文件不再重复解析
+    if w.changed() {
+        t.Error("failed config should not be reported as changed again")
+    }
+}
+
+func TestConfigWatcherRegistersFacadeOnly(t *testing.T) {
+    dir := t.TempDir()
+    cfgPath := filepath.Join(dir, "log.yaml")
+    cfg := "id: reload-registry\noutputs:\n  - type: file\n    path: " + filepath.Join(dir, "a.log") + "\n"
+    if err := ioutil.WriteFile(cfgPath, []byte(cfg), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    w, err := NewConfigWatcher(cfgPath)
+    if err != nil {
+        t.Fatal(err)
+    }
+    w.DrainDelay = 0
+    defer DeRegisterLogger(w.Logger())
+    defer w.Logger().Close()
+
+    // 重新加载期间，GetLogger 只能获取到 ConfigWatcher 返回的 Logger，不能获取到输出源会被关闭的内部 Logger
+    stop := make(chan struct{})
+    done := make(chan string)
+    go func() {
+        var bad string
+        for {
+            select {
+            case <-stop:
+                done <- bad
+                return
+            default:
+            }
+            if l, ok := GetLogger("reload-registry"); ok {
+                if _, isFacade := l.core.(*reloadCore); !isFacade && bad == "" {
+                    bad = "GetLogger returned the logger built by Reload"
+                }
+            }
+        }
+    }()
+    for i := 0; i < 200; i++ {
+        if _, err = w.Reload(); err != nil {
+            t.Fatal(err)
+        }
+    }
+    close(stop)
+    if bad := <-done; bad != "" {
+        t.Error(bad)
+    }
+    if l, _ := GetLogger("reload-registry"); l != w.Logger() {
+        t.Error("registered logger should be the current ConfigWatcher logger")
+    }
+}
+
+func readFile(t *testing.T, path string) string {
+    t.Helper()
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return string(data)
+}
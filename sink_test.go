@@ -0,0 +1,91 @@
+package log
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+
+    "github.com/weitrue/log/config"
+    "github.com/weitrue/log/level"
+    "github.com/weitrue/log/writer"
+)
+
+func TestSinks(t *testing.T) {
+    var all, low, high bytes.Buffer
+    lvl := level.NewAtomicLevelAt(DEBUG)
+    maxLow := level.NewAtomicLevelAt(INFO)
+    l, err := New(config.Config{
+        Level:         lvl,
+        Encoding:      "json",
+        EncoderConfig: config.EncoderConfig{MessageKey: "msg"},
+        Writer:        writer.AddSync(&all),
+        Sinks: []config.Sink{
+            // DEBUG 到 INFO 使用 console 格式
+            {MaxLevel: maxLow, Encoding: "console", EncoderConfig: &config.EncoderConfig{MessageKey: "msg"}, Writer: writer.AddSync(&low)},
+            // ERROR 以上使用单独的编码器配置
+            {Level: level.NewAtomicLevelAt(ERROR), EncoderConfig: &config.EncoderConfig{MessageKey: "text"}, Writer: writer.AddSync(&high)},
+        },
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    l.Debug("debug")
+    l.Info("info")
+    l.Warn("warn")
+    l.Error("error")
+
+    if got, want := strings.Count(all.String(), `"msg"`), 4; got != want {
+        t.Errorf("writer got %d entries, want %d: %q", got, want, all.String())
+    }
+    if got, want := low.String(), "debug\ninfo\n"; got != want {
+        t.Errorf("low sink got %q, want %q", got, want)
+    }
+    if got, want := high.String(), `{"text":"error"}`+"\n"; got != want {
+        t.Errorf("high sink got %q, want %q", got, want)
+    }
+
+    // 没有单独设置 Level 的 sink 跟随 Config.Level，MaxLevel 同样可以动态调整
+    all.Reset()
+    low.Reset()
+    lvl.SetLevel(INFO)
+    maxLow.SetLevel(WARN)
+    l.Debug("debug")
+    l.Warn("warn")
+    if got, want := low.String(), "warn\n"; got != want {
+        t.Errorf("low sink got %q after changing levels, want %q", got, want)
+    }
+    if strings.Contains(all.String(), "debug") {
+        t.Errorf("writer should follow Config.Level: %q", all.String())
+    }
+}
+
+func TestSinksWithoutWriter(t *testing.T) {
+    var buf bytes.Buffer
+    // Writer 为空时只输出到 Sinks
+    l, err := New(config.Config{
+        Level:         level.NewAtomicLevelAt(INFO),
+        Encoding:      "json",
+        EncoderConfig: config.EncoderConfig{MessageKey: "msg"},
+        Sinks:         []config.Sink{{Writer: writer.AddSync(&buf)}},
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.Info("info")
+    if got, want := buf.String(), `{"msg":"info"}`+"\n"; got != want {
+        t.Errorf("got %q, want %q", got, want)
+    }
+
+    _, err = New(config.Config{Level: level.NewAtomicLevelAt(INFO), Encoding: "json", Sinks: []config.Sink{{}}})
+    if err == nil || err.Error() != "sinks[0]: empty writer" {
+        t.Errorf("New() error = %v, want the sink without a writer reported", err)
+    }
+    _, err = New(config.Config{
+        Level: level.NewAtomicLevelAt(INFO), Encoding: "json",
+        Sinks: []config.Sink{{Encoding: "xml", Writer: writer.AddSync(&buf)}},
+    })
+    if err == nil || !strings.HasPrefix(err.Error(), "sinks[0]: ") {
+        t.Errorf("New() error = %v, want the sink encoding reported", err)
+    }
+}
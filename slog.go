@@ -0,0 +1,228 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+    "context"
+    "log/slog"
+    "runtime"
+    "time"
+
+    "github.com/weitrue/log/core"
+    "github.com/weitrue/log/entry"
+    "github.com/weitrue/log/field"
+    "github.com/weitrue/log/level"
+    "github.com/weitrue/log/stacktrace"
+    "github.com/weitrue/log/writer"
+    "go.uber.org/zap/zapcore"
+)
+
+// slog 中没有对应等级的日志，可以通过 slog.Logger.Log 使用以下等级记录
+const (
+    SlogLevelCritical = slog.Level(12)
+    SlogLevelFixed    = slog.Level(16)
+)
+
+// SlogLevel 将 slog 的日志等级转换为本库的日志等级：
+// 小于 Info 为 DEBUG，小于 Warn 为 INFO，小于 Error 为 WARN，小于 SlogLevelCritical 为 ERROR，
+// 小于 SlogLevelFixed 为 CRITICAL，其余为 FIXED。不会转换为 PANIC 和 FATAL。
+func SlogLevel(l slog.Level) level.Level {
+    switch {
+    case l >= SlogLevelFixed:
+        return FIXED
+    case l >= SlogLevelCritical:
+        return CRITICAL
+    case l >= slog.LevelError:
+        return ERROR
+    case l >= slog.LevelWarn:
+        return WARN
+    case l >= slog.LevelInfo:
+        return INFO
+    default:
+        return DEBUG
+    }
+}
+
+// SlogHandler 实现了 slog.Handler，将 slog 的日志写入 core.Core，
+// 使用 slog 的代码可以复用本库的编码器以及 syslog、flume 等输出源。
+// slog 的 group 会转换为 field.Namespace，同时会记录通过 RegisterContextExtractor 注册的 context 字段。
+type SlogHandler struct {
+    core core.Core
+    // groups 还没有添加到 core 中的 group，有字段时才添加，避免输出空的 group
+    groups []string
+
+    name        string
+    addCaller   bool
+    addStack    level.LevelEnabler
+    location    *time.Location
+    errorOutput writer.WriteSyncer
+}
+
+// NewSlogHandler 创建写入 c 的 slog.Handler，不记录 caller 和堆栈信息，
+// 需要使用 logger 的名称、caller 等配置时，使用 Logger.Slog
+func NewSlogHandler(c core.Core) *SlogHandler {
+    return &SlogHandler{
+        core:     c,
+        addStack: level.LevelEnablerFunc(func(level.Level) bool { return false }),
+        location: Local,
+    }
+}
+
+// Slog 返回写入 l 的 slog.Logger，使用 l 的名称、caller、堆栈信息以及时区配置
+func (l *Logger) Slog() *slog.Logger {
+    return slog.New(&SlogHandler{
+        core:        l.core,
+        name:        l.Name,
+        addCaller:   l.addCaller,
+        addStack:    l.addStack,
+        location:    l.Location,
+        errorOutput: l.errorOutput,
+    })
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, l slog.Level) bool {
+    return h.core.Enabled(SlogLevel(l))
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+    t := r.Time
+    if t.IsZero() {
+        t = time.Now()
+    }
+    if h.location != nil {
+        t = t.In(h.location)
+    }
+    ent := entry.Entry{
+        LoggerName: h.name,
+        Time:       t,
+        Level:      SlogLevel(r.Level),
+        Message:    r.Message,
+    }
+    ce := h.core.Check(ent, nil)
+    if ce == nil {
+        return nil
+    }
+    if h.errorOutput != nil {
+        ce.ErrorOutput = h.errorOutput
+    }
+    if r.PC != 0 {
+        if h.addCaller {
+            frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+            ce.Entry.Caller = core.NewEntryCaller(frame.PC, frame.File, frame.Line, frame.PC != 0)
+        }
+        if h.addStack.Enabled(ce.Entry.Level) {
+            ce.Entry.Stack = stacktrace.TakeStacktraceSkip(callerDepth(r.PC), 0)
+        }
+    }
+
+    fields := ContextFields(ctx)
+    if r.NumAttrs() > 0 {
+        fields = append(fields, namespaces(h.groups)...)
+        r.Attrs(func(a slog.Attr) bool {
+            fields = appendSlogAttr(fields, a)
+            return true
+        })
+    }
+    ce.Write(fields...)
+    return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+    if len(attrs) == 0 {
+        return h
+    }
+    fields := namespaces(h.groups)
+    for _, a := range attrs {
+        fields = appendSlogAttr(fields, a)
+    }
+    h2 := *h
+    h2.core = h.core.With(fields)
+    h2.groups = nil
+    return &h2
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+    if name == "" {
+        return h
+    }
+    h2 := *h
+    h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+    return &h2
+}
+
+// callerDepth 获取 pc 在当前调用栈中的位置，用于跳过 slog 内部的调用栈，找不到时返回 0
+func callerDepth(pc uintptr) int {
+    var pcs [64]uintptr
+    // 跳过 runtime.Callers 和 callerDepth，从 Handle 开始计算
+    n := runtime.Callers(2, pcs[:])
+    for i := 0; i < n; i++ {
+        if pcs[i] == pc {
+            return i
+        }
+    }
+    return 0
+}
+
+func namespaces(groups []string) []field.Field {
+    fields := make([]field.Field, 0, len(groups))
+    for _, g := range groups {
+        fields = append(fields, field.Namespace(g))
+    }
+    return fields
+}
+
+// appendSlogAttr 将 slog.Attr 转换为 field.Field，key 为空的 group 展开到上一级
+func appendSlogAttr(fields []field.Field, a slog.Attr) []field.Field {
+    a.Value = a.Value.Resolve()
+    if a.Equal(slog.Attr{}) {
+        return fields
+    }
+    switch a.Value.Kind() {
+    case slog.KindString:
+        return append(fields, field.String(a.Key, a.Value.String()))
+    case slog.KindInt64:
+        return append(fields, field.Int64(a.Key, a.Value.Int64()))
+    case slog.KindUint64:
+        return append(fields, field.Uint64(a.Key, a.Value.Uint64()))
+    case slog.KindFloat64:
+        return append(fields, field.Float64(a.Key, a.Value.Float64()))
+    case slog.KindBool:
+        return append(fields, field.Bool(a.Key, a.Value.Bool()))
+    case slog.KindDuration:
+        return append(fields, field.Duration(a.Key, a.Value.Duration()))
+    case slog.KindTime:
+        return append(fields, field.Time(a.Key, a.Value.Time()))
+    case slog.KindGroup:
+        attrs := a.Value.Group()
+        if len(attrs) == 0 {
+            return fields
+        }
+        if a.Key == "" {
+            for _, ga := range attrs {
+                fields = appendSlogAttr(fields, ga)
+            }
+            return fields
+        }
+        return append(fields, field.Object(a.Key, slogGroup(attrs)))
+    default:
+        if err, ok := a.Value.Any().(error); ok {
+            return append(fields, field.NamedError(a.Key, err))
+        }
+        return append(fields, field.Any(a.Key, a.Value.Any()))
+    }
+}
+
+// slogGroup 将 slog 的 group 编码为对象
+type slogGroup []slog.Attr
+
+func (g slogGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+    var fields []field.Field
+    for _, a := range g {
+        fields = appendSlogAttr(fields, a)
+    }
+    for _, f := range fields {
+        f.AddTo(enc)
+    }
+    return nil
+}
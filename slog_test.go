@@ -0,0 +1,84 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "log/slog"
+    "strings"
+    "testing"
+
+    "github.com/weitrue/log/config"
+    "github.com/weitrue/log/core"
+    "github.com/weitrue/log/encoder"
+    "github.com/weitrue/log/level"
+    "github.com/weitrue/log/writer"
+)
+
+func TestSlogLevel(t *testing.T) {
+    for l, want := range map[slog.Level]level.Level{
+        slog.LevelDebug - 4:  DEBUG,
+        slog.LevelDebug:      DEBUG,
+        slog.LevelInfo:       INFO,
+        slog.LevelInfo + 2:   INFO,
+        slog.LevelWarn:       WARN,
+        slog.LevelError:      ERROR,
+        SlogLevelCritical:    CRITICAL,
+        SlogLevelFixed:       FIXED,
+        SlogLevelFixed + 100: FIXED,
+    } {
+        if got := SlogLevel(l); got != want {
+            t.Errorf("SlogLevel(%v) = %v, want %v", l, got, want)
+        }
+    }
+}
+
+func TestSlogHandler(t *testing.T) {
+    var buf bytes.Buffer
+    l := newBufferLogger(t, &buf)
+    sl := l.Slog().With("app", "demo").WithGroup("req")
+
+    sl.Info("hi", "id", 7, slog.Group("user", "name", "bob"), slog.Group("", "flat", true), slog.Group("empty"))
+    // 没有字段时不输出空的 group
+    sl.WithGroup("none").Warn("no attrs")
+    sl.Error("failed", "err", errors.New("boom"))
+    sl.Log(context.Background(), SlogLevelFixed, "fixed")
+
+    want := `{"msg":"hi","app":"demo","req":{"id":7,"user":{"name":"bob"},"flat":true}}` + "\n" +
+        `{"msg":"no attrs","app":"demo"}` + "\n" +
+        `{"msg":"failed","app":"demo","req":{"err":"boom"}}` + "\n" +
+        `{"msg":"fixed","app":"demo"}` + "\n"
+    if got := buf.String(); got != want {
+        t.Errorf("got\n%s\nwant\n%s", got, want)
+    }
+}
+
+func TestSlogHandlerLevelAndCaller(t *testing.T) {
+    var buf bytes.Buffer
+    enc := encoder.NewJSONEncoder(config.EncoderConfig{MessageKey: "msg", LevelKey: "level", EncodeLevel: encoder.CapitalLevelEncoder})
+    sl := slog.New(NewSlogHandler(core.NewCore(enc, writer.AddSync(&buf), INFO)))
+    sl.Debug("debug")
+    sl.Log(context.Background(), SlogLevelCritical, "critical")
+    if got, want := buf.String(), `{"level":"CRITICAL","msg":"critical"}`+"\n"; got != want {
+        t.Errorf("got %q, want %q", got, want)
+    }
+
+    buf.Reset()
+    l, err := New(config.Config{
+        Level:         level.NewAtomicLevelAt(DEBUG),
+        EnableCaller:  true,
+        Encoding:      "json",
+        EncoderConfig: config.EncoderConfig{MessageKey: "msg", CallerKey: "caller", EncodeCaller: encoder.ShortCallerEncoder},
+        Writer:        writer.AddSync(&buf),
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.Slog().Info("caller")
+    if !strings.Contains(buf.String(), "/slog_test.go:") {
+        t.Errorf("caller should point to the slog call site: %q", buf.String())
+    }
+}
@@ -0,0 +1,105 @@
+package log
+
+import (
+    "bytes"
+    stdlog "log"
+    "sync"
+
+    "github.com/weitrue/log/level"
+)
+
+// 标准库 log 的 Printf 等函数调用 Write 时，Write 与调用方之间的调用栈层数（Printf -> output -> Write）
+const _stdLogCallerSkip = 2
+
+// LineWriter 将写入的数据按行拆分，每一行记录为一条日志，用于接入只支持 io.Writer 的第三方库。
+// 不完整的行会缓存起来，等到写入换行符或者调用 Sync 时再记录。
+// 记录的 caller 为调用 Write 的位置，由其他函数间接调用 Write 时，可以通过 AddCallerSkip 调整。
+type LineWriter struct {
+    logger *Logger
+    level  level.Level
+
+    mu  sync.Mutex
+    buf []byte
+}
+
+// NewLineWriter 创建 LineWriter，每一行数据以 lvl 等级记录到 l 中
+func NewLineWriter(l *Logger, lvl level.Level) *LineWriter {
+    return &LineWriter{logger: l, level: lvl}
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    n := len(p)
+    for len(p) > 0 {
+        i := bytes.IndexByte(p, '\n')
+        if i < 0 {
+            w.buf = append(w.buf, p...)
+            break
+        }
+        line := p[:i]
+        if len(w.buf) > 0 {
+            line = append(w.buf, line...)
+            w.buf = w.buf[:0]
+        }
+        p = p[i+1:]
+
+        msg := string(bytes.TrimSuffix(line, []byte{'\r'}))
+        if msg == "" {
+            continue
+        }
+        // 需要在 Write 中直接调用 Check，保证 caller 的层数正确
+        if ce := w.logger.Check(w.level, msg); ce != nil {
+            ce.Write()
+        }
+    }
+    return n, nil
+}
+
+// Sync 记录缓存中不完整的行，并同步 logger
+func (w *LineWriter) Sync() error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    if len(w.buf) > 0 {
+        msg := string(w.buf)
+        w.buf = w.buf[:0]
+        if ce := w.logger.Check(w.level, msg); ce != nil {
+            ce.Write()
+        }
+    }
+    return w.logger.Sync()
+}
+
+// NewStdLog 返回写入 l 的标准库 *log.Logger，每次输出记录为一条 INFO 日志
+func NewStdLog(l *Logger) *stdlog.Logger {
+    return NewStdLogAt(l, INFO)
+}
+
+// NewStdLogAt 返回写入 l 的标准库 *log.Logger，每次输出记录为一条 lvl 等级的日志，
+// 时间、caller 等信息由 l 记录，因此返回的 *log.Logger 不设置前缀和 flags
+func NewStdLogAt(l *Logger, lvl level.Level) *stdlog.Logger {
+    return stdlog.New(newStdLogWriter(l, lvl), "", 0)
+}
+
+// RedirectStdLog 将标准库 log 包的全局输出重定向到 l，每次输出记录为一条 lvl 等级的日志。
+// 返回的函数用于恢复重定向前的输出、前缀和 flags。
+func RedirectStdLog(l *Logger, lvl level.Level) func() {
+    flags := stdlog.Flags()
+    prefix := stdlog.Prefix()
+    output := stdlog.Writer()
+
+    stdlog.SetFlags(0)
+    stdlog.SetPrefix("")
+    stdlog.SetOutput(newStdLogWriter(l, lvl))
+    return func() {
+        stdlog.SetFlags(flags)
+        stdlog.SetPrefix(prefix)
+        stdlog.SetOutput(output)
+    }
+}
+
+func newStdLogWriter(l *Logger, lvl level.Level) *LineWriter {
+    return NewLineWriter(l.WithOptions(AddCallerSkip(_stdLogCallerSkip)), lvl)
+}
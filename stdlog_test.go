@@ -0,0 +1,103 @@
+package log
+
+import (
+    "bytes"
+    "fmt"
+    stdlog "log"
+    "runtime"
+    "strings"
+    "testing"
+
+    "github.com/weitrue/log/config"
+    "github.com/weitrue/log/encoder"
+    "github.com/weitrue/log/level"
+    "github.com/weitrue/log/writer"
+)
+
+// newCallerTestLogger 创建输出等级、caller 和消息的 console logger
+func newCallerTestLogger(t *testing.T, buf *bytes.Buffer) *Logger {
+    t.Helper()
+    l, err := New(config.Config{
+        Level:        level.NewAtomicLevelAt(INFO),
+        EnableCaller: true,
+        Encoding:     "console",
+        EncoderConfig: config.EncoderConfig{
+            LevelKey:     "level",
+            CallerKey:    "caller",
+            MessageKey:   "msg",
+            EncodeLevel:  encoder.CapitalLevelEncoder,
+            EncodeCaller: encoder.ShortCallerEncoder,
+        },
+        Writer: writer.AddSync(buf),
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    return l
+}
+
+// callerLine 返回调用方的下一行，用于检查日志记录的 caller
+func callerLine() string {
+    _, _, line, _ := runtime.Caller(1)
+    return fmt.Sprintf("/stdlog_test.go:%d", line+1)
+}
+
+func TestRedirectStdLog(t *testing.T) {
+    var buf bytes.Buffer
+    output, flags := stdlog.Writer(), stdlog.Flags()
+    restore := RedirectStdLog(newCallerTestLogger(t, &buf), WARN)
+
+    caller := callerLine()
+    stdlog.Printf("hello %s", "world")
+    stdlog.Println("a\nb")
+    restore()
+
+    lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+    if len(lines) != 3 {
+        t.Fatalf("got %d lines, want one entry per line: %q", len(lines), buf.String())
+    }
+    if !strings.HasPrefix(lines[0], "WARN\t") || !strings.HasSuffix(lines[0], "\thello world") {
+        t.Errorf("unexpected entry %q", lines[0])
+    }
+    if !strings.Contains(lines[0], caller+"\t") {
+        t.Errorf("caller should be the stdlog call site %s: %q", caller, lines[0])
+    }
+    if stdlog.Writer() != output || stdlog.Flags() != flags {
+        t.Error("restore should reset the std log output and flags")
+    }
+}
+
+func TestNewStdLogAt(t *testing.T) {
+    var buf bytes.Buffer
+    l := newCallerTestLogger(t, &buf)
+
+    NewStdLogAt(l, DEBUG).Print("dropped")
+    caller := callerLine()
+    NewStdLog(l).Print("kept")
+    if strings.Contains(buf.String(), "dropped") {
+        t.Errorf("entry below the logger level written: %q", buf.String())
+    }
+    if got := buf.String(); !strings.HasPrefix(got, "INFO\t") || !strings.Contains(got, caller+"\tkept") {
+        t.Errorf("got %q, want an INFO entry from %s", got, caller)
+    }
+}
+
+func TestLineWriter(t *testing.T) {
+    var buf bytes.Buffer
+    w := NewLineWriter(newCallerTestLogger(t, &buf), ERROR)
+
+    caller := callerLine()
+    _, _ = w.Write([]byte("first\r\n\nsec"))
+    _, _ = w.Write([]byte("ond\nthi"))
+    if got := strings.Count(buf.String(), "\n"); got != 2 {
+        t.Errorf("got %d entries, want complete lines only: %q", got, buf.String())
+    }
+    if !strings.Contains(buf.String(), caller+"\tfirst\n") {
+        t.Errorf("caller should be the Write call site %s: %q", caller, buf.String())
+    }
+    // Sync 记录缓存中不完整的行
+    _ = w.Sync()
+    if !strings.Contains(buf.String(), "\tsecond\n") || !strings.HasSuffix(buf.String(), "\tthi\n") {
+        t.Errorf("partial line not flushed by Sync: %q", buf.String())
+    }
+}
@@ -33,6 +33,12 @@ type IBaseWLogger interface {
     // Critical 异常信息记录
     Critical(msg string, keysAndValues ...interface{})
     Criticalf(template string, args ...interface{})
+    // Panic 记录日志后触发 panic
+    Panic(msg string, keysAndValues ...interface{})
+    Panicf(template string, args ...interface{})
+    // Fatal 记录日志后调用 ExitFunc 退出程序
+    Fatal(msg string, keysAndValues ...interface{})
+    Fatalf(template string, args ...interface{})
     // Fixed 固定信息记录，用于服务运行状态或者输出加载配置信息等等。
     Fixed(msg string, keysAndValues ...interface{})
     Fixedf(template string, args ...interface{})
@@ -44,7 +50,7 @@ type IWLogger interface {
 
     // Sync 同步操作,刷新缓冲区
     Sync() error
-    // Named 日志对象命名
+    // Named 返回以 "." 连接名称的子日志对象
     Named(s string) *WLogger
     // With 附加信息到日志对象，并返回
     With(args ...interface{}) *WLogger
@@ -96,6 +102,35 @@ func (l *WLogger) Critical(msg string, keysAndValues ...interface{}) {
 func (s *WLogger) Criticalf(template string, args ...interface{}) {
     s.log(CRITICAL, template, args, nil)
 }
+// Panic 记录日志，并同步当前 logger 以及所有已注册的 logger 后触发 panic，参考 Logger.Panic
+func (l *WLogger) Panic(msg string, keysAndValues ...interface{}) {
+    l.log(PANIC, msg, nil, keysAndValues)
+    _ = l.Sync()
+    _ = SyncAll()
+    panic(msg)
+}
+// Panicf uses fmt.Sprintf to log a templated message, then panics.
+func (s *WLogger) Panicf(template string, args ...interface{}) {
+    msg := fmt.Sprintf(template, args...)
+    s.log(PANIC, msg, nil, nil)
+    _ = s.Sync()
+    _ = SyncAll()
+    panic(msg)
+}
+// Fatal 记录日志，并同步当前 logger 以及所有已注册的 logger 后调用 ExitFunc(1) 退出程序，参考 Logger.Fatal
+func (l *WLogger) Fatal(msg string, keysAndValues ...interface{}) {
+    l.log(FATAL, msg, nil, keysAndValues)
+    _ = l.Sync()
+    _ = SyncAll()
+    ExitFunc(1)
+}
+// Fatalf uses fmt.Sprintf to log a templated message, then calls ExitFunc(1).
+func (s *WLogger) Fatalf(template string, args ...interface{}) {
+    s.log(FATAL, fmt.Sprintf(template, args...), nil, nil)
+    _ = s.Sync()
+    _ = SyncAll()
+    ExitFunc(1)
+}
 func (l *WLogger) Fixed(msg string, keysAndValues ...interface{}) {
     l.log(FIXED, msg, nil, keysAndValues)
 }
@@ -109,8 +144,9 @@ func (l *WLogger) Sync() error {
     return l.base.Sync()
 }
 
-func (l *WLogger) Named(name string) {
-     l.base.Named(name)
+// Named 返回名称为 "l.Name.name" 的子 logger，参考 Logger.Named
+func (l *WLogger) Named(name string) *WLogger {
+    return &WLogger{base: l.base.Named(name), Level: l.Level}
 }
 
 func (l *WLogger) With(args ...interface{}) *WLogger {
@@ -1,6 +1,8 @@
 package flumefilewriter
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"os"
 	"strconv"
@@ -26,6 +28,18 @@ func MergeError(errs ...error) (err error) {
 
 }
 
+// gzipTo 使用 gzip 压缩 data 并写入 buf
+func gzipTo(buf *bytes.Buffer, data []byte, level int) error {
+	w, err := gzip.NewWriterLevel(buf, level)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
 // 快速版读取文件夹
 func readDir(path string) (fileInfos []os.FileInfo, err error) {
 	file, err := os.Open(path)
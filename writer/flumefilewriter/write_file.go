@@ -110,6 +110,11 @@ func (wh *writeHandle) formatFileName() (pwd string) {
 	return pwd
 }
 
+// String 返回 writer 的描述信息，格式为 flume:根目录/服务名
+func (wh *writeHandle) String() string {
+	return "flume:" + filepath.Join(wh.rootPath, wh.tableName)
+}
+
 // Sync 同步数据，写入，并作一些特殊处理
 // 这边尝试进行移动临时数据
 func (wh *writeHandle) Sync() error {
@@ -232,8 +237,20 @@ func (wh *writeHandle) writeFile() {
 				i = logLength
 			}
 		}
-		d := buff.Bytes()
+		raw := buff.Bytes()
+		d := raw
 		countSuffix := strconv.Itoa(logMsgCount)
+		if wh.gzipLevel != 0 && len(d) > 0 {
+			// 压缩失败时写入未压缩的数据
+			gz := _pool.Get()
+			if err := gzipTo(gz, d, wh.gzipLevel); err != nil {
+				_, _ = utils.ErrorOutput(NewError("gzip", err).Error())
+			} else {
+				d = gz.Bytes()
+				countSuffix += GzipSuffix
+			}
+			defer _pool.Put(gz)
+		}
 
 		if len(d) > 0 {
 			// 重试所有目录
@@ -263,25 +280,27 @@ func (wh *writeHandle) writeFile() {
 				_, _ = utils.ErrorOutput(NewError("WriteFile", writeErr).Error())
 				// 写临时目录了
 				// 不处理 fmt 错误
-				_, _ = wh.write2SysTemp(d, countSuffix)
+				_, _ = wh.write2SysTemp(d, raw, countSuffix)
 			}
 		}
 		_pool.Put(buff)
 	}
 }
 
-func (wh *writeHandle) write2SysTemp(d []byte, logMsgCount string) (int, error) {
+// write2SysTemp 将 d 写入系统临时目录，d 为写入文件的数据（可能已经压缩），
+// raw 为未压缩的数据，临时目录也无法写入时输出 raw
+func (wh *writeHandle) write2SysTemp(d, raw []byte, logMsgCount string) (int, error) {
 	preDir := filepath.Join(os.TempDir(), "taotie.log")
 	err := os.Mkdir(preDir, os.ModePerm)
 	// 这都不能写
 	if err != nil {
-		return utils.ErrorOutput(string(d))
+		return utils.ErrorOutput(string(raw))
 	}
 	pwd := wh.formatFileNameWithDir(preDir) + "." + logMsgCount
 	n, err := WriteFile(pwd, d, os.ModePerm)
 	// 这都不能写
 	if err != nil {
-		return utils.ErrorOutput(string(d))
+		return utils.ErrorOutput(string(raw))
 	}
 	return n, err
 }
@@ -1,6 +1,7 @@
 package flumefilewriter
 
 import (
+	"compress/gzip"
 	"time"
 
 	"go.uber.org/atomic"
@@ -36,6 +37,7 @@ type writeHandle struct {
 
 	sliceDirCount  atomic.Int64 // 分片目录数量
 	isMoveTempFile bool         // 是否监控并移动临时文件
+	gzipLevel      int          // 不为 0 时日志文件使用 gzip 压缩，文件名增加 GzipSuffix
 	// 关闭标志
 	isClose bool
 }
@@ -122,6 +124,19 @@ func (s setmaxLogCount) apply(info *writeHandle) {
 	info.maxLogCount = s.logCount
 }
 
+// GzipSuffix 使用 gzip 压缩的日志文件名后缀，flume spooldir source 需要配置对应的 includePattern 和解压的 deserializer
+const GzipSuffix = ".gz"
+
+// Gzip 日志文件使用 gzip 压缩，文件名增加 GzipSuffix，level 参考 compress/gzip，不在范围内时使用 gzip.DefaultCompression
+func Gzip(level int) DialOption {
+	return optionFunc(func(wh *writeHandle) {
+		if level < gzip.BestSpeed || level > gzip.BestCompression {
+			level = gzip.DefaultCompression
+		}
+		wh.gzipLevel = level
+	})
+}
+
 // MoveTempFile 可移动TempFile
 func MoveTempFile() DialOption {
 	return moveTempFile{}
@@ -0,0 +1,6 @@
+package writer
+
+import "github.com/weitrue/log/writer/gelf"
+
+var NewGelfTCPWriter = gelf.NewTCPWriter
+var NewGelfUDPWriter = gelf.NewUDPWriter
@@ -0,0 +1,284 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+/*
+GELF writer，将 encoder.GelfEncoder 编码的日志发送到 Graylog：
+  1、tcp：每条日志以 '\0' 结尾，不支持压缩，发送失败时会重新连接并重试一次；
+  2、udp：日志超过 ChunkSize 时按 GELF 的 chunk 格式分片发送，最多 128 片，可以使用 gzip 或 zlib 压缩。
+每次 Write 的数据为一条日志，末尾的换行符会被去掉。
+Write 在持有锁的情况下同步发送，不会缓存数据：单次 Write 最多阻塞 DialTimeout + WriteTimeout（tcp 重试时为两倍），
+并发写入时依次等待。Graylog 响应慢或者不可用时会直接拖慢写日志的协程，对延迟敏感时应设置较小的超时时间，
+或者使用 udp 发送。
+*/
+
+// CompressType udp 发送时使用的压缩方式
+type CompressType int
+
+const (
+	CompressNone CompressType = iota
+	CompressGzip
+	CompressZlib
+)
+
+const (
+	// DefaultChunkSize 默认的 udp 分片大小（包含分片头），适用于大部分网络环境
+	DefaultChunkSize = 1420
+	// MaxChunkCount GELF 允许的最大分片数量
+	MaxChunkCount = 128
+
+	_chunkHeaderSize = 12
+	_minChunkSize    = _chunkHeaderSize + 1
+)
+
+var (
+	ErrUnsupportedNetwork = errors.New("gelf: only support tcp and udp")
+	ErrMessageTooLarge    = errors.New("gelf: message too large")
+	ErrWriterClosed       = errors.New("gelf: writer closed")
+
+	_chunkMagic = []byte{0x1e, 0x0f}
+)
+
+type OptionFunc func(w *Writer)
+
+// Compression 设置 udp 发送时使用的压缩方式，默认不压缩，tcp 发送时不生效
+func Compression(c CompressType) OptionFunc {
+	return func(w *Writer) {
+		w.compress = c
+	}
+}
+
+// ChunkSize 设置 udp 分片大小（包含 12 字节的分片头），默认为 DefaultChunkSize
+func ChunkSize(size int) OptionFunc {
+	return func(w *Writer) {
+		if size >= _minChunkSize {
+			w.chunkSize = size
+		}
+	}
+}
+
+// DialTimeout 设置连接超时时间，默认为 3 秒
+func DialTimeout(d time.Duration) OptionFunc {
+	return func(w *Writer) {
+		w.dialTimeout = d
+	}
+}
+
+// WriteTimeout 设置发送超时时间，默认为 3 秒
+func WriteTimeout(d time.Duration) OptionFunc {
+	return func(w *Writer) {
+		w.writeTimeout = d
+	}
+}
+
+// Writer GELF writer，可以并发使用，并发的 Write 按顺序同步发送
+type Writer struct {
+	network string
+	raddr   string
+
+	compress     CompressType
+	chunkSize    int
+	dialTimeout  time.Duration
+	writeTimeout time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+	// msgID udp 分片使用的消息 ID，初始值随机生成，每条分片消息加一
+	msgID atomic.Uint64
+	buf   bytes.Buffer
+}
+
+// Dial 创建 GELF writer，network 为 tcp 或 udp
+func Dial(network, raddr string, opts ...OptionFunc) (*Writer, error) {
+	if network != "tcp" && network != "udp" {
+		return nil, ErrUnsupportedNetwork
+	}
+	w := &Writer{
+		network:      network,
+		raddr:        raddr,
+		chunkSize:    DefaultChunkSize,
+		dialTimeout:  3 * time.Second,
+		writeTimeout: 3 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err == nil {
+		w.msgID.Store(binary.BigEndian.Uint64(id[:]))
+	}
+
+	if err := w.dial(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// NewTCPWriter 创建使用 tcp 发送的 GELF writer
+func NewTCPWriter(raddr string, opts ...OptionFunc) (*Writer, error) {
+	return Dial("tcp", raddr, opts...)
+}
+
+// NewUDPWriter 创建使用 udp 发送的 GELF writer
+func NewUDPWriter(raddr string, opts ...OptionFunc) (*Writer, error) {
+	return Dial("udp", raddr, opts...)
+}
+
+func (w *Writer) dial() error {
+	conn, err := net.DialTimeout(w.network, w.raddr, w.dialTimeout)
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n := len(p)
+	p = bytes.TrimRight(p, "\r\n")
+	if len(p) == 0 {
+		return n, nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+
+	var err error
+	if w.network == "tcp" {
+		err = w.writeTCP(p)
+	} else {
+		err = w.writeUDP(p)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (w *Writer) writeTCP(p []byte) error {
+	w.buf.Reset()
+	w.buf.Write(p)
+	w.buf.WriteByte(0)
+
+	// 连接可能已经被服务端关闭，失败时重新连接并重试一次
+	var err error
+	for i := 0; i < 2; i++ {
+		if w.conn == nil {
+			if err = w.dial(); err != nil {
+				return err
+			}
+		}
+		_ = w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+		if _, err = w.conn.Write(w.buf.Bytes()); err == nil {
+			return nil
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+	return err
+}
+
+func (w *Writer) writeUDP(p []byte) error {
+	if w.conn == nil {
+		if err := w.dial(); err != nil {
+			return err
+		}
+	}
+	data, err := w.compressData(p)
+	if err != nil {
+		return err
+	}
+	_ = w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+	if len(data) <= w.chunkSize {
+		_, err = w.conn.Write(data)
+		return err
+	}
+
+	dataSize := w.chunkSize - _chunkHeaderSize
+	count := (len(data) + dataSize - 1) / dataSize
+	if count > MaxChunkCount {
+		return fmt.Errorf("%w: %d bytes need %d chunks", ErrMessageTooLarge, len(data), count)
+	}
+	// 分片头：2 字节 magic、8 字节消息 ID、1 字节分片序号、1 字节分片数量
+	chunk := make([]byte, _chunkHeaderSize, w.chunkSize)
+	copy(chunk, _chunkMagic)
+	binary.BigEndian.PutUint64(chunk[2:10], w.msgID.Inc())
+	chunk[11] = byte(count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * dataSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk[10] = byte(i)
+		chunk = append(chunk[:_chunkHeaderSize], data[i*dataSize:end]...)
+		if _, err = w.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compressData 按设置的压缩方式压缩数据，不压缩时直接返回 p
+func (w *Writer) compressData(p []byte) ([]byte, error) {
+	var zw io.WriteCloser
+	switch w.compress {
+	case CompressGzip:
+		w.buf.Reset()
+		zw = gzip.NewWriter(&w.buf)
+	case CompressZlib:
+		w.buf.Reset()
+		zw = zlib.NewWriter(&w.buf)
+	default:
+		return p, nil
+	}
+	if _, err := zw.Write(p); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+// Sync 数据在 Write 时已经发送，不需要同步
+func (w *Writer) Sync() error {
+	return nil
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// String 返回 writer 的描述信息，格式为 gelf:网络://地址
+func (w *Writer) String() string {
+	return "gelf:" + w.network + "://" + w.raddr
+}
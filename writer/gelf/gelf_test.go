@@ -0,0 +1,198 @@
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	frames := make(chan string, 8)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		r := bufio.NewReader(c)
+		for {
+			frame, err := r.ReadBytes(0)
+			if err != nil {
+				return
+			}
+			frames <- string(frame)
+		}
+	}()
+
+	w, err := NewTCPWriter(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"short_message":"a"}` + "\n", `{"short_message":"a"}` + "\x00"},
+		{`{"short_message":"b"}` + "\r\n", `{"short_message":"b"}` + "\x00"},
+		{`{"short_message":"c"}`, `{"short_message":"c"}` + "\x00"},
+	}
+	for _, tt := range tests {
+		if n, err := w.Write([]byte(tt.in)); err != nil || n != len(tt.in) {
+			t.Fatalf("Write(%q) = %d, %v", tt.in, n, err)
+		}
+		select {
+		case got := <-frames:
+			if got != tt.want {
+				t.Errorf("got frame %q, want %q", got, tt.want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("frame %q not received", tt.want)
+		}
+	}
+}
+
+func TestUDPChunking(t *testing.T) {
+	random := make([]byte, 1000)
+	_, _ = rand.Read(random)
+	tests := []struct {
+		name      string
+		compress  CompressType
+		chunkSize int
+		msg       []byte
+		chunks    int // 0 表示不分片
+	}{
+		{"small", CompressNone, DefaultChunkSize, []byte(`{"short_message":"a"}`), 0},
+		{"chunked", CompressNone, 112, random, 10},
+		{"gzip", CompressGzip, DefaultChunkSize, bytes.Repeat([]byte("a"), 5000), 0},
+		{"gzip chunked", CompressGzip, 112, random, 11},
+		{"zlib chunked", CompressZlib, 112, random, 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pc.Close()
+			w, err := NewUDPWriter(pc.LocalAddr().String(), Compression(tt.compress), ChunkSize(tt.chunkSize))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+			if _, err = w.Write(tt.msg); err != nil {
+				t.Fatal(err)
+			}
+
+			data := readMessage(t, pc, tt.chunks)
+			switch tt.compress {
+			case CompressGzip:
+				r, err := gzip.NewReader(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if data, err = ioutil.ReadAll(r); err != nil {
+					t.Fatal(err)
+				}
+			case CompressZlib:
+				r, err := zlib.NewReader(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if data, err = ioutil.ReadAll(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(data, tt.msg) {
+				t.Errorf("got %d bytes, want %d bytes", len(data), len(tt.msg))
+			}
+		})
+	}
+}
+
+// readMessage 读取一条消息，chunks 不为 0 时检查分片头并按序号合并
+func readMessage(t *testing.T, pc net.PacketConn, chunks int) []byte {
+	t.Helper()
+	_ = pc.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 65536)
+	if chunks == 0 {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.HasPrefix(buf[:n], _chunkMagic) {
+			t.Fatal("message should not be chunked")
+		}
+		return append([]byte(nil), buf[:n]...)
+	}
+	parts := make([][]byte, chunks)
+	var msgID uint64
+	for i := 0; i < chunks; i++ {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunk := buf[:n]
+		if n < _chunkHeaderSize || !bytes.HasPrefix(chunk, _chunkMagic) {
+			t.Fatalf("invalid chunk header % x", chunk[:_chunkHeaderSize])
+		}
+		id := binary.BigEndian.Uint64(chunk[2:10])
+		if i == 0 {
+			msgID = id
+		} else if id != msgID {
+			t.Fatalf("chunk %d has message id %d, want %d", i, id, msgID)
+		}
+		seq, count := int(chunk[10]), int(chunk[11])
+		if count != chunks || seq >= chunks {
+			t.Fatalf("chunk %d/%d, want %d chunks", seq, count, chunks)
+		}
+		parts[seq] = append([]byte(nil), chunk[_chunkHeaderSize:]...)
+	}
+	return bytes.Join(parts, nil)
+}
+
+func TestUDPTooLarge(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	w, err := NewUDPWriter(pc.LocalAddr().String(), ChunkSize(_minChunkSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err = w.Write(make([]byte, MaxChunkCount+1)); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("got %v, want ErrMessageTooLarge", err)
+	}
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	w, err := NewUDPWriter(pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+	if _, err = w.Write([]byte("{}")); err != ErrWriterClosed {
+		t.Errorf("got %v, want ErrWriterClosed", err)
+	}
+}
@@ -4,4 +4,7 @@ import "github.com/weitrue/log/writer/syslog"
 
 var NewTcpSyslog = syslog.NewTcpSyslog
 var NewTcpSyslog2 = syslog.NewTcpSyslog2
+var NewUdpSyslog2 = syslog.NewUdpSyslog2
+var NewUnixSyslog2 = syslog.NewUnixSyslog2
+var NewTlsSyslog2 = syslog.NewTlsSyslog2
 var ClearSyslogWriter = syslog.ClearSyslogWriter
@@ -0,0 +1,195 @@
+package syslog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/weitrue/log/utils"
+)
+
+// BreakerState 熔断器状态
+type BreakerState int32
+
+const (
+	// BreakerClosed 正常发送
+	BreakerClosed BreakerState = iota
+	// BreakerOpen 连续失败次数达到 BreakerConfig.FailureThreshold，数据直接写入本地缓存，不再建立连接
+	BreakerOpen
+	// BreakerHalfOpen 熔断时间结束，后台检查连接，同时允许一次发送，成功时关闭熔断器，失败时重新熔断
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// 熔断器的默认设置
+const (
+	DefaultFailureThreshold = 5
+	DefaultOpenTimeout      = 5 * time.Second
+	DefaultMaxOpenTimeout   = time.Minute
+)
+
+// BreakerConfig 熔断器设置，通过 CircuitBreaker 设置，为 0 的字段使用默认值
+type BreakerConfig struct {
+	// FailureThreshold 连续失败多少次后熔断，默认为 DefaultFailureThreshold，小于 0 时不使用熔断器
+	FailureThreshold int
+	// OpenTimeout 熔断时间，连续熔断时按指数增加，最长为 MaxOpenTimeout，默认为 DefaultOpenTimeout 和 DefaultMaxOpenTimeout
+	OpenTimeout    time.Duration
+	MaxOpenTimeout time.Duration
+	// OnStateChange 状态变化时调用，为空时通过 utils.ErrorOutput 输出
+	OnStateChange func(from, to BreakerState)
+}
+
+// CircuitBreaker 设置熔断器
+func CircuitBreaker(cfg BreakerConfig) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		handle.settingsRef().Breaker = cfg
+	}
+}
+
+// resolve 为 0 的字段使用默认值
+func (c *BreakerConfig) resolve() {
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = DefaultFailureThreshold
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = DefaultOpenTimeout
+	}
+	if c.MaxOpenTimeout <= 0 {
+		c.MaxOpenTimeout = DefaultMaxOpenTimeout
+	}
+	if c.MaxOpenTimeout < c.OpenTimeout {
+		c.MaxOpenTimeout = c.OpenTimeout
+	}
+}
+
+// breaker 连接池的熔断器
+type breaker struct {
+	mu        sync.Mutex
+	cfg       BreakerConfig
+	state     BreakerState
+	failures  int       // 连续失败次数
+	trips     int       // 连续熔断次数，用于计算熔断时间
+	openUntil time.Time // 熔断结束时间
+	trial     bool      // 半开状态下是否已经允许了一次发送
+
+	// opened 熔断时通知后台检查
+	opened chan struct{}
+}
+
+func newBreaker(cfg BreakerConfig) *breaker {
+	return &breaker{cfg: cfg, opened: make(chan struct{}, 1)}
+}
+
+// allow 是否允许建立连接发送数据
+func (b *breaker) allow() bool {
+	if b.cfg.FailureThreshold < 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+	}
+	return true
+}
+
+// success 发送或者检查成功，关闭熔断器
+func (b *breaker) success() {
+	if b.cfg.FailureThreshold < 0 {
+		return
+	}
+	b.mu.Lock()
+	from := b.state
+	b.failures = 0
+	b.trips = 0
+	b.trial = false
+	b.state = BreakerClosed
+	b.mu.Unlock()
+	b.report(from, BreakerClosed)
+}
+
+// failure 连接或者发送失败，连续失败次数达到阈值或者处于半开状态时熔断
+func (b *breaker) failure() {
+	if b.cfg.FailureThreshold < 0 {
+		return
+	}
+	b.mu.Lock()
+	from := b.state
+	b.failures++
+	if from == BreakerOpen || (from == BreakerClosed && b.failures < b.cfg.FailureThreshold) {
+		b.mu.Unlock()
+		return
+	}
+	b.trips++
+	d := b.cfg.OpenTimeout
+	for i := 1; i < b.trips && d < b.cfg.MaxOpenTimeout; i++ {
+		d *= 2
+	}
+	if d > b.cfg.MaxOpenTimeout {
+		d = b.cfg.MaxOpenTimeout
+	}
+	b.openUntil = time.Now().Add(d)
+	b.trial = false
+	b.state = BreakerOpen
+	b.mu.Unlock()
+
+	select {
+	case b.opened <- struct{}{}:
+	default:
+	}
+	b.report(from, BreakerOpen)
+}
+
+// halfOpen 熔断时间结束，进入半开状态，返回 false 表示状态已经变化
+func (b *breaker) halfOpen() bool {
+	b.mu.Lock()
+	if b.state != BreakerOpen {
+		b.mu.Unlock()
+		return false
+	}
+	b.state = BreakerHalfOpen
+	b.trial = false
+	b.mu.Unlock()
+	b.report(BreakerOpen, BreakerHalfOpen)
+	return true
+}
+
+// wait 返回熔断状态以及距离熔断结束的时间
+func (b *breaker) wait() (BreakerState, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, time.Until(b.openUntil)
+}
+
+func (b *breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *breaker) report(from, to BreakerState) {
+	if from == to {
+		return
+	}
+	if b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(from, to)
+		return
+	}
+	_, _ = utils.ErrorOutput(fmt.Sprintf("syslog circuit breaker %s -> %s", from, to))
+}
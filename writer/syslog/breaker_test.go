@@ -0,0 +1,152 @@
+package syslog
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestBreaker 创建记录状态变化的熔断器
+func newTestBreaker(cfg BreakerConfig) (*breaker, *[]string) {
+	var transitions []string
+	cfg.OnStateChange = func(from, to BreakerState) {
+		transitions = append(transitions, fmt.Sprintf("%s->%s", from, to))
+	}
+	cfg.resolve()
+	return newBreaker(cfg), &transitions
+}
+
+func TestBreaker(t *testing.T) {
+	b, transitions := newTestBreaker(BreakerConfig{FailureThreshold: 3})
+
+	// 成功会清空连续失败次数
+	b.failure()
+	b.failure()
+	b.success()
+	b.failure()
+	b.failure()
+	if !b.allow() || b.State() != BreakerClosed {
+		t.Fatalf("breaker should stay closed below the threshold, state %s", b.State())
+	}
+
+	b.failure()
+	if b.allow() || b.State() != BreakerOpen {
+		t.Fatalf("breaker should open at the threshold, state %s", b.State())
+	}
+	select {
+	case <-b.opened:
+	default:
+		t.Error("opening the breaker should notify the health check")
+	}
+	// 熔断期间的失败不会重新熔断
+	b.failure()
+
+	// 半开状态只允许一次发送，失败时重新熔断
+	if !b.halfOpen() {
+		t.Fatal("halfOpen() should change the state")
+	}
+	if !b.allow() || b.allow() {
+		t.Error("half-open breaker should allow exactly one trial")
+	}
+	b.failure()
+	if b.State() != BreakerOpen {
+		t.Fatalf("failed trial should reopen the breaker, state %s", b.State())
+	}
+
+	b.halfOpen()
+	b.allow()
+	b.success()
+	if b.halfOpen() {
+		t.Error("halfOpen() on a closed breaker should do nothing")
+	}
+
+	want := "closed->open,open->half-open,half-open->open,open->half-open,half-open->closed"
+	if got := strings.Join(*transitions, ","); got != want {
+		t.Errorf("transitions %s, want %s", got, want)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b, transitions := newTestBreaker(BreakerConfig{FailureThreshold: -1})
+	for i := 0; i < 10; i++ {
+		b.failure()
+	}
+	if !b.allow() || b.State() != BreakerClosed || len(*transitions) != 0 {
+		t.Errorf("disabled breaker should never open, state %s, transitions %v", b.State(), *transitions)
+	}
+}
+
+func TestBreakerOpenTimeout(t *testing.T) {
+	b, _ := newTestBreaker(BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Second, MaxOpenTimeout: 5 * time.Second})
+
+	// 连续熔断时熔断时间按指数增加，最长为 MaxOpenTimeout
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if i > 0 {
+			b.halfOpen()
+		}
+		b.failure()
+		if _, d := b.wait(); d > want || d < want-time.Second/2 {
+			t.Errorf("trip %d: open timeout %v, want %v", i+1, d, want)
+		}
+	}
+
+	// 恢复之后重新计算
+	b.halfOpen()
+	b.success()
+	b.failure()
+	if _, d := b.wait(); d > time.Second {
+		t.Errorf("open timeout %v after recovery, want %v", d, time.Second)
+	}
+}
+
+func TestBreakerConfigResolve(t *testing.T) {
+	var cfg BreakerConfig
+	cfg.resolve()
+	if cfg.FailureThreshold != DefaultFailureThreshold || cfg.OpenTimeout != DefaultOpenTimeout || cfg.MaxOpenTimeout != DefaultMaxOpenTimeout {
+		t.Errorf("resolve() = %+v, want the defaults", cfg)
+	}
+
+	cfg = BreakerConfig{FailureThreshold: -1, OpenTimeout: 2 * time.Minute}
+	cfg.resolve()
+	if cfg.FailureThreshold != -1 || cfg.MaxOpenTimeout != 2*time.Minute {
+		t.Errorf("resolve() = %+v, want the breaker disabled and MaxOpenTimeout raised to OpenTimeout", cfg)
+	}
+}
+
+func TestConnPoolWriteCountsOneFailure(t *testing.T) {
+	// 两个地址都接受连接，但发送总是失败，重试时会切换到另一个地址，最后没有可用地址
+	dial := func(network, address string, timeout time.Duration) (net.Conn, error) {
+		c, peer := net.Pipe()
+		_ = peer.Close()
+		return c, nil
+	}
+	s := Settings{
+		Timeout:   time.Second,
+		Endpoints: []Endpoint{{Addr: "127.0.0.1:1"}},
+		Breaker:   BreakerConfig{FailureThreshold: 3, OnStateChange: func(from, to BreakerState) {}},
+	}
+	s.resolve(t.TempDir())
+	cp := newConnPool(NetworkTCP, "127.0.0.1:2", &s, dial, 0)
+	defer cp.Close()
+	if err := cp.createConn(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 每次写入失败只计数一次，失败次数达到阈值之前不能熔断
+	for i := 0; i < s.Breaker.FailureThreshold-1; i++ {
+		if err := cp.write([]byte("<14>msg\n")); err == nil {
+			t.Fatalf("write #%d should fail", i)
+		}
+		if state := cp.breaker.State(); state != BreakerClosed {
+			t.Fatalf("breaker %s after %d failed writes, want closed", state, i+1)
+		}
+	}
+	if err := cp.write([]byte("<14>msg\n")); err == nil {
+		t.Fatal("write should fail")
+	}
+	if state := cp.breaker.State(); state != BreakerOpen {
+		t.Errorf("breaker %s after %d failed writes, want open", state, s.Breaker.FailureThreshold)
+	}
+}
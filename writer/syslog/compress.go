@@ -0,0 +1,53 @@
+package syslog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"sync"
+)
+
+// _recordGzip 记录头中长度字段的最高位，表示记录的数据使用 gzip 压缩
+const _recordGzip = 1 << 31
+
+// Gzip 本地缓存的每条记录使用 gzip 压缩，压缩后没有变小的记录不压缩。
+// 重新发送时自动解压，开启或关闭后之前写入的缓存仍然可以正常发送
+func Gzip() OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		handle.settingsRef().Gzip = true
+	}
+}
+
+var _gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// gzipEncode 压缩 data，压缩后没有变小时返回 false
+func gzipEncode(data []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	buf.Grow(len(data) / 4)
+	w := _gzipWriterPool.Get().(*gzip.Writer)
+	defer _gzipWriterPool.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(data); err != nil {
+		return data, false
+	}
+	if err := w.Close(); err != nil {
+		return data, false
+	}
+	if buf.Len() >= len(data) {
+		return data, false
+	}
+	return buf.Bytes(), true
+}
+
+func gzipDecode(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -1,89 +1,315 @@
 package syslog
 
 import (
+	"errors"
+	"fmt"
 	"github.com/weitrue/log/utils"
 	"net"
+	"sync"
 	"time"
 )
 
+var errNoAvailableConn = errors.New("log/syslog: no available connection")
+
 type dialFunc func(network, address string, timeout time.Duration) (net.Conn, error)
 
 type sysConn struct {
 	conn       net.Conn
-	createTime int64
-	lifeTime   int64
+	ep         *endpoint
+	createTime time.Time
+	lifeTime   time.Duration
 	timeOut    time.Duration
 }
 
 func (s *sysConn) setTimeout() {
-	s.conn.SetDeadline(time.Now().Add(s.timeOut * time.Millisecond))
+	s.conn.SetDeadline(time.Now().Add(s.timeOut))
 }
 
 func (s *sysConn) isOld() bool {
-	return time.Now().Unix()-s.createTime > s.lifeTime
+	return time.Since(s.createTime) > s.lifeTime
 }
 
+// connPool 连接池，每个地址单独维护空闲连接，通过 balancer 选择地址，通过 breaker 在连续失败时熔断
 type connPool struct {
-	connQueue     *queue
+	*balancer
+	breaker       *breaker
 	dialTimeoutFn dialFunc
 	timeout       time.Duration //发送超时时间
-	raddr         string        //连接地址
-	lifeTime      int64         //连接最大生存时间,默认是100毫秒
+	network       string        //传输方式
+	lifeTime      time.Duration //连接最大生存时间,默认是100秒
+
+	maxDatagramSize int // 数据报传输时单条消息的最大长度
+
+	stop      chan struct{}
+	closeOnce sync.Once
 }
 
+func newConnPool(network, raddr string, s *Settings, dialTimeoutFn dialFunc, maxDatagramSize int) *connPool {
+	eps := mergeEndpoints(raddr, s.Endpoints)
+	b := &balancer{
+		policy:     s.Policy,
+		interval:   s.HealthCheckInterval,
+		maxBackoff: s.MaxBackoff,
+	}
+	for _, ep := range eps {
+		b.endpoints = append(b.endpoints, newEndpoint(ep))
+	}
+	return &connPool{
+		balancer:        b,
+		breaker:         newBreaker(s.Breaker),
+		dialTimeoutFn:   dialTimeoutFn,
+		timeout:         s.Timeout,
+		network:         network,
+		lifeTime:        s.ConnLifeTime,
+		maxDatagramSize: maxDatagramSize,
+		stop:            make(chan struct{}),
+	}
+}
+
+func (cp *connPool) dial(addr string) (net.Conn, error) {
+	network := cp.network
+	if network == "" || network == NetworkTLS {
+		network = NetworkTCP
+	}
+	return cp.dialTimeoutFn(network, addr, cp.timeout)
+}
+
+// createConn 创建第一个连接并启动健康检查，所有地址都无法连接时返回最后一个错误
 func (cp *connPool) createConn() error {
-	conn, err := cp.dialTimeoutFn("tcp", cp.raddr, time.Millisecond*cp.timeout)
-	if err != nil {
-		_, _ = utils.ErrorOutput(err.Error())
-		cp.connQueue.Put("")
-		return err
+	var err error
+	for i := 0; i < len(cp.endpoints); i++ {
+		ep := cp.pick()
+		if ep == nil {
+			break
+		}
+		var conn net.Conn
+		conn, err = cp.dial(ep.Addr)
+		if err != nil {
+			cp.fail(ep, err)
+			continue
+		}
+		//将创建的连接放到连接池中
+		ep.conns.Put(cp.newSysConn(conn, ep))
+		go cp.healthCheck()
+		go cp.probe()
+		return nil
 	}
-	//将创建的连接放到连接池中
-	cp.connQueue.Put(&sysConn{conn: conn, createTime: time.Now().Unix(), lifeTime: cp.lifeTime, timeOut: cp.timeout})
-	return nil
+	if err == nil {
+		err = fmt.Errorf("log/syslog: no available endpoint")
+	}
+	_, _ = utils.ErrorOutput(err.Error())
+	return err
 }
 
+func (cp *connPool) newSysConn(conn net.Conn, ep *endpoint) *sysConn {
+	return &sysConn{conn: conn, ep: ep, createTime: time.Now(), lifeTime: cp.lifeTime, timeOut: cp.timeout}
+}
+
+// get 按策略选择地址并获取连接，熔断或者都失败时返回 nil，都失败时计入熔断器
 func (cp *connPool) get() *sysConn {
-	var c *sysConn
-	length := cp.connQueue.Size()
-	if length == 0 {
-		cp.createConn()
-		length = 1
-	}
-	for i := 0; i < length; i++ {
-		conn, ok := cp.connQueue.Get()
-		if !ok { // have no connect to use
-			continue
+	if !cp.breaker.allow() {
+		return nil
+	}
+	if conn := cp.acquire(); conn != nil {
+		return conn
+	}
+	cp.breaker.failure()
+	return nil
+}
+
+// acquire 按策略选择地址并获取连接，没有空闲连接时创建新的连接，连接失败时尝试下一个地址。
+// 都失败时返回 nil，不计入熔断器，由调用方负责
+func (cp *connPool) acquire() *sysConn {
+	for i := 0; i < len(cp.endpoints); i++ {
+		ep := cp.pick()
+		if ep == nil {
+			break
 		}
-		connect, ok := conn.(*sysConn)
-		if !ok { //conn is not sysconn
-			continue
+		for ep.conns.Size() > 0 {
+			conn, ok := ep.conns.Get()
+			if !ok { // have no connect to use
+				break
+			}
+			connect, ok := conn.(*sysConn)
+			if !ok { //conn is not sysconn
+				continue
+			}
+			if connect.isOld() {
+				connect.conn.Close()
+				continue
+			}
+			return connect
 		}
-		if connect.isOld() {
-			connect.conn.Close()
+		conn, err := cp.dial(ep.Addr)
+		if err != nil {
+			_, _ = utils.ErrorOutput(err.Error())
+			cp.fail(ep, err)
 			continue
 		}
-		c = connect
-		break
+		return cp.newSysConn(conn, ep)
+	}
+	return nil
+}
+
+// send 使用 conn 发送缓存中的数据，数据报传输时拆分为单条消息发送。发送失败时记录地址的失败次数，参考 balancer.fail
+func (cp *connPool) send(conn *sysConn, b []byte) error {
+	conn.setTimeout()
+	var err error
+	if isDatagram(cp.network) {
+		err = writeDatagrams(conn.conn, b, cp.maxDatagramSize)
+	} else {
+		_, err = conn.conn.Write(b)
+	}
+	switch {
+	case err == nil:
+		cp.success(conn.ep)
+	case !errors.Is(err, errInvalidFrame):
+		cp.fail(conn.ep, err)
+	}
+	return err
+}
+
+// write 获取连接并发送数据，数据格式错误时丢弃数据并返回 nil。
+// 发送失败时使用新建的连接重试一次，比如空闲连接已经被服务端关闭，重试仍然失败时才计入熔断器
+func (cp *connPool) write(b []byte) error {
+	conn := cp.get()
+	if conn == nil {
+		return errNoAvailableConn
+	}
+	err := cp.send(conn, b)
+	if err != nil && !errors.Is(err, errInvalidFrame) {
+		conn.conn.Close()
+		if conn = cp.redial(conn.ep); conn == nil {
+			cp.breaker.failure()
+			return err
+		}
+		err = cp.send(conn, b)
+	}
+	switch {
+	case err == nil:
+		cp.breaker.success()
+	case !errors.Is(err, errInvalidFrame):
+		cp.breaker.failure()
+	}
+	if errors.Is(err, errInvalidFrame) {
+		// 数据格式错误，重新发送也不会成功，直接丢弃
+		_, _ = utils.ErrorOutput("syslog drop invalid data:" + err.Error())
+		cp.put(conn)
+		return nil
+	}
+	if err != nil {
+		conn.conn.Close()
+		return err
 	}
-	return c
+	cp.put(conn)
+	return nil
+}
+
+// redial 发送失败后创建新的连接，地址仍然可用时重新连接该地址，否则按策略选择其他地址。
+// 失败时不计入熔断器，由 write 统一记录
+func (cp *connPool) redial(ep *endpoint) *sysConn {
+	if !cp.isHealthy(ep) {
+		return cp.acquire()
+	}
+	conn, err := cp.dial(ep.Addr)
+	if err != nil {
+		_, _ = utils.ErrorOutput(err.Error())
+		cp.fail(ep, err)
+		return nil
+	}
+	return cp.newSysConn(conn, ep)
 }
 
 func (cp *connPool) put(conn *sysConn) {
-	cp.connQueue.Put(conn)
+	conn.ep.conns.Put(conn)
 }
 
-func (cp *connPool) Close() {
-	for !cp.connQueue.Empty() {
-		conn, ok := cp.connQueue.Get()
-		if !ok { // have no connect to use
-			continue
+// healthCheck 定时检查不可用的地址，连接成功时恢复
+func (cp *connPool) healthCheck() {
+	defer utils.CatchPanic()
+	ticker := time.NewTicker(cp.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cp.stop:
+			return
+		case <-ticker.C:
+		}
+		for _, ep := range cp.due() {
+			conn, err := cp.dial(ep.Addr)
+			if err != nil {
+				cp.fail(ep, err)
+				continue
+			}
+			_ = conn.Close()
+			cp.recover(ep)
 		}
-		connect, ok := conn.(*sysConn)
-		if !ok { //conn is not sysconn
+	}
+}
+
+// probe 熔断时间结束后进入半开状态并检查所有地址，有一个地址可以连接时关闭熔断器，否则重新熔断
+func (cp *connPool) probe() {
+	defer utils.CatchPanic()
+	for {
+		select {
+		case <-cp.stop:
+			return
+		case <-cp.breaker.opened:
+		}
+		for {
+			state, d := cp.breaker.wait()
+			if state != BreakerOpen {
+				break
+			}
+			if d > 0 {
+				t := time.NewTimer(d)
+				select {
+				case <-cp.stop:
+					t.Stop()
+					return
+				case <-t.C:
+				}
+			}
+			if !cp.breaker.halfOpen() {
+				continue
+			}
+			if cp.probeEndpoints() {
+				cp.breaker.success()
+			} else {
+				cp.breaker.failure()
+			}
+		}
+	}
+}
+
+// probeEndpoints 按顺序连接所有地址，连接成功的地址恢复可用
+func (cp *connPool) probeEndpoints() bool {
+	for _, ep := range cp.endpoints {
+		conn, err := cp.dial(ep.Addr)
+		if err != nil {
+			cp.fail(ep, err)
 			continue
 		}
-		connect.conn.Close()
+		_ = conn.Close()
+		if !cp.isHealthy(ep) {
+			cp.recover(ep)
+		}
+		return true
+	}
+	return false
+}
+
+// Stats 返回所有地址的状态
+func (cp *connPool) Stats() []EndpointStats {
+	return cp.stats()
+}
+
+func (cp *connPool) Close() {
+	cp.closeOnce.Do(func() {
+		close(cp.stop)
+	})
+	for _, ep := range cp.endpoints {
+		ep.closeIdle()
+		ep.conns.Close()
 	}
-	cp.connQueue.Close()
 }
@@ -0,0 +1,76 @@
+package syslog
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+// lineServer 按行读取数据的 tcp 服务
+func lineServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	lines := make(chan string, 16)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				s := bufio.NewScanner(c)
+				for s.Scan() {
+					lines <- s.Text()
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), lines
+}
+
+func TestConnPoolWriteRetry(t *testing.T) {
+	addr, lines := lineServer(t)
+	// 第一个连接模拟已经被服务端关闭的空闲连接
+	dials := 0
+	dial := func(network, address string, timeout time.Duration) (net.Conn, error) {
+		if dials++; dials == 1 {
+			c, peer := net.Pipe()
+			_ = peer.Close()
+			return c, nil
+		}
+		return net.DialTimeout(network, address, timeout)
+	}
+	s := Settings{Timeout: time.Second}
+	s.resolve(t.TempDir())
+	cp := newConnPool(NetworkTCP, addr, &s, dial, 0)
+	defer cp.Close()
+	if err := cp.createConn(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := cp.write([]byte("<14>msg\n")); err != nil {
+			t.Fatalf("write #%d: %v", i, err)
+		}
+		select {
+		case line := <-lines:
+			if line != "<14>msg" {
+				t.Fatalf("unexpected line %q", line)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("write #%d not received", i)
+		}
+	}
+	if st := cp.Stats()[0]; !st.Healthy {
+		t.Errorf("endpoint should stay healthy after a retried error: %+v", st)
+	}
+	if state := cp.breaker.State(); state != BreakerClosed {
+		t.Errorf("breaker state = %s, want closed", state)
+	}
+}
@@ -0,0 +1,318 @@
+package syslog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weitrue/log/utils"
+)
+
+// Policy 多个地址时的选择策略
+type Policy int
+
+const (
+	// PolicyFailover 默认策略，按顺序使用第一个可用的地址，前面的地址恢复后自动切换回去
+	PolicyFailover Policy = iota
+	// PolicyRoundRobin 轮流使用所有可用的地址
+	PolicyRoundRobin
+	// PolicyWeighted 按 Endpoint.Weight 的比例使用所有可用的地址
+	PolicyWeighted
+)
+
+// ParsePolicy 根据名称获取策略：failover、roundrobin、weighted，不区分大小写
+func ParsePolicy(name string) (Policy, error) {
+	switch strings.ToLower(name) {
+	case "", "failover":
+		return PolicyFailover, nil
+	case "roundrobin":
+		return PolicyRoundRobin, nil
+	case "weighted":
+		return PolicyWeighted, nil
+	}
+	return PolicyFailover, fmt.Errorf("log/syslog: unknown policy %q", name)
+}
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyRoundRobin:
+		return "roundrobin"
+	case PolicyWeighted:
+		return "weighted"
+	default:
+		return "failover"
+	}
+}
+
+// 健康检查的默认间隔，失败后按指数退避重试，最长为 DefaultMaxBackoff
+const (
+	DefaultHealthCheckInterval = time.Second
+	DefaultMaxBackoff          = 30 * time.Second
+)
+
+// Endpoint syslog 服务地址，Weight 只在 PolicyWeighted 时生效，小于 1 时为 1
+type Endpoint struct {
+	Addr   string
+	Weight int
+}
+
+// EndpointStats 地址的状态，通过 Stats 获取
+type EndpointStats struct {
+	Addr    string
+	Weight  int
+	Healthy bool
+	// Failures 连续失败的次数，恢复后清零
+	Failures int
+	// Sent、Errors 发送成功、失败的次数，每次发送的数据可能包含多条日志
+	Sent   uint64
+	Errors uint64
+	// LastError 最近一次失败的原因
+	LastError string
+	// NextCheck 不可用时下一次健康检查的时间
+	NextCheck time.Time
+}
+
+// Endpoints 设置备用地址以及选择策略，Dial 的 raddr 为第一个地址，列表中包含相同地址时使用列表中的权重
+func Endpoints(policy Policy, endpoints ...Endpoint) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		s := handle.settingsRef()
+		s.Policy = policy
+		s.Endpoints = endpoints
+	}
+}
+
+// HealthCheck 设置不可用地址的健康检查间隔，连续失败时间隔按指数增加，最长为 maxBackoff
+func HealthCheck(interval, maxBackoff time.Duration) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		s := handle.settingsRef()
+		s.HealthCheckInterval = interval
+		s.MaxBackoff = maxBackoff
+	}
+}
+
+// mergeEndpoints 将 raddr 放在第一个，并去掉重复的地址
+func mergeEndpoints(raddr string, endpoints []Endpoint) []Endpoint {
+	merged := []Endpoint{{Addr: raddr, Weight: 1}}
+	index := map[string]int{raddr: 0}
+	for _, ep := range endpoints {
+		if ep.Weight < 1 {
+			ep.Weight = 1
+		}
+		if i, ok := index[ep.Addr]; ok {
+			merged[i].Weight = ep.Weight
+			continue
+		}
+		index[ep.Addr] = len(merged)
+		merged = append(merged, ep)
+	}
+	return merged
+}
+
+// endpoint 地址的连接以及健康状态
+type endpoint struct {
+	Endpoint
+	conns *queue // 空闲的连接
+
+	// 以下字段由 balancer.mu 保护
+	healthy       bool
+	failures      int
+	sent          uint64
+	errors        uint64
+	lastError     string
+	nextCheck     time.Time
+	currentWeight int // 平滑加权轮询使用
+}
+
+func newEndpoint(ep Endpoint) *endpoint {
+	return &endpoint{
+		Endpoint: ep,
+		conns:    NewQueue(30, time.Millisecond*10),
+		healthy:  true,
+	}
+}
+
+// closeIdle 关闭所有空闲的连接
+func (ep *endpoint) closeIdle() {
+	for ep.conns.Size() > 0 {
+		v, ok := ep.conns.Get()
+		if !ok {
+			return
+		}
+		if c, ok := v.(*sysConn); ok {
+			_ = c.conn.Close()
+		}
+	}
+}
+
+// balancer 按 Policy 选择地址并维护地址的健康状态
+type balancer struct {
+	mu        sync.Mutex
+	policy    Policy
+	endpoints []*endpoint
+	next      int // 轮询的位置
+
+	interval   time.Duration
+	maxBackoff time.Duration
+}
+
+// pick 按策略选择一个可用的地址，没有可用地址时返回 nil
+func (b *balancer) pick() *endpoint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.policy {
+	case PolicyRoundRobin:
+		for i := 0; i < len(b.endpoints); i++ {
+			ep := b.endpoints[b.next%len(b.endpoints)]
+			b.next++
+			if ep.healthy {
+				return ep
+			}
+		}
+		return nil
+	case PolicyWeighted:
+		// 平滑加权轮询，参考 nginx
+		var best *endpoint
+		total := 0
+		for _, ep := range b.endpoints {
+			if !ep.healthy {
+				continue
+			}
+			ep.currentWeight += ep.Weight
+			total += ep.Weight
+			if best == nil || ep.currentWeight > best.currentWeight {
+				best = ep
+			}
+		}
+		if best != nil {
+			best.currentWeight -= total
+		}
+		return best
+	default:
+		for _, ep := range b.endpoints {
+			if ep.healthy {
+				return ep
+			}
+		}
+		return nil
+	}
+}
+
+// _lastHealthyFailures 最后一个可用的地址连续失败多少次后才标记为不可用
+const _lastHealthyFailures = 2
+
+// success 记录发送成功，清零连续失败的次数
+func (b *balancer) success(ep *endpoint) {
+	b.mu.Lock()
+	ep.sent++
+	if ep.healthy {
+		ep.failures = 0
+	}
+	b.mu.Unlock()
+}
+
+// fail 记录连接或者发送失败，将地址标记为不可用，等待健康检查恢复。
+// 最后一个可用的地址连续失败 _lastHealthyFailures 次才标记为不可用，避免偶尔的错误使所有数据都进入本地缓存
+func (b *balancer) fail(ep *endpoint, err error) {
+	b.mu.Lock()
+	ep.errors++
+	ep.failures++
+	ep.lastError = err.Error()
+	if ep.healthy && ep.failures < _lastHealthyFailures && b.healthyCount() == 1 {
+		b.mu.Unlock()
+		return
+	}
+	ep.nextCheck = time.Now().Add(b.backoff(ep.failures))
+	wasHealthy := ep.healthy
+	ep.healthy = false
+	ep.currentWeight = 0
+	b.mu.Unlock()
+
+	if wasHealthy {
+		_, _ = utils.ErrorOutput(fmt.Sprintf("syslog endpoint %s is down: %s", ep.Addr, err.Error()))
+		ep.closeIdle()
+	}
+}
+
+// backoff 第 failures 次失败后的健康检查间隔
+func (b *balancer) backoff(failures int) time.Duration {
+	d := b.interval
+	for i := 1; i < failures && d < b.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > b.maxBackoff {
+		d = b.maxBackoff
+	}
+	return d
+}
+
+// healthyCount 可用的地址数量，调用方需要持有 b.mu
+func (b *balancer) healthyCount() int {
+	n := 0
+	for _, ep := range b.endpoints {
+		if ep.healthy {
+			n++
+		}
+	}
+	return n
+}
+
+func (b *balancer) isHealthy(ep *endpoint) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return ep.healthy
+}
+
+// due 返回需要进行健康检查的地址
+func (b *balancer) due() []*endpoint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	var eps []*endpoint
+	for _, ep := range b.endpoints {
+		if !ep.healthy && !now.Before(ep.nextCheck) {
+			eps = append(eps, ep)
+		}
+	}
+	return eps
+}
+
+// recover 健康检查成功，地址恢复可用。PolicyFailover 时后面地址的空闲连接会被关闭，使新的数据发送到恢复的地址
+func (b *balancer) recover(ep *endpoint) {
+	b.mu.Lock()
+	ep.healthy = true
+	ep.failures = 0
+	ep.nextCheck = time.Time{}
+	var idle []*endpoint
+	if b.policy == PolicyFailover {
+		for i := len(b.endpoints) - 1; i >= 0 && b.endpoints[i] != ep; i-- {
+			idle = append(idle, b.endpoints[i])
+		}
+	}
+	b.mu.Unlock()
+
+	_, _ = utils.ErrorOutput(fmt.Sprintf("syslog endpoint %s recovered", ep.Addr))
+	for _, e := range idle {
+		e.closeIdle()
+	}
+}
+
+// stats 返回所有地址的状态
+func (b *balancer) stats() []EndpointStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := make([]EndpointStats, 0, len(b.endpoints))
+	for _, ep := range b.endpoints {
+		stats = append(stats, EndpointStats{
+			Addr:      ep.Addr,
+			Weight:    ep.Weight,
+			Healthy:   ep.healthy,
+			Failures:  ep.failures,
+			Sent:      ep.sent,
+			Errors:    ep.errors,
+			LastError: ep.lastError,
+			NextCheck: ep.nextCheck,
+		})
+	}
+	return stats
+}
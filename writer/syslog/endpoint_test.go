@@ -0,0 +1,91 @@
+package syslog
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBalancerFail(t *testing.T) {
+	errSend := errors.New("send failed")
+	tests := []struct {
+		name      string
+		endpoints int
+		failures  int // 对第一个地址连续调用 fail 的次数
+		healthy   bool
+	}{
+		{"single endpoint, one error", 1, 1, true},
+		{"single endpoint, two errors", 1, 2, false},
+		{"two endpoints, one error", 2, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &balancer{interval: time.Second, maxBackoff: time.Minute}
+			for i := 0; i < tt.endpoints; i++ {
+				b.endpoints = append(b.endpoints, newEndpoint(Endpoint{Addr: "127.0.0.1:0", Weight: 1}))
+			}
+			ep := b.endpoints[0]
+			for i := 0; i < tt.failures; i++ {
+				b.fail(ep, errSend)
+			}
+			if got := b.isHealthy(ep); got != tt.healthy {
+				t.Errorf("healthy = %v, want %v", got, tt.healthy)
+			}
+			if st := b.stats()[0]; st.Errors != uint64(tt.failures) || st.LastError != errSend.Error() {
+				t.Errorf("unexpected stats %+v", st)
+			}
+		})
+	}
+}
+
+func TestBalancerSuccessResetsFailures(t *testing.T) {
+	b := &balancer{interval: time.Second, maxBackoff: time.Minute}
+	ep := newEndpoint(Endpoint{Addr: "127.0.0.1:0", Weight: 1})
+	b.endpoints = []*endpoint{ep}
+	b.fail(ep, errors.New("send failed"))
+	b.success(ep)
+	b.fail(ep, errors.New("send failed"))
+	if !b.isHealthy(ep) {
+		t.Error("failures separated by a success should not mark the last endpoint down")
+	}
+}
+
+func TestPick(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		weights []int
+		down    []int
+		want    []int // 连续 pick 得到的地址下标
+	}{
+		{"failover", PolicyFailover, []int{1, 1, 1}, nil, []int{0, 0, 0}},
+		{"failover skips down", PolicyFailover, []int{1, 1, 1}, []int{0}, []int{1, 1}},
+		{"roundrobin", PolicyRoundRobin, []int{1, 1, 1}, []int{1}, []int{0, 2, 0, 2}},
+		{"weighted", PolicyWeighted, []int{2, 1}, nil, []int{0, 1, 0, 0, 1, 0}},
+		{"all down", PolicyFailover, []int{1}, []int{0}, []int{-1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &balancer{policy: tt.policy}
+			for _, w := range tt.weights {
+				b.endpoints = append(b.endpoints, newEndpoint(Endpoint{Weight: w}))
+			}
+			for _, i := range tt.down {
+				b.endpoints[i].healthy = false
+			}
+			for n, want := range tt.want {
+				got := -1
+				if ep := b.pick(); ep != nil {
+					for i, e := range b.endpoints {
+						if e == ep {
+							got = i
+						}
+					}
+				}
+				if got != want {
+					t.Fatalf("pick #%d = %d, want %d", n, got, want)
+				}
+			}
+		})
+	}
+}
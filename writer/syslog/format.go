@@ -0,0 +1,218 @@
+package syslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Format syslog 消息头格式
+type Format int
+
+const (
+	// FormatLegacy 默认格式，只在日志前增加 "<PRI>"
+	FormatLegacy Format = iota
+	// FormatRFC5424 RFC 5424 格式：<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	FormatRFC5424
+)
+
+// Framing tcp 传输时的消息分帧方式，参考 RFC 6587
+type Framing int
+
+const (
+	// FramingNonTransparent 默认方式，每条消息以换行符结尾，消息中包含换行符（比如多行的堆栈信息）时会被拆分为多条
+	FramingNonTransparent Framing = iota
+	// FramingOctetCounting 每条消息前增加 "消息长度 "，消息中可以包含换行符
+	FramingOctetCounting
+)
+
+// RFC 5424 中各字段的最大长度
+const (
+	_maxHostnameLen = 255
+	_maxAppNameLen  = 48
+	_maxProcIDLen   = 128
+	_maxMsgIDLen    = 32
+	_maxSDNameLen   = 32
+
+	_nilValue       = "-"
+	_rfc5424TimeFmt = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// MessageFormat syslog 消息格式设置，通过 WithMessageFormat 设置
+type MessageFormat struct {
+	Format  Format
+	Framing Framing
+
+	// 以下字段只在 FormatRFC5424 时生效，为空时：
+	// Hostname 使用 os.Hostname，AppName 使用程序文件名，ProcID 使用进程 ID，MsgID 输出为 "-"
+	Hostname string
+	AppName  string
+	ProcID   string
+	MsgID    string
+
+	// SDID STRUCTURED-DATA 的 SD-ID，比如 "fields@32473"，为空或 SDFields 为空时不输出 STRUCTURED-DATA
+	SDID string
+	// SDFields 输出到 STRUCTURED-DATA 中的字段，从 json 格式日志的第一层字段中获取，
+	// 只对 json 类的编码器（json、ecs、gelf）生效，日志中没有的字段不输出
+	SDFields []string
+}
+
+// WithMessageFormat 设置 syslog 消息格式，默认为 FormatLegacy 和 FramingNonTransparent
+func WithMessageFormat(mf MessageFormat) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		handle.SetMessageFormat(mf)
+	}
+}
+
+// framer 按 MessageFormat 生成 syslog 消息
+type framer struct {
+	MessageFormat
+	// header RFC 5424 中 HOSTNAME APP-NAME PROCID MSGID 部分，不会变化，提前生成
+	header string
+}
+
+func newFramer(mf MessageFormat) *framer {
+	f := &framer{MessageFormat: mf}
+	if mf.Format != FormatRFC5424 {
+		return f
+	}
+	hostname := mf.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	appName := mf.AppName
+	if appName == "" {
+		appName = filepath.Base(os.Args[0])
+	}
+	procID := mf.ProcID
+	if procID == "" {
+		procID = strconv.Itoa(os.Getpid())
+	}
+	f.header = headerField(hostname, _maxHostnameLen) + " " +
+		headerField(appName, _maxAppNameLen) + " " +
+		headerField(procID, _maxProcIDLen) + " " +
+		headerField(mf.MsgID, _maxMsgIDLen)
+	f.SDID = sdName(mf.SDID)
+	return f
+}
+
+// headerField 生成 RFC 5424 消息头字段，只保留可见的 ASCII 字符，为空时返回 "-"
+func headerField(s string, maxLen int) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(b) < maxLen; i++ {
+		if s[i] > 32 && s[i] < 127 {
+			b = append(b, s[i])
+		}
+	}
+	if len(b) == 0 {
+		return _nilValue
+	}
+	return string(b)
+}
+
+// sdName 生成 SD-ID、PARAM-NAME，去掉 '='、']'、'"' 以及不可见字符，为空时返回 "-"
+func sdName(s string) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(b) < _maxSDNameLen; i++ {
+		switch c := s[i]; {
+		case c <= 32, c >= 127, c == '=', c == ']', c == '"':
+		default:
+			b = append(b, c)
+		}
+	}
+	if len(b) == 0 {
+		return _nilValue
+	}
+	return string(b)
+}
+
+// appendMessage 将 msg 按设置的格式和分帧方式追加到 dst 中，t 为 RFC 5424 消息的 TIMESTAMP
+func (f *framer) appendMessage(dst []byte, priority Priority, t time.Time, msg []byte) []byte {
+	if f.Framing == FramingOctetCounting {
+		msg = bytes.TrimRight(msg, "\n")
+		start := len(dst)
+		dst = f.appendSyslogMsg(dst, priority, t, msg)
+		n := len(dst) - start
+		// 在消息前插入 "消息长度 "
+		prefix := strconv.AppendInt(nil, int64(n), 10)
+		prefix = append(prefix, ' ')
+		dst = append(dst, prefix...)
+		copy(dst[start+len(prefix):], dst[start:start+n])
+		copy(dst[start:], prefix)
+		return dst
+	}
+	dst = f.appendSyslogMsg(dst, priority, t, msg)
+	if len(msg) == 0 || msg[len(msg)-1] != '\n' {
+		dst = append(dst, '\n')
+	}
+	return dst
+}
+
+func (f *framer) appendSyslogMsg(dst []byte, priority Priority, t time.Time, msg []byte) []byte {
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(priority), 10)
+	dst = append(dst, '>')
+	if f.Format != FormatRFC5424 {
+		return append(dst, msg...)
+	}
+	dst = append(dst, '1', ' ')
+	dst = t.AppendFormat(dst, _rfc5424TimeFmt)
+	dst = append(dst, ' ')
+	dst = append(dst, f.header...)
+	dst = append(dst, ' ')
+	dst = f.appendStructuredData(dst, msg)
+	if len(msg) > 0 {
+		dst = append(dst, ' ')
+		dst = append(dst, msg...)
+	}
+	return dst
+}
+
+// appendStructuredData 从 json 格式的日志中获取 SDFields 对应的字段，生成 [SD-ID name="value" ...]
+func (f *framer) appendStructuredData(dst []byte, msg []byte) []byte {
+	if f.SDID == _nilValue || len(f.SDFields) == 0 {
+		return append(dst, _nilValue...)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg, &fields); err != nil {
+		return append(dst, _nilValue...)
+	}
+	start := len(dst)
+	dst = append(dst, '[')
+	dst = append(dst, f.SDID...)
+	for _, name := range f.SDFields {
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		dst = append(dst, ' ')
+		dst = append(dst, sdName(name)...)
+		dst = append(dst, '=', '"')
+		dst = appendParamValue(dst, value)
+		dst = append(dst, '"')
+	}
+	if len(dst)-start == len(f.SDID)+1 {
+		// 没有任何字段
+		return append(dst[:start], _nilValue...)
+	}
+	return append(dst, ']')
+}
+
+// appendParamValue 按 RFC 5424 转义 PARAM-VALUE 中的 '"'、'\' 和 ']'
+func appendParamValue(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\\', ']':
+			dst = append(dst, '\\')
+		}
+		dst = append(dst, s[i])
+	}
+	return dst
+}
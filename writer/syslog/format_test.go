@@ -0,0 +1,132 @@
+package syslog
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFramerLegacy(t *testing.T) {
+	msgs := []string{"msg\n", "", "a\n  stack\n"}
+
+	f := newFramer(MessageFormat{Framing: FramingNonTransparent})
+	var dst []byte
+	for _, msg := range msgs {
+		dst = f.appendMessage(dst, LOG_LOCAL0|LOG_INFO, time.Now(), []byte(msg))
+	}
+	if got, want := string(dst), "<134>msg\n<134>\n<134>a\n  stack\n"; got != want {
+		t.Errorf("non-transparent framing got %q, want %q", got, want)
+	}
+
+	// octet counting 不需要换行符分隔，结尾的换行符会被去掉
+	f = newFramer(MessageFormat{Framing: FramingOctetCounting})
+	dst = dst[:0]
+	for _, msg := range msgs {
+		dst = f.appendMessage(dst, LOG_LOCAL0|LOG_INFO, time.Now(), []byte(msg))
+	}
+	if got, want := string(dst), "8 <134>msg5 <134>14 <134>a\n  stack"; got != want {
+		t.Errorf("octet counting got %q, want %q", got, want)
+	}
+}
+
+// splitRFC5424 拆分 RFC 5424 消息为 "<PRI>1"、TIMESTAMP 以及之后的部分
+func splitRFC5424(t *testing.T, msg string) (string, time.Time, string) {
+	t.Helper()
+	parts := strings.SplitN(msg, " ", 3)
+	if len(parts) != 3 {
+		t.Fatalf("malformed message %q", msg)
+	}
+	ts, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		t.Fatalf("bad timestamp in %q: %v", msg, err)
+	}
+	return parts[0], ts, parts[2]
+}
+
+func TestFramerRFC5424(t *testing.T) {
+	mf := MessageFormat{Format: FormatRFC5424, Hostname: "my host\t", AppName: strings.Repeat("a", 60), ProcID: "42"}
+	f := newFramer(mf)
+
+	// TIMESTAMP 使用日志时间而不是生成消息的时间，精确到微秒并保留时区
+	entryTime := time.Date(2021, 3, 4, 5, 6, 7, 123456789, time.FixedZone("CST", 8*3600))
+	msg := string(f.appendMessage(nil, LOG_LOCAL0|LOG_ERR, entryTime, []byte("msg\n")))
+	header, ts, rest := splitRFC5424(t, msg)
+	if header != "<131>1" {
+		t.Errorf("header %q, want <131>1", header)
+	}
+	if !ts.Equal(entryTime.Truncate(time.Microsecond)) {
+		t.Errorf("timestamp %v, want the entry time %v", ts, entryTime)
+	}
+	if !strings.Contains(msg, " 2021-03-04T05:06:07.123456+08:00 ") {
+		t.Errorf("timestamp not formatted as RFC 5424 in %q", msg)
+	}
+	// 非法字符被去掉，超长的字段被截断
+	if want := "myhost " + strings.Repeat("a", _maxAppNameLen) + " 42 - - msg\n"; rest != want {
+		t.Errorf("got %q, want %q", rest, want)
+	}
+
+	mf = MessageFormat{Format: FormatRFC5424, Hostname: "host", AppName: "app", ProcID: "42", MsgID: "audit"}
+	if _, _, rest = splitRFC5424(t, string(newFramer(mf).appendMessage(nil, LOG_LOCAL0|LOG_ERR, time.Now(), nil))); rest != "host app 42 audit -\n" {
+		t.Errorf("empty message got %q", rest)
+	}
+}
+
+func TestFramerRFC5424StructuredData(t *testing.T) {
+	mf := MessageFormat{
+		Format:   FormatRFC5424,
+		Hostname: "host",
+		AppName:  "app",
+		ProcID:   "42",
+		SDID:     "fields@32473",
+		SDFields: []string{"trace", "code", "missing"},
+	}
+	f := newFramer(mf)
+	frame := func(msg string) string {
+		_, _, rest := splitRFC5424(t, string(f.appendMessage(nil, LOG_LOCAL0|LOG_ERR, time.Now(), []byte(msg))))
+		return rest
+	}
+
+	// 参数值中的 "、\、] 需要转义，缺少的字段不输出
+	msg := `{"msg":"hi","trace":"a\"b]c","code":7}`
+	if got, want := frame(msg+"\n"), `host app 42 - [fields@32473 trace="a\"b\]c" code="7"] `+msg+"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// 没有任何字段，或者消息不是 json 时，STRUCTURED-DATA 为 "-"
+	if got, want := frame(`{"msg":"hi"}`), `host app 42 - - {"msg":"hi"}`+"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := frame("trace=1"), "host app 42 - - trace=1\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFramerRFC5424OctetCounting(t *testing.T) {
+	f := newFramer(MessageFormat{Format: FormatRFC5424, Framing: FramingOctetCounting, Hostname: "host"})
+	got := string(f.appendMessage(nil, LOG_LOCAL0|LOG_INFO, time.Now(), []byte("a\nb\n")))
+	i := strings.IndexByte(got, ' ')
+	n, err := strconv.Atoi(got[:i])
+	if err != nil {
+		t.Fatalf("bad length prefix in %q", got)
+	}
+	if n != len(got)-i-1 {
+		t.Errorf("length prefix %d, message is %d bytes", n, len(got)-i-1)
+	}
+	if !strings.HasSuffix(got, " a\nb") {
+		t.Errorf("got %q, want suffix %q", got, " a\nb")
+	}
+}
+
+func TestSDName(t *testing.T) {
+	for in, want := range map[string]string{
+		"fields@32473":          "fields@32473",
+		`a=b]c"d e`:             "abcde",
+		"":                      _nilValue,
+		"==":                    _nilValue,
+		strings.Repeat("x", 40): strings.Repeat("x", _maxSDNameLen),
+	} {
+		if got := sdName(in); got != want {
+			t.Errorf("sdName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -1,11 +1,19 @@
 package syslog
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"github.com/weitrue/log/level"
 	"go.uber.org/atomic"
+	"hash/crc32"
 	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 var uswManager = map[string]*UniqueSyslogWriter{}
@@ -14,12 +22,38 @@ var uswManagerLock = &sync.Mutex{}
 type SyslogHandleWriter interface {
 	io.WriteCloser
 	Sync() error
+	// WriteLevel 根据日志等级设置消息的 severity，参考 Severity，t 为日志时间，用于 RFC 5424 消息的 TIMESTAMP
+	WriteLevel(lvl level.Level, t time.Time, b []byte) (int, error)
 	SetDialTimeoutFn(dialFunc)
+	SetMessageFormat(MessageFormat)
+	SetFacility(Priority)
+	SetNetwork(string)
+	SetTLSConfig(*tls.Config)
+	SetMaxDatagramSize(int)
+	// Settings 返回生效的设置，包括通过 OptionFunc 设置的值以及从环境变量获取的值
+	Settings() Settings
+	settingsRef() *Settings
+	// Stats 返回所有地址的状态，第一个为 Dial 的地址
+	Stats() []EndpointStats
+	// BreakerState 返回熔断器状态
+	BreakerState() BreakerState
+	// ReplayStatus 返回本地缓存的状态
+	ReplayStatus() ReplayStatus
+	// DropStats 返回因为本地缓存大小限制丢弃的数据
+	DropStats() DropStats
+
+	init() error
+	// identity 返回通过 OptionFunc 设置的选项的标识，选项相同的 writer 才会被复用
+	identity() string
+	defaultBufferDir() string
+	// adoptSpool 导入其他缓存目录中未发送的数据
+	adoptSpool(dir string)
 }
 
 type UniqueSyslogWriter struct {
 	SyslogHandleWriter
-	id string
+	id  string
+	key string // uswManager 中的 key，包括 id 和选项的标识
 	// 引用计数
 	count *atomic.Int32
 }
@@ -29,7 +63,7 @@ func (usw *UniqueSyslogWriter) Close() (err error) {
 	// 引用删除，调用 客户端 的 close 方法
 	if r <= 0 {
 		uswManagerLock.Lock()
-		delete(uswManager, usw.id)
+		delete(uswManager, usw.key)
 		uswManagerLock.Unlock()
 
 		return usw.SyslogHandleWriter.Close()
@@ -37,6 +71,11 @@ func (usw *UniqueSyslogWriter) Close() (err error) {
 	return
 }
 
+// String 返回 syslog 的描述信息，格式为 syslog:v版本号-地址
+func (usw *UniqueSyslogWriter) String() string {
+	return "syslog:" + usw.id
+}
+
 func (usw *UniqueSyslogWriter) Reference() {
 	usw.count.Inc()
 }
@@ -60,7 +99,7 @@ func ClearSyslogWriter() {
 		// 引用删除，调用 客户端 的 close 方法
 		if r <= 0 {
 			_ = w.SyslogHandleWriter.Close()
-			delete(uswManager, w.id)
+			delete(uswManager, w.key)
 		}
 	}
 }
@@ -69,60 +108,188 @@ var syslogLevM = map[string]Priority{
 	"DEBUG":    LOG_DEBUG,
 	"INFO":     LOG_INFO,
 	"ERROR":    LOG_ERR,
+	"WARN":     LOG_WARNING,
 	"WARNING":  LOG_WARNING,
 	"CRITICAL": LOG_CRIT,
+	"PANIC":    LOG_ALERT,
+	"FATAL":    LOG_EMERG,
 	"FIXED":    LOG_ALERT,
 }
 
+// Severity 根据日志等级获取 syslog 的 severity，未知的等级返回 LOG_INFO
+func Severity(lvl level.Level) Priority {
+	if p, ok := syslogLevM[level.Level2CapitalName(lvl)]; ok {
+		return p
+	}
+	return LOG_INFO
+}
+
+var syslogFacilityM = map[string]Priority{
+	"kern":     LOG_KERN,
+	"user":     LOG_USER,
+	"mail":     LOG_MAIL,
+	"daemon":   LOG_DAEMON,
+	"auth":     LOG_AUTH,
+	"syslog":   LOG_SYSLOG,
+	"lpr":      LOG_LPR,
+	"news":     LOG_NEWS,
+	"uucp":     LOG_UUCP,
+	"cron":     LOG_CRON,
+	"authpriv": LOG_AUTHPRIV,
+	"ftp":      LOG_FTP,
+	"local0":   LOG_LOCAL0,
+	"local1":   LOG_LOCAL1,
+	"local2":   LOG_LOCAL2,
+	"local3":   LOG_LOCAL3,
+	"local4":   LOG_LOCAL4,
+	"local5":   LOG_LOCAL5,
+	"local6":   LOG_LOCAL6,
+	"local7":   LOG_LOCAL7,
+}
+
+// ParseFacility 根据名称获取 facility，比如 "local0"、"daemon"，不区分大小写
+func ParseFacility(name string) (Priority, error) {
+	f, ok := syslogFacilityM[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("log/syslog: unknown facility %q", name)
+	}
+	return f, nil
+}
+
+// DialByLevel 创建或者复用 syslog writer，版本、地址以及 OptionFunc 设置的选项都相同时复用已经创建的 writer，
+// level 只在第一次创建时生效。多个 writer 不能使用同一个本地缓存目录，目录已经被其他 writer 使用时
+// （比如重新加载配置时新旧 writer 同时存在）使用 "目录.1"、"目录.2" 等，并导入这些目录中没有 writer 使用的缓存数据
 func DialByLevel(ver int, network, raddr string, level string, opts ...OptionFunc) (*UniqueSyslogWriter, error) {
 	uswManagerLock.Lock()
 	defer uswManagerLock.Unlock()
 
 	id := fmt.Sprintf("v%d-%s", ver, raddr)
-	w, ok := uswManager[id]
-	// client 已经存在
-	if ok {
-		w.Reference()
-		return w, nil
+	if network != NetworkTCP {
+		id = fmt.Sprintf("v%d-%s://%s", ver, network, raddr)
 	}
 
 	l, ok := syslogLevM[level]
 	if !ok {
 		l = LOG_INFO
 	}
-
-	sysLog, err := Dial(ver, network, raddr, l, opts...)
+	sysLog, err := newHandle(ver, network, raddr, l, opts...)
 	if err != nil {
-		if sysLog != nil {
-			_ = sysLog.Close()
-		}
 		return nil, err
 	}
+	key := id + "#" + sysLog.identity()
+	w, ok := uswManager[key]
+	// client 已经存在
+	if ok {
+		w.Reference()
+		return w, nil
+	}
+
+	base := useBufferDir(sysLog)
+	// init 失败时已经释放资源
+	if err = sysLog.init(); err != nil {
+		return nil, err
+	}
+	adoptBufferDirs(sysLog, base)
 
-	w = &UniqueSyslogWriter{sysLog, id, atomic.NewInt32(1)}
-	uswManager[id] = w
+	w = &UniqueSyslogWriter{SyslogHandleWriter: sysLog, id: id, key: key, count: atomic.NewInt32(1)}
+	uswManager[key] = w
 	return w, nil
 }
 
+// useBufferDir 本地缓存目录已经被其他 writer 使用时改为 "目录.n"，返回原来的目录，调用方需要持有 uswManagerLock
+func useBufferDir(handle SyslogHandleWriter) string {
+	s := handle.Settings()
+	s.resolve(handle.defaultBufferDir())
+	dir := s.BufferDir
+	for i := 1; bufferDirInUse(dir); i++ {
+		dir = s.BufferDir + "." + strconv.Itoa(i)
+	}
+	handle.settingsRef().BufferDir = dir
+	return s.BufferDir
+}
+
+// adoptBufferDirs 导入 base 以及 "base.n" 中没有 writer 使用的缓存数据，
+// 这些数据是之前同时存在的 writer 关闭时未发送完的数据，调用方需要持有 uswManagerLock
+func adoptBufferDirs(handle SyslogHandleWriter, base string) {
+	dirs, _ := filepath.Glob(base + ".*")
+	for _, dir := range append([]string{base}, dirs...) {
+		if dir != base {
+			if _, err := strconv.Atoi(strings.TrimPrefix(dir, base+".")); err != nil {
+				continue
+			}
+		}
+		if dir == handle.Settings().BufferDir || bufferDirInUse(dir) {
+			continue
+		}
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			continue
+		}
+		handle.adoptSpool(dir)
+	}
+}
+
+func bufferDirInUse(dir string) bool {
+	for _, w := range uswManager {
+		if w.Settings().BufferDir == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// identity 根据生效的选项生成标识，tls 配置按内容比较，函数按地址比较
+func identity(s Settings, mf MessageFormat, cfg *tls.Config, fn dialFunc) string {
+	return fmt.Sprintf("%+v|%+v|%s|%p", s, mf, tlsIdentity(cfg), fn)
+}
+
+func tlsIdentity(cfg *tls.Config) string {
+	if cfg == nil {
+		return "-"
+	}
+	h := crc32.NewIEEE()
+	for _, cert := range cfg.Certificates {
+		for _, der := range cert.Certificate {
+			_, _ = h.Write(der)
+		}
+	}
+	if cfg.RootCAs != nil {
+		for _, subject := range cfg.RootCAs.Subjects() {
+			_, _ = h.Write(subject)
+		}
+	}
+	return fmt.Sprintf("%s/%t/%x", cfg.ServerName, cfg.InsecureSkipVerify, h.Sum32())
+}
+
 func Dial(ver int, network, raddr string, priority Priority, opts ...OptionFunc) (SyslogHandleWriter, error) {
+	w, err := newHandle(ver, network, raddr, priority, opts...)
+	if err != nil {
+		return nil, err
+	}
+	// init 失败时已经释放资源
+	if err = w.init(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// newHandle 创建 writer 并应用选项，不建立连接
+func newHandle(ver int, network, raddr string, priority Priority, opts ...OptionFunc) (SyslogHandleWriter, error) {
 	if priority < LOG_EMERG || priority > LOG_DEBUG {
 		return nil, errors.New("log/syslog: invalid priority")
 	}
 
-	if network != "tcp" {
-		return nil, errors.New("syslog only support tcp")
+	if err := checkNetwork(network); err != nil {
+		return nil, err
 	}
+	opts = append([]OptionFunc{withNetwork(network)}, opts...)
 
-	var w SyslogHandleWriter
-	var err error
 	switch ver {
 	case 1:
-		w, err = NewSyslogHandle(raddr, priority, opts...)
+		return newSyslogHandle(raddr, priority, opts...), nil
 	case 2:
-		w, err = NewSyslogHandleV2(raddr, priority, opts...)
+		return newSyslogHandleV2(raddr, priority, opts...), nil
 	}
-
-	return w, err
+	return nil, fmt.Errorf("log/syslog: unknown version %d", ver)
 }
 
 // NewTcpSyslog 创建 syslog writer
@@ -133,3 +300,19 @@ func NewTcpSyslog(raddr string, opts ...OptionFunc) (*UniqueSyslogWriter, error)
 func NewTcpSyslog2(raddr string, opts ...OptionFunc) (*UniqueSyslogWriter, error) {
 	return DialByLevel(2, "tcp", raddr, "INFO", opts...)
 }
+
+// NewUdpSyslog2 创建使用 udp 发送的 syslog writer，每条日志单独发送，超过 MaxDatagramSize 时截断
+func NewUdpSyslog2(raddr string, opts ...OptionFunc) (*UniqueSyslogWriter, error) {
+	return DialByLevel(2, NetworkUDP, raddr, "INFO", opts...)
+}
+
+// NewUnixSyslog2 创建使用 unix socket 发送的 syslog writer，比如 "/dev/log"，
+// 本机的 syslog 服务一般监听 unixgram，需要连接 unix stream socket 时使用 DialByLevel
+func NewUnixSyslog2(path string, opts ...OptionFunc) (*UniqueSyslogWriter, error) {
+	return DialByLevel(2, NetworkUnixgram, path, "INFO", opts...)
+}
+
+// NewTlsSyslog2 创建使用 tls 发送的 syslog writer，cfg 为空时使用系统根证书校验服务端
+func NewTlsSyslog2(raddr string, cfg *tls.Config, opts ...OptionFunc) (*UniqueSyslogWriter, error) {
+	return DialByLevel(2, NetworkTLS, raddr, "INFO", append([]OptionFunc{TLS(cfg)}, opts...)...)
+}
@@ -0,0 +1,137 @@
+package syslog
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// discardServer 启动一个丢弃所有数据的 tcp 服务
+func discardServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(ioutil.Discard, c)
+				_ = c.Close()
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// refusedAddr 返回一个没有监听的本地地址
+func refusedAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	return addr
+}
+
+func TestDialByLevelRefused(t *testing.T) {
+	addr := refusedAddr(t)
+	for _, ver := range []int{1, 2} {
+		done := make(chan error, 1)
+		go func(ver int) {
+			w, err := DialByLevel(ver, NetworkTCP, addr, "INFO", BufferDir(t.TempDir()), Timeout(100*time.Millisecond))
+			if w != nil {
+				_ = w.Close()
+			}
+			done <- err
+		}(ver)
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Errorf("v%d: dial refused addr returned nil error", ver)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("v%d: dial refused addr did not return", ver)
+		}
+	}
+}
+
+func TestDialByLevelReuse(t *testing.T) {
+	addr := discardServer(t)
+	t.Setenv("SYSLOG_BUFFER", t.TempDir()+"/buffer")
+	dirA, dirB := t.TempDir(), t.TempDir()
+
+	dial := func(opts ...OptionFunc) *UniqueSyslogWriter {
+		t.Helper()
+		// Timeout 同时是 Close 时等待后台退出的时间
+		w, err := DialByLevel(2, NetworkTCP, addr, "INFO", append(opts, Timeout(50*time.Millisecond))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = w.Close() })
+		return w
+	}
+
+	a := dial(BufferDir(dirA), CacheQuota(1<<20))
+	if b := dial(BufferDir(dirA), CacheQuota(1<<20)); b != a {
+		t.Error("same options should reuse the writer")
+	}
+	if b := dial(BufferDir(dirB), CacheQuota(1<<20)); b == a || b.Settings().BufferDir != dirB {
+		t.Error("different buffer dir should create a new writer")
+	}
+	if b := dial(BufferDir(dirA), CacheQuota(2<<20)); b == a || b.Settings().BufferDir != dirA+".1" {
+		t.Errorf("different options with the same buffer dir should use %s.1", dirA)
+	}
+
+	// 未设置 BufferDir 时同样使用 "默认目录.n"
+	c := dial(Facility(LOG_LOCAL1))
+	d := dial(Facility(LOG_LOCAL2))
+	if c == d {
+		t.Fatal("different facility should create a new writer")
+	}
+	if c.Settings().Facility != LOG_LOCAL1 || d.Settings().Facility != LOG_LOCAL2 {
+		t.Error("facility not applied")
+	}
+	if dc, dd := c.Settings().BufferDir, d.Settings().BufferDir; dd != dc+".1" {
+		t.Errorf("unexpected buffer dirs %s and %s", dc, dd)
+	}
+}
+
+func TestDialByLevelAdopt(t *testing.T) {
+	addr := discardServer(t)
+	dir := t.TempDir() + "/buffer"
+
+	// 之前同时存在的 writer 关闭时留下的缓存
+	orphan, err := openSpool(dir+".1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err = orphan.append([]byte("<14>orphan\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	orphan.close()
+
+	w, err := DialByLevel(2, NetworkTCP, addr, "INFO", BufferDir(dir), Timeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err = os.Stat(dir + ".1"); !os.IsNotExist(err) {
+		t.Errorf("orphan buffer dir should be removed, stat: %v", err)
+	}
+	if st := w.ReplayStatus(); st.NextSeq != 4 {
+		t.Errorf("adopted records: got next seq %d, want 4", st.NextSeq)
+	}
+}
@@ -7,3 +7,10 @@ func ResetDialTimeout(fn dialFunc) OptionFunc {
 		handle.SetDialTimeoutFn(fn)
 	}
 }
+
+// Facility 设置 syslog 的 facility，默认为 LOG_LOCAL0
+func Facility(facility Priority) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		handle.SetFacility(facility)
+	}
+}
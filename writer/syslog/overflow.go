@@ -0,0 +1,282 @@
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weitrue/log/utils"
+	"go.uber.org/atomic"
+)
+
+// OverflowPolicy 本地缓存达到 CacheQuota 时的处理策略
+type OverflowPolicy int
+
+const (
+	// OverflowDropNewest 默认策略，缓存接近写满（90%）时 Write 返回 ErrCacheNearFull，写满时丢弃新的数据
+	OverflowDropNewest OverflowPolicy = iota
+	// OverflowDropOldest 写满时删除最早的缓存文件（包括未发送的记录），Write 不受缓存大小限制
+	OverflowDropOldest
+	// OverflowDropByLevel 缓存接近写满时 Write 只接收 ERROR 及以上等级的日志，
+	// 写满时丢弃新数据中 ERROR 以下的日志，仍然超过限制时删除最早的缓存文件。
+	// 日志等级只能通过 WriteLevel 获取（core.NewCore 以及 writer.Lock、writer.NewMultiWriteSyncer 会转发），
+	// 直接调用 Write 或者经过不转发 WriteLevel 的包装（比如 zapcore.Lock、zapcore.AddSync）写入时，
+	// 使用创建 writer 时的固定 severity 判断，此时等同于按 writer 的等级全部保留或者全部丢弃
+	OverflowDropByLevel
+	// OverflowBlock 缓存接近写满时 Write 等待缓存减少，最多等待 BlockTimeout，超时返回 ErrCacheFull，写满时丢弃新的数据
+	OverflowBlock
+)
+
+// DefaultBlockTimeout OverflowBlock 时 Write 的默认最长等待时间
+const DefaultBlockTimeout = time.Second
+
+// ParseOverflowPolicy 根据名称获取策略：dropnewest、dropoldest、dropbylevel、block，不区分大小写
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	switch strings.ToLower(name) {
+	case "", "dropnewest":
+		return OverflowDropNewest, nil
+	case "dropoldest":
+		return OverflowDropOldest, nil
+	case "dropbylevel":
+		return OverflowDropByLevel, nil
+	case "block":
+		return OverflowBlock, nil
+	}
+	return OverflowDropNewest, fmt.Errorf("log/syslog: unknown overflow policy %q", name)
+}
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDropOldest:
+		return "dropoldest"
+	case OverflowDropByLevel:
+		return "dropbylevel"
+	case OverflowBlock:
+		return "block"
+	default:
+		return "dropnewest"
+	}
+}
+
+// Overflow 设置本地缓存达到 CacheQuota 时的处理策略，blockTimeout 只在 OverflowBlock 时生效，小于等于 0 时为 DefaultBlockTimeout
+func Overflow(policy OverflowPolicy, blockTimeout time.Duration) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		s := handle.settingsRef()
+		s.Overflow = policy
+		s.BlockTimeout = blockTimeout
+	}
+}
+
+// DropStats 因为本地缓存大小限制丢弃的数据，通过 DropStats 获取
+type DropStats struct {
+	// Rejected Write 时被拒绝的日志条数（返回 ErrCacheNearFull 或 ErrCacheFull）
+	Rejected uint64
+	// DroppedNewest 写满时未写入缓存的日志条数，DroppedByLevel 写满时丢弃的 ERROR 以下的日志条数
+	DroppedNewest  uint64
+	DroppedByLevel uint64
+	// DroppedOldest 写满时删除的未发送的缓存记录数，每条记录为一次发送失败的数据，可能包含多条日志
+	DroppedOldest uint64
+	// DroppedBytes 写满时丢弃的数据总大小，不包括 Rejected，删除的缓存文件按文件大小计算
+	DroppedBytes uint64
+}
+
+// overflow 按 OverflowPolicy 限制本地缓存的大小
+type overflow struct {
+	policy       OverflowPolicy
+	quota        int64
+	blockTimeout time.Duration
+	framing      Framing
+	spool        *spool
+
+	full           atomic.Bool // 是否处于写满状态，只在进入写满状态时输出错误
+	rejected       atomic.Uint64
+	droppedNewest  atomic.Uint64
+	droppedByLevel atomic.Uint64
+	droppedOldest  atomic.Uint64
+	droppedBytes   atomic.Uint64
+}
+
+func newOverflow(s *Settings, framing Framing, sp *spool) *overflow {
+	sp.limitSegmentSize(s.CacheQuota / 8)
+	return &overflow{
+		policy:       s.Overflow,
+		quota:        s.CacheQuota,
+		blockTimeout: s.BlockTimeout,
+		framing:      framing,
+		spool:        sp,
+	}
+}
+
+func (o *overflow) isNearFull() bool {
+	return o.spool.size.Load() > (o.quota * 90 / 100)
+}
+
+// admit Write 时检查缓存大小，缓存即将写满说明写速度过快，或者远程syslog故障
+func (o *overflow) admit(priority Priority) error {
+	if !o.isNearFull() {
+		return nil
+	}
+	switch o.policy {
+	case OverflowDropOldest:
+		return nil
+	case OverflowDropByLevel:
+		if priority&0x07 <= LOG_ERR {
+			return nil
+		}
+	case OverflowBlock:
+		return o.wait()
+	}
+	o.rejected.Inc()
+	return ErrCacheNearFull
+}
+
+// wait 等待缓存文件被删除（重新发送成功或者 dropOldest），超过 blockTimeout 时返回 ErrCacheFull
+func (o *overflow) wait() error {
+	timer := time.NewTimer(o.blockTimeout)
+	defer timer.Stop()
+	for {
+		// 先获取 channel 再检查大小，避免错过检查之后的通知
+		shrunk := o.spool.shrinkNotify()
+		if !o.isNearFull() {
+			return nil
+		}
+		select {
+		case <-shrunk:
+		case <-timer.C:
+			o.rejected.Inc()
+			return ErrCacheFull
+		}
+	}
+}
+
+// store 写入本地缓存，超过 CacheQuota 时按策略丢弃数据，开启压缩时按压缩后的大小计算
+func (o *overflow) store(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	rec, flags := o.spool.encode(data)
+	if o.spool.size.Load()+int64(len(rec)+_recordHeaderSize) > o.quota {
+		if rec, flags = o.shrink(data, rec, flags); len(rec) == 0 {
+			return
+		}
+	} else {
+		o.full.Store(false)
+	}
+	if err := o.spool.appendRecord(rec, flags); err != nil {
+		_, _ = utils.ErrorOutput(err.Error())
+	}
+}
+
+// shrink 缓存写满时按策略丢弃数据，data 为原始数据，rec 为 encode 后的数据，返回需要写入缓存的记录
+func (o *overflow) shrink(data, rec []byte, flags uint32) ([]byte, uint32) {
+	if o.full.CAS(false, true) {
+		_, _ = utils.ErrorOutput(fmt.Sprintf("%s, policy: %s", ErrCacheFull.Error(), o.policy))
+	}
+	switch o.policy {
+	case OverflowDropByLevel:
+		var kept []byte
+		var dropped, droppedBytes int
+		splitFrames(data, o.framing, func(frame []byte, priority Priority, ok bool) {
+			if !ok || priority&0x07 <= LOG_ERR {
+				kept = append(kept, frame...)
+				return
+			}
+			dropped++
+			droppedBytes += len(frame)
+		})
+		o.droppedByLevel.Add(uint64(dropped))
+		o.droppedBytes.Add(uint64(droppedBytes))
+		if len(kept) == 0 {
+			return nil, 0
+		}
+		data = kept
+		rec, flags = o.spool.encode(data)
+		if o.spool.size.Load()+int64(len(rec)+_recordHeaderSize) <= o.quota {
+			return rec, flags
+		}
+		fallthrough
+	case OverflowDropOldest:
+		need := int64(len(rec) + _recordHeaderSize)
+		if need <= o.quota {
+			records, size := o.spool.dropOldest(o.quota - need)
+			o.droppedOldest.Add(records)
+			o.droppedBytes.Add(uint64(size))
+			return rec, flags
+		}
+	}
+	n := 0
+	splitFrames(data, o.framing, func([]byte, Priority, bool) { n++ })
+	o.droppedNewest.Add(uint64(n))
+	o.droppedBytes.Add(uint64(len(data)))
+	return nil, 0
+}
+
+func (o *overflow) stats() DropStats {
+	return DropStats{
+		Rejected:       o.rejected.Load(),
+		DroppedNewest:  o.droppedNewest.Load(),
+		DroppedByLevel: o.droppedByLevel.Load(),
+		DroppedOldest:  o.droppedOldest.Load(),
+		DroppedBytes:   o.droppedBytes.Load(),
+	}
+}
+
+// splitFrames 将缓存数据拆分为单条消息，ok 为 false 表示无法解析 "<PRI>"。
+// FramingNonTransparent 时不以 "<PRI>" 开头的行属于上一条消息，无法拆分的数据作为一条消息
+func splitFrames(b []byte, framing Framing, fn func(frame []byte, priority Priority, ok bool)) {
+	if framing == FramingOctetCounting {
+		for len(b) > 0 {
+			i := bytes.IndexByte(b, ' ')
+			n := -1
+			if i > 0 {
+				if v, err := strconv.Atoi(string(b[:i])); err == nil {
+					n = v
+				}
+			}
+			if n < 0 || n > len(b)-i-1 {
+				fn(b, 0, false)
+				return
+			}
+			priority, ok := parsePriority(b[i+1 : i+1+n])
+			fn(b[:i+1+n], priority, ok)
+			b = b[i+1+n:]
+		}
+		return
+	}
+	for len(b) > 0 {
+		// 找到下一条以 "<PRI>" 开头的行
+		end := len(b)
+		for i := bytes.IndexByte(b, '\n'); i >= 0 && i+1 < len(b); {
+			if _, ok := parsePriority(b[i+1:]); ok {
+				end = i + 1
+				break
+			}
+			j := bytes.IndexByte(b[i+1:], '\n')
+			if j < 0 {
+				break
+			}
+			i += j + 1
+		}
+		priority, ok := parsePriority(b)
+		fn(b[:end], priority, ok)
+		b = b[end:]
+	}
+}
+
+// parsePriority 解析消息开头的 "<PRI>"
+func parsePriority(msg []byte) (Priority, bool) {
+	if len(msg) < 3 || msg[0] != '<' {
+		return 0, false
+	}
+	i := bytes.IndexByte(msg, '>')
+	if i < 2 || i > 4 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(msg[1:i]))
+	if err != nil || n < 0 || n > 191 {
+		return 0, false
+	}
+	return Priority(n), true
+}
@@ -0,0 +1,230 @@
+package syslog
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestOverflow 创建缓存总大小为 quota 的 overflow，并写入 records 条 100 字节的记录，
+// segment 大小限制为 200 字节，每个 segment 包含两条记录
+func newTestOverflow(t *testing.T, policy OverflowPolicy, quota int64, records int) *overflow {
+	t.Helper()
+	sp, err := openSpool(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sp.close)
+	sp.segSize = 200
+	for i := 0; i < records; i++ {
+		if err = sp.append(frame(LOG_INFO, 100)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &overflow{policy: policy, quota: quota, blockTimeout: 50 * time.Millisecond, spool: sp}
+}
+
+// frame 返回长度为 n、以 "<PRI>" 开头并以换行符结尾的消息
+func frame(severity Priority, n int) []byte {
+	b := []byte("<" + strconv.Itoa(int(LOG_LOCAL0|severity)) + ">")
+	b = append(b, bytes.Repeat([]byte("x"), n-len(b)-1)...)
+	return append(b, '\n')
+}
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want Priority
+		ok   bool
+	}{
+		{"<134>msg", 134, true},
+		{"<0>msg", 0, true},
+		{"<191>", 191, true},
+		{"<192>msg", 0, false},
+		{"<>msg", 0, false},
+		{"<1234>msg", 0, false},
+		{"<a>msg", 0, false},
+		{"134>msg", 0, false},
+		{"<1", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePriority([]byte(tt.msg))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parsePriority(%q) = %d, %v, want %d, %v", tt.msg, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSplitFrames(t *testing.T) {
+	type result struct {
+		frame    string
+		priority Priority
+		ok       bool
+	}
+	tests := []struct {
+		name    string
+		framing Framing
+		data    string
+		want    []result
+	}{
+		{
+			name: "lines",
+			data: "<134>a\n<131>b\n",
+			want: []result{{"<134>a\n", 134, true}, {"<131>b\n", 131, true}},
+		},
+		{
+			name: "continuation lines",
+			data: "<131>panic\n  stack 1\n  stack 2\n<134>c\n",
+			want: []result{{"<131>panic\n  stack 1\n  stack 2\n", 131, true}, {"<134>c\n", 134, true}},
+		},
+		{
+			name: "no priority",
+			data: "raw\n<134>a\n",
+			want: []result{{"raw\n", 0, false}, {"<134>a\n", 134, true}},
+		},
+		{
+			name:    "octet counting",
+			framing: FramingOctetCounting,
+			data:    "6 <134>a8 <131>b\nc",
+			want:    []result{{"6 <134>a", 134, true}, {"8 <131>b\nc", 131, true}},
+		},
+		{
+			name:    "octet counting truncated",
+			framing: FramingOctetCounting,
+			data:    "6 <134>a9 <131>b",
+			want:    []result{{"6 <134>a", 134, true}, {"9 <131>b", 0, false}},
+		},
+		{
+			name:    "octet counting bad length",
+			framing: FramingOctetCounting,
+			data:    "x <134>a",
+			want:    []result{{"x <134>a", 0, false}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []result
+			splitFrames([]byte(tt.data), tt.framing, func(frame []byte, priority Priority, ok bool) {
+				got = append(got, result{string(frame), priority, ok})
+			})
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d frames %+v, want %d", len(got), got, len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("frame %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestOverflowAdmit(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   OverflowPolicy
+		records  int
+		priority Priority
+		want     error
+	}{
+		{"not near full", OverflowDropNewest, 4, LOG_INFO, nil},
+		{"dropnewest", OverflowDropNewest, 8, LOG_INFO, ErrCacheNearFull},
+		{"dropoldest", OverflowDropOldest, 8, LOG_INFO, nil},
+		{"dropbylevel info", OverflowDropByLevel, 8, LOG_LOCAL0 | LOG_INFO, ErrCacheNearFull},
+		{"dropbylevel error", OverflowDropByLevel, 8, LOG_LOCAL0 | LOG_ERR, nil},
+		{"block timeout", OverflowBlock, 8, LOG_INFO, ErrCacheFull},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newTestOverflow(t, tt.policy, 1000, tt.records)
+			if err := o.admit(tt.priority); err != tt.want {
+				t.Errorf("admit() = %v, want %v", err, tt.want)
+			}
+			rejected := uint64(0)
+			if tt.want != nil {
+				rejected = 1
+			}
+			if got := o.stats().Rejected; got != rejected {
+				t.Errorf("Rejected = %d, want %d", got, rejected)
+			}
+		})
+	}
+}
+
+func TestOverflowBlockWakeup(t *testing.T) {
+	o := newTestOverflow(t, OverflowBlock, 1000, 8)
+	o.blockTimeout = 10 * time.Second
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		o.spool.dropOldest(500)
+	}()
+	start := time.Now()
+	if err := o.admit(LOG_INFO); err != nil {
+		t.Fatalf("admit() = %v, want nil", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("blocked write should wake up when the cache shrinks, waited %v", d)
+	}
+}
+
+func TestOverflowStore(t *testing.T) {
+	info, errFrame := frame(LOG_INFO, 100), frame(LOG_ERR, 20)
+	tests := []struct {
+		name   string
+		policy OverflowPolicy
+		data   []byte
+		want   DropStats
+		size   int64 // 写入之后缓存的总大小
+	}{
+		{
+			name:   "dropnewest",
+			policy: OverflowDropNewest,
+			data:   info,
+			want:   DropStats{DroppedNewest: 1, DroppedBytes: 100},
+			size:   928,
+		},
+		{
+			name:   "dropoldest",
+			policy: OverflowDropOldest,
+			data:   info,
+			want:   DropStats{DroppedOldest: 2, DroppedBytes: 232},
+			size:   812,
+		},
+		{
+			name:   "dropbylevel",
+			policy: OverflowDropByLevel,
+			data:   append(append([]byte{}, errFrame...), info...),
+			want:   DropStats{DroppedByLevel: 1, DroppedBytes: 100},
+			size:   964,
+		},
+		{
+			name:   "dropbylevel all info",
+			policy: OverflowDropByLevel,
+			data:   append(append([]byte{}, info...), info...),
+			want:   DropStats{DroppedByLevel: 2, DroppedBytes: 200},
+			size:   928,
+		},
+		{
+			name:   "block",
+			policy: OverflowBlock,
+			data:   info,
+			want:   DropStats{DroppedNewest: 1, DroppedBytes: 100},
+			size:   928,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 8 条 116 字节（包含记录头）的记录，共 928 字节，再写入 100 字节的数据会超过 1000
+			o := newTestOverflow(t, tt.policy, 1000, 8)
+			o.store(tt.data)
+			if got := o.stats(); got != tt.want {
+				t.Errorf("stats() = %+v, want %+v", got, tt.want)
+			}
+			if got := o.spool.size.Load(); got != tt.size {
+				t.Errorf("cache size = %d, want %d", got, tt.size)
+			}
+		})
+	}
+}
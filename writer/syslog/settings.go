@@ -0,0 +1,151 @@
+package syslog
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Settings syslog writer 的设置，通过 BufferDir、Timeout 等 OptionFunc 设置，
+// 未设置的字段使用对应的环境变量（如果有），环境变量也未设置时使用默认值
+type Settings struct {
+	// 以下字段只用于查看，通过 Dial、Facility、MaxDatagramSize 设置
+	Network         string
+	Raddr           string
+	Facility        Priority
+	MaxDatagramSize int
+
+	// BufferDir 发送失败时的本地缓存目录，环境变量 SYSLOG_BUFFER，V1 默认为 /data/syslog_buffer，V2 默认为 /data/syslog_buffer2
+	BufferDir string
+	// BatchSize V1 每次从缓存队列中取出发送的最大日志条数，环境变量 BATCH_SIZE，默认为 1000
+	BatchSize int
+	// Linger V1 每次扫描的最大等待时间，环境变量 Linger（秒），默认为 3 秒
+	Linger time.Duration
+	// Timeout 连接和发送的超时时间，同时也是空闲时扫描缓存的间隔，环境变量 SYSLOG_TIMEOUT（毫秒），默认为 3 秒
+	Timeout time.Duration
+	// ConnLifeTime 连接的最大生存时间，超过后关闭并重新连接，环境变量 SYSLOG_CONN_LIFE_TIME（秒），默认为 100 秒
+	ConnLifeTime time.Duration
+	// CommitBufferSize V2 发送缓冲区超过该大小时立即发送，环境变量 SYSLOG_COMMIT_BUFFER_SIZE，默认为 1MB
+	CommitBufferSize int64
+	// CacheQuota 本地缓存的总大小限制，环境变量 SYSLOG_CACHE_QUOTA，默认为 10GB
+	CacheQuota int64
+	// Overflow、BlockTimeout 通过 Overflow 设置，缓存达到 CacheQuota 时的处理策略，默认为 OverflowDropNewest
+	Overflow     OverflowPolicy
+	BlockTimeout time.Duration
+	// Gzip 通过 Gzip 设置，本地缓存是否使用 gzip 压缩，默认不压缩
+	Gzip bool
+
+	// Policy、Endpoints 通过 Endpoints 设置，Endpoints 不包括 Raddr
+	Policy    Policy
+	Endpoints []Endpoint
+	// HealthCheckInterval、MaxBackoff 通过 HealthCheck 设置，默认为 DefaultHealthCheckInterval 和 DefaultMaxBackoff
+	HealthCheckInterval time.Duration
+	MaxBackoff          time.Duration
+	// Breaker 通过 CircuitBreaker 设置
+	Breaker BreakerConfig
+}
+
+// BufferDir 设置发送失败时的本地缓存目录，未设置时使用环境变量 SYSLOG_BUFFER
+func BufferDir(dir string) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		handle.settingsRef().BufferDir = dir
+	}
+}
+
+// BatchSize 设置 V1 每次发送的最大日志条数，未设置时使用环境变量 BATCH_SIZE
+func BatchSize(size int) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		handle.settingsRef().BatchSize = size
+	}
+}
+
+// Linger 设置 V1 每次扫描的最大等待时间，未设置时使用环境变量 Linger
+func Linger(d time.Duration) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		handle.settingsRef().Linger = d
+	}
+}
+
+// Timeout 设置连接和发送的超时时间，未设置时使用环境变量 SYSLOG_TIMEOUT
+func Timeout(d time.Duration) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		handle.settingsRef().Timeout = d
+	}
+}
+
+// ConnLifeTime 设置连接的最大生存时间，未设置时使用环境变量 SYSLOG_CONN_LIFE_TIME
+func ConnLifeTime(d time.Duration) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		handle.settingsRef().ConnLifeTime = d
+	}
+}
+
+// CommitBufferSize 设置 V2 发送缓冲区的大小，未设置时使用环境变量 SYSLOG_COMMIT_BUFFER_SIZE
+func CommitBufferSize(size int64) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		handle.settingsRef().CommitBufferSize = size
+	}
+}
+
+// CacheQuota 设置本地缓存的总大小限制，未设置时使用环境变量 SYSLOG_CACHE_QUOTA
+func CacheQuota(quota int64) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		handle.settingsRef().CacheQuota = quota
+	}
+}
+
+// resolve 未设置的字段使用环境变量或默认值补全
+func (s *Settings) resolve(defaultBufferDir string) {
+	if s.BufferDir == "" {
+		s.BufferDir = defaultBufferDir
+		if dir, ok := os.LookupEnv("SYSLOG_BUFFER"); ok {
+			s.BufferDir = dir
+		}
+	}
+	s.BufferDir = strings.TrimSuffix(s.BufferDir, "/")
+	if s.BatchSize <= 0 {
+		s.BatchSize = int(envInt("BATCH_SIZE", 1000))
+	}
+	if s.Linger <= 0 {
+		s.Linger = time.Duration(envInt("Linger", 3)) * time.Second
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = time.Duration(envInt("SYSLOG_TIMEOUT", 3000)) * time.Millisecond
+	}
+	if s.ConnLifeTime <= 0 {
+		s.ConnLifeTime = time.Duration(envInt("SYSLOG_CONN_LIFE_TIME", 100)) * time.Second
+	}
+	if s.CommitBufferSize <= 0 {
+		s.CommitBufferSize = envInt("SYSLOG_COMMIT_BUFFER_SIZE", 1048576)
+	}
+	if s.CacheQuota <= 0 {
+		s.CacheQuota = envInt("SYSLOG_CACHE_QUOTA", 10*1024*1024*1024) // 10GB
+	}
+	if s.BlockTimeout <= 0 {
+		s.BlockTimeout = DefaultBlockTimeout
+	}
+	if s.HealthCheckInterval <= 0 {
+		s.HealthCheckInterval = DefaultHealthCheckInterval
+	}
+	if s.MaxBackoff <= 0 {
+		s.MaxBackoff = DefaultMaxBackoff
+	}
+	if s.MaxBackoff < s.HealthCheckInterval {
+		s.MaxBackoff = s.HealthCheckInterval
+	}
+	s.Breaker.resolve()
+}
+
+// envInt 获取整数类型的环境变量，未设置或格式错误时返回 def
+func envInt(key string, def int64) int64 {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return def
+	}
+	return n
+}
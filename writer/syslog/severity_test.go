@@ -0,0 +1,88 @@
+package syslog
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/weitrue/log/level"
+)
+
+func TestSeverity(t *testing.T) {
+	for lvl, want := range map[level.Level]Priority{
+		level.DebugLevel:    LOG_DEBUG,
+		level.InfoLevel:     LOG_INFO,
+		level.WarnLevel:     LOG_WARNING,
+		level.ErrorLevel:    LOG_ERR,
+		level.CriticalLevel: LOG_CRIT,
+		level.PanicLevel:    LOG_ALERT,
+		level.FatalLevel:    LOG_EMERG,
+		level.FixedLevel:    LOG_ALERT,
+		level.Level(100):    LOG_INFO,
+	} {
+		if got := Severity(lvl); got != want {
+			t.Errorf("Severity(%v) = %d, want %d", lvl, got, want)
+		}
+	}
+}
+
+func TestParseFacility(t *testing.T) {
+	if f, err := ParseFacility("Daemon"); err != nil || f != LOG_DAEMON {
+		t.Errorf("ParseFacility(Daemon) = %d, %v, want %d", f, err, LOG_DAEMON)
+	}
+	if f, err := ParseFacility("local7"); err != nil || f != LOG_LOCAL7 {
+		t.Errorf("ParseFacility(local7) = %d, %v, want %d", f, err, LOG_LOCAL7)
+	}
+	if _, err := ParseFacility("local8"); err == nil {
+		t.Error("unknown facility should fail")
+	}
+}
+
+func TestWriteLevel(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				s := bufio.NewScanner(conn)
+				for s.Scan() {
+					lines <- s.Text()
+				}
+			}()
+		}
+	}()
+
+	t.Setenv("SYSLOG_BUFFER", t.TempDir())
+	// facility 中的 severity 部分会被忽略
+	w, err := Dial(2, "tcp", ln.Addr().String(), LOG_WARNING, Facility(LOG_DAEMON|LOG_ERR))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	_, _ = w.WriteLevel(level.ErrorLevel, time.Now(), []byte("error\n"))
+	_, _ = w.WriteLevel(level.DebugLevel, time.Now(), []byte("debug\n"))
+	// Write 使用创建时指定的 severity
+	_, _ = w.Write([]byte("plain\n"))
+	_ = w.Sync()
+
+	for _, want := range []string{"<27>error", "<31>debug", "<28>plain"} {
+		select {
+		case got := <-lines:
+			if got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+	}
+}
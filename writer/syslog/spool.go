@@ -0,0 +1,627 @@
+package syslog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weitrue/log/utils"
+	"go.uber.org/atomic"
+)
+
+/*
+本地缓存（spool），发送失败的数据按顺序写入 segment 文件：
+  1、每次写入的数据为一条记录，记录头为 8 字节序号、4 字节长度、4 字节 crc32，序号从 1 开始递增，
+     长度字段的最高位表示数据使用 gzip 压缩，参考 Gzip；
+  2、segment 文件名为第一条记录的序号（20 位，不足补 0）加 ".seg"，超过 segSize（默认 _segmentSize）时创建新的文件；
+  3、checkpoint 文件保存已经发送成功的最后一条记录的序号，通过临时文件 + rename 更新；
+  4、重新发送时按序号从小到大发送，发送成功后更新 checkpoint，一个 segment 中的记录都发送成功后删除该文件，
+     发送失败时停止，下次从失败的记录继续；
+  5、进程异常退出时，最后一个 segment 末尾不完整的记录会在启动时被截断，
+     发送成功但 checkpoint 未更新的记录会重复发送一次。
+旧版本的缓存文件（V1 为缓存目录下的文件，V2 为缓存目录下子目录中的文件）在启动时按修改时间导入。
+*/
+
+const (
+	_segmentSize      = 16 << 20
+	_minSegmentSize   = 64 << 10
+	_segmentExt       = ".seg"
+	_checkpointFile   = "checkpoint"
+	_recordHeaderSize = 16
+)
+
+var (
+	errCorruptRecord = errors.New("log/syslog: corrupt spool record")
+	errReplayStopped = errors.New("log/syslog: replay stopped")
+)
+
+// ReplayStatus 本地缓存的状态，通过 ReplayStatus 获取
+type ReplayStatus struct {
+	// Dir 缓存目录
+	Dir string
+	// Segments 缓存文件数量，Bytes 缓存文件总大小（包括已经发送但所在文件未删除的记录）
+	Segments int
+	Bytes    int64
+	// Pending 等待重新发送的记录数，每条记录为一次发送失败的数据，可能包含多条日志
+	Pending uint64
+	// Checkpoint 已经发送成功的最后一条记录的序号，NextSeq 下一条写入的记录的序号
+	Checkpoint uint64
+	NextSeq    uint64
+	// Replaying 是否正在重新发送
+	Replaying bool
+	// Replayed 启动以来重新发送成功的记录数
+	Replayed uint64
+	// LastReplay 最近一次重新发送的时间，LastError 最近一次重新发送失败的原因
+	LastReplay time.Time
+	LastError  string
+}
+
+type segment struct {
+	first uint64 // 第一条记录的序号
+	path  string
+	size  int64
+}
+
+type spool struct {
+	mu         sync.Mutex
+	dir        string
+	segments   []*segment // 按序号排序，最后一个可能为 active
+	active     *os.File   // 正在写入的 segment
+	segSize    int64      // segment 文件的大小限制
+	gzip       bool       // 写入的记录是否使用 gzip 压缩
+	nextSeq    uint64
+	checkpoint uint64
+	// inflight replay 正在发送的记录序号，dropOldest 不将其计为丢弃
+	inflight uint64
+	size     atomic.Int64
+	// shrunk 删除 segment 或者关闭时关闭并替换为新的 channel，用于唤醒等待缓存减少的 Write
+	shrunk chan struct{}
+
+	replaying  atomic.Bool
+	replayed   atomic.Uint64
+	lastReplay time.Time
+	lastError  string
+}
+
+// openSpool 打开缓存目录，恢复序号和 checkpoint，并导入旧版本的缓存文件
+func openSpool(dir string, gzip bool) (*spool, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	s := &spool{dir: dir, nextSeq: 1, segSize: _segmentSize, gzip: gzip, shrunk: make(chan struct{})}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	s.importLegacy()
+	return s, nil
+}
+
+func (s *spool) load() error {
+	if data, err := ioutil.ReadFile(filepath.Join(s.dir, _checkpointFile)); err == nil {
+		s.checkpoint, _ = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	}
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, _segmentExt) {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(name, _segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		s.segments = append(s.segments, &segment{first: first, path: filepath.Join(s.dir, name), size: f.Size()})
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].first < s.segments[j].first })
+
+	s.nextSeq = s.checkpoint + 1
+	if n := len(s.segments); n > 0 {
+		// 最后一个 segment 可能在写入时异常退出，找到最后一条完整的记录并截断之后的数据
+		last := s.segments[n-1]
+		lastSeq, validSize, err := scanSegment(last.path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case validSize == 0:
+			// 没有完整的记录，删除后由 append 重新创建
+			if err = os.Remove(last.path); err != nil {
+				return err
+			}
+			s.segments = s.segments[:n-1]
+			lastSeq = last.first - 1
+		case validSize < last.size:
+			if err = os.Truncate(last.path, validSize); err != nil {
+				return err
+			}
+			last.size = validSize
+		}
+		if lastSeq+1 > s.nextSeq {
+			s.nextSeq = lastSeq + 1
+		}
+	}
+	for _, seg := range s.segments {
+		s.size.Add(seg.size)
+	}
+	return nil
+}
+
+// scanSegment 返回 segment 中最后一条完整记录的序号以及完整记录的总长度
+func scanSegment(path string) (lastSeq uint64, validSize int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		seq, _, data, err := readRecord(r)
+		if err != nil {
+			return lastSeq, validSize, nil
+		}
+		lastSeq = seq
+		validSize += int64(_recordHeaderSize + len(data))
+	}
+}
+
+// readRecord 读取一条记录，返回序号、长度字段中的标记以及文件中保存的数据
+func readRecord(r io.Reader) (uint64, uint32, []byte, error) {
+	var header [_recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, 0, nil, err
+	}
+	seq := binary.BigEndian.Uint64(header[0:8])
+	n := binary.BigEndian.Uint32(header[8:12])
+	sum := binary.BigEndian.Uint32(header[12:16])
+	flags := n & _recordGzip
+	n &^= _recordGzip
+	if n > _segmentSize*4 {
+		return 0, 0, nil, errCorruptRecord
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, 0, nil, err
+	}
+	if crc32.ChecksumIEEE(data) != sum {
+		return 0, 0, nil, errCorruptRecord
+	}
+	return seq, flags, data, nil
+}
+
+// decodeRecord 解压记录的数据
+func decodeRecord(flags uint32, data []byte) ([]byte, error) {
+	if flags&_recordGzip == 0 {
+		return data, nil
+	}
+	return gzipDecode(data)
+}
+
+// importLegacy 导入旧版本的缓存文件，按修改时间排序，导入后删除
+func (s *spool) importLegacy() {
+	type legacyFile struct {
+		path    string
+		modTime time.Time
+	}
+	var legacy []legacyFile
+	var dirs []string
+	collect := func(dir string, depth int) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		for _, f := range files {
+			path := filepath.Join(dir, f.Name())
+			switch {
+			case f.IsDir():
+				if depth == 0 {
+					dirs = append(dirs, path)
+				}
+			case depth == 0 && (strings.HasSuffix(f.Name(), _segmentExt) || strings.HasPrefix(f.Name(), _checkpointFile)):
+			default:
+				legacy = append(legacy, legacyFile{path, f.ModTime()})
+			}
+		}
+	}
+	collect(s.dir, 0)
+	for _, dir := range dirs {
+		collect(dir, 1)
+	}
+	sort.SliceStable(legacy, func(i, j int) bool {
+		if !legacy[i].modTime.Equal(legacy[j].modTime) {
+			return legacy[i].modTime.Before(legacy[j].modTime)
+		}
+		return legacy[i].path < legacy[j].path
+	})
+	for _, f := range legacy {
+		data, err := ioutil.ReadFile(f.path)
+		if err != nil {
+			_, _ = utils.ErrorOutput(err.Error())
+			continue
+		}
+		if len(data) > 0 {
+			if err = s.append(data); err != nil {
+				_, _ = utils.ErrorOutput(err.Error())
+				continue
+			}
+		}
+		_ = os.Remove(f.path)
+	}
+	for _, dir := range dirs {
+		// 只删除空目录
+		_ = os.Remove(dir)
+	}
+}
+
+// encode 开启压缩时压缩 data，返回写入文件的数据以及长度字段中的标记
+func (s *spool) encode(data []byte) ([]byte, uint32) {
+	if !s.gzip || len(data) == 0 {
+		return data, 0
+	}
+	if b, ok := gzipEncode(data); ok {
+		return b, _recordGzip
+	}
+	return data, 0
+}
+
+// append 压缩并写入一条记录，data 为空时不写入
+func (s *spool) append(data []byte) error {
+	return s.appendRecord(s.encode(data))
+}
+
+// appendRecord 写入一条记录，data 为 encode 后的数据，data 为空时不写入
+func (s *spool) appendRecord(data []byte, flags uint32) error {
+	if len(data) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active == nil || s.segments[len(s.segments)-1].size >= s.segSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	seg := s.segments[len(s.segments)-1]
+	buf := make([]byte, _recordHeaderSize, _recordHeaderSize+len(data))
+	binary.BigEndian.PutUint64(buf[0:8], s.nextSeq)
+	binary.BigEndian.PutUint32(buf[8:12], uint32(len(data))|flags)
+	binary.BigEndian.PutUint32(buf[12:16], crc32.ChecksumIEEE(data))
+	buf = append(buf, data...)
+	if _, err := s.active.Write(buf); err != nil {
+		// 去掉写入了一部分的记录，之后的记录写入新的 segment
+		_ = s.active.Truncate(seg.size)
+		s.seal()
+		return err
+	}
+	seg.size += int64(len(buf))
+	s.size.Add(int64(len(buf)))
+	s.nextSeq++
+	return nil
+}
+
+// rotate 关闭当前的 segment 并创建新的 segment，调用方需要持有 s.mu
+func (s *spool) rotate() error {
+	s.seal()
+	path := filepath.Join(s.dir, fmt.Sprintf("%020d%s", s.nextSeq, _segmentExt))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	s.active = f
+	s.segments = append(s.segments, &segment{first: s.nextSeq, path: path})
+	return nil
+}
+
+// seal 关闭当前的 segment，之后写入的记录使用新的 segment，调用方需要持有 s.mu
+func (s *spool) seal() {
+	if s.active == nil {
+		return
+	}
+	_ = s.active.Sync()
+	_ = s.active.Close()
+	s.active = nil
+}
+
+// replay 按序号从小到大重新发送记录，send 返回错误或者 stop 返回 true 时停止。
+// 同一时间只有一个 replay 在运行，正在运行时直接返回
+func (s *spool) replay(send func([]byte) error, stop func() bool) {
+	if !s.replaying.CAS(false, true) {
+		return
+	}
+	defer s.replaying.Store(false)
+
+	// 正在写入的 segment 只发送 replay 开始时已经写入的记录
+	s.mu.Lock()
+	segments := append([]*segment(nil), s.segments...)
+	sizes := make([]int64, len(segments))
+	for i, seg := range segments {
+		sizes[i] = seg.size
+	}
+	checkpoint := s.checkpoint
+	nextSeq := s.nextSeq
+	s.mu.Unlock()
+
+	var err error
+	for i, seg := range segments {
+		// 每个 segment 最后一条记录的序号
+		last := nextSeq - 1
+		if i+1 < len(segments) {
+			last = segments[i+1].first - 1
+		}
+		checkpoint, err = s.replaySegment(seg, sizes[i], checkpoint, send, stop)
+		if errors.Is(err, errCorruptRecord) {
+			// 数据损坏，跳过该 segment 剩余的记录
+			_, _ = utils.ErrorOutput(err.Error())
+			if checkpoint < last {
+				if err = s.saveCheckpoint(last); err != nil {
+					break
+				}
+				checkpoint = last
+			}
+			err = nil
+		}
+		if err != nil {
+			break
+		}
+		if checkpoint >= last {
+			s.removeSegment(seg, sizes[i])
+		}
+	}
+
+	s.mu.Lock()
+	s.lastReplay = time.Now()
+	switch {
+	case err == nil:
+		s.lastError = ""
+	case err != errReplayStopped:
+		s.lastError = err.Error()
+	}
+	s.mu.Unlock()
+}
+
+// replaySegment 发送 segment 前 size 字节中序号大于 checkpoint 的记录，返回新的 checkpoint
+func (s *spool) replaySegment(seg *segment, size int64, checkpoint uint64, send func([]byte) error, stop func() bool) (uint64, error) {
+	// segment 可能已经被 dropOldest 删除
+	s.mu.Lock()
+	if s.checkpoint > checkpoint {
+		checkpoint = s.checkpoint
+	}
+	s.mu.Unlock()
+	f, err := os.Open(seg.path)
+	if os.IsNotExist(err) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return checkpoint, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(io.LimitReader(f, size))
+	for !stop() {
+		seq, flags, data, err := readRecord(r)
+		if err == io.EOF {
+			return checkpoint, nil
+		}
+		if err != nil {
+			return checkpoint, fmt.Errorf("%w in %s: %s", errCorruptRecord, seg.path, err.Error())
+		}
+		if seq <= checkpoint {
+			continue
+		}
+		// 文件已经打开，dropOldest 删除 segment 之后仍然可以读取，
+		// 发送前检查记录是否已经被删除，避免重新发送已经计为丢弃的记录
+		if !s.claim(seq) {
+			s.mu.Lock()
+			checkpoint = s.checkpoint
+			s.mu.Unlock()
+			return checkpoint, nil
+		}
+		if data, err = decodeRecord(flags, data); err != nil {
+			// 无法解压，只跳过该记录
+			_, _ = utils.ErrorOutput(fmt.Sprintf("%s in %s: seq %d: %s", errCorruptRecord, seg.path, seq, err.Error()))
+		} else if err = send(data); err != nil {
+			s.release()
+			return checkpoint, err
+		} else {
+			s.replayed.Inc()
+		}
+		checkpoint = seq
+		if err = s.saveCheckpoint(seq); err != nil {
+			return checkpoint, err
+		}
+	}
+	return checkpoint, errReplayStopped
+}
+
+// claim 标记 seq 正在发送，seq 已经被 dropOldest 删除时返回 false
+func (s *spool) claim(seq uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if seq <= s.checkpoint {
+		return false
+	}
+	s.inflight = seq
+	return true
+}
+
+// release 清除正在发送的记录
+func (s *spool) release() {
+	s.mu.Lock()
+	s.inflight = 0
+	s.mu.Unlock()
+}
+
+// saveCheckpoint 清除正在发送的记录并更新 checkpoint，checkpoint 已经被 dropOldest 更新为更大的序号时不更新
+func (s *spool) saveCheckpoint(seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inflight = 0
+	if seq <= s.checkpoint {
+		return nil
+	}
+	return s.writeCheckpoint(seq)
+}
+
+// writeCheckpoint 通过临时文件 + rename 更新 checkpoint，调用方需要持有 s.mu
+func (s *spool) writeCheckpoint(seq uint64) error {
+	tmp := filepath.Join(s.dir, _checkpointFile+".tmp")
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatUint(seq, 10)+"\n"), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, _checkpointFile)); err != nil {
+		return err
+	}
+	s.checkpoint = seq
+	return nil
+}
+
+// removeSegment 删除已经全部发送的 segment，正在写入的 segment 在 replay 期间有新的记录写入时不删除
+func (s *spool) removeSegment(seg *segment, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, sg := range s.segments {
+		if sg != seg {
+			continue
+		}
+		if i == len(s.segments)-1 && s.active != nil {
+			if seg.size != size {
+				return
+			}
+			s.seal()
+		}
+		if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+			_, _ = utils.ErrorOutput(err.Error())
+			return
+		}
+		s.size.Sub(seg.size)
+		s.segments = append(s.segments[:i], s.segments[i+1:]...)
+		s.notifyShrunk()
+		return
+	}
+}
+
+// dropOldest 删除最早的 segment 直到缓存总大小不超过 limit，未发送的记录也会被删除，
+// checkpoint 更新为被删除的最后一条记录的序号。返回删除的未发送记录数（不包括 replay 正在发送的记录）以及文件总大小
+func (s *spool) dropOldest(limit int64) (records uint64, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.segments) > 0 && s.size.Load() > limit {
+		seg := s.segments[0]
+		last := s.nextSeq - 1
+		if len(s.segments) > 1 {
+			last = s.segments[1].first - 1
+		} else {
+			s.seal()
+		}
+		if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+			_, _ = utils.ErrorOutput(err.Error())
+			break
+		}
+		if last > s.checkpoint {
+			from := s.checkpoint
+			if seg.first > from+1 {
+				from = seg.first - 1
+			}
+			records += last - from
+			if s.inflight > from && s.inflight <= last {
+				records--
+			}
+			if err := s.writeCheckpoint(last); err != nil {
+				_, _ = utils.ErrorOutput(err.Error())
+			}
+		}
+		size += seg.size
+		s.size.Sub(seg.size)
+		s.segments = s.segments[1:]
+	}
+	if size > 0 {
+		s.notifyShrunk()
+	}
+	return records, size
+}
+
+// limitSegmentSize 缓存总大小限制较小时减小 segment 文件的大小，使 dropOldest 每次删除的数据不会太多
+func (s *spool) limitSegmentSize(size int64) {
+	if size < _minSegmentSize {
+		size = _minSegmentSize
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if size < s.segSize {
+		s.segSize = size
+	}
+}
+
+// pending 是否有等待重新发送的记录
+func (s *spool) pending() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nextSeq-1 > s.checkpoint
+}
+
+func (s *spool) status() ReplayStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ReplayStatus{
+		Dir:        s.dir,
+		Segments:   len(s.segments),
+		Bytes:      s.size.Load(),
+		Pending:    s.nextSeq - 1 - s.checkpoint,
+		Checkpoint: s.checkpoint,
+		NextSeq:    s.nextSeq,
+		Replaying:  s.replaying.Load(),
+		Replayed:   s.replayed.Load(),
+		LastReplay: s.lastReplay,
+		LastError:  s.lastError,
+	}
+}
+
+func (s *spool) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seal()
+	s.notifyShrunk()
+}
+
+// shrinkNotify 返回在缓存减少（或者关闭）时关闭的 channel
+func (s *spool) shrinkNotify() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shrunk
+}
+
+// notifyShrunk 唤醒等待缓存减少的 Write，调用方需要持有 s.mu
+func (s *spool) notifyShrunk() {
+	close(s.shrunk)
+	s.shrunk = make(chan struct{})
+}
+
+// adopt 将其他缓存目录中未发送的记录按顺序写入当前缓存，全部写入后删除该目录
+func (s *spool) adopt(dir string) {
+	other, err := openSpool(dir, s.gzip)
+	if err != nil {
+		_, _ = utils.ErrorOutput(err.Error())
+		return
+	}
+	other.replay(s.append, func() bool { return false })
+	other.close()
+	if other.pending() {
+		_, _ = utils.ErrorOutput(fmt.Sprintf("log/syslog: adopt buffer dir %s: %s", dir, other.status().LastError))
+		return
+	}
+	_ = os.Remove(filepath.Join(dir, _checkpointFile))
+	// 只删除空目录
+	_ = os.Remove(dir)
+}
@@ -0,0 +1,216 @@
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// record 返回第 i 条 100 字节的测试记录
+func record(i int) []byte {
+	return []byte(fmt.Sprintf("%-99d\n", i))
+}
+
+// newTestSpool 创建 segment 大小限制为 200 字节的缓存并写入 n 条记录，每个 segment 包含两条记录
+func newTestSpool(t *testing.T, dir string, gzip bool, n int) *spool {
+	t.Helper()
+	s, err := openSpool(dir, gzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.segSize = 200
+	for i := 1; i <= n; i++ {
+		if err = s.append(record(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// recorder 记录 replay 发送的数据，发送第 failAt 条时返回错误
+type recorder struct {
+	sent   []string
+	failAt int
+	hook   func(n int)
+}
+
+func (r *recorder) send(data []byte) error {
+	n := len(r.sent) + 1
+	if r.hook != nil {
+		r.hook(n)
+	}
+	if n == r.failAt {
+		return errors.New("send failed")
+	}
+	r.sent = append(r.sent, string(data))
+	return nil
+}
+
+func records(from, to int) []string {
+	var rs []string
+	for i := from; i <= to; i++ {
+		rs = append(rs, string(record(i)))
+	}
+	return rs
+}
+
+func TestSpoolReplay(t *testing.T) {
+	tests := []struct {
+		name     string
+		gzip     bool
+		records  int
+		failAt   int
+		sent     []string
+		pending  uint64
+		segments int
+	}{
+		{"all sent", false, 5, 0, records(1, 5), 0, 0},
+		{"gzip", true, 5, 0, records(1, 5), 0, 0},
+		{"fail in first segment", false, 5, 2, records(1, 1), 4, 3},
+		{"fail in last segment", false, 5, 5, records(1, 4), 1, 1},
+		{"empty", false, 0, 0, nil, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := newTestSpool(t, dir, tt.gzip, tt.records)
+			r := &recorder{failAt: tt.failAt}
+			s.replay(r.send, func() bool { return false })
+			s.close()
+			if !reflect.DeepEqual(r.sent, tt.sent) {
+				t.Errorf("sent %q, want %q", r.sent, tt.sent)
+			}
+			st := s.status()
+			if st.Pending != tt.pending || st.Segments != tt.segments {
+				t.Errorf("pending %d, segments %d, want %d, %d", st.Pending, st.Segments, tt.pending, tt.segments)
+			}
+			if tt.failAt > 0 && st.LastError == "" {
+				t.Error("LastError should be set after a failed replay")
+			}
+
+			// 重新打开后从 checkpoint 之后继续发送
+			reopened, err := openSpool(dir, tt.gzip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reopened.close()
+			r = &recorder{}
+			reopened.replay(r.send, func() bool { return false })
+			if want := records(len(tt.sent)+1, tt.records); !reflect.DeepEqual(r.sent, want) {
+				t.Errorf("sent %q after reopen, want %q", r.sent, want)
+			}
+		})
+	}
+}
+
+func TestSpoolDropOldest(t *testing.T) {
+	// 5 条记录共 580 字节，segment 分别为 232、232、116 字节
+	tests := []struct {
+		name       string
+		checkpoint uint64 // 删除前已经发送的记录
+		limit      int64
+		records    uint64
+		size       int64
+		pending    uint64
+	}{
+		{"under limit", 0, 580, 0, 0, 5},
+		{"one segment", 0, 500, 2, 232, 3},
+		{"two segments", 0, 200, 4, 464, 1},
+		{"all", 0, 0, 5, 580, 0},
+		{"partially sent", 1, 500, 1, 232, 3},
+		{"sent segment", 2, 500, 0, 232, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSpool(t, t.TempDir(), false, 5)
+			defer s.close()
+			if tt.checkpoint > 0 {
+				if err := s.saveCheckpoint(tt.checkpoint); err != nil {
+					t.Fatal(err)
+				}
+			}
+			records, size := s.dropOldest(tt.limit)
+			if records != tt.records || size != tt.size {
+				t.Errorf("dropOldest(%d) = %d, %d, want %d, %d", tt.limit, records, size, tt.records, tt.size)
+			}
+			if st := s.status(); st.Pending != tt.pending || st.Bytes != 580-tt.size {
+				t.Errorf("pending %d, bytes %d, want %d, %d", st.Pending, st.Bytes, tt.pending, 580-tt.size)
+			}
+		})
+	}
+}
+
+func TestSpoolDropOldestDuringReplay(t *testing.T) {
+	tests := []struct {
+		name    string
+		dropAt  int   // 发送第 dropAt 条记录时删除
+		limit   int64 // dropOldest 的参数
+		sent    []string
+		dropped uint64
+	}{
+		// 正在发送第 1 条记录时删除前两个 segment，第 1 条记录不计为丢弃，第 2 条记录不再发送
+		{"first record", 1, 232, append(records(1, 1), records(5, 6)...), 3},
+		// 正在发送第 2 条记录时删除第一个 segment
+		{"last record in segment", 2, 464, records(1, 6), 0},
+		{"second segment", 3, 232, append(records(1, 3), records(5, 6)...), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSpool(t, t.TempDir(), false, 6)
+			defer s.close()
+			var dropped uint64
+			r := &recorder{hook: func(n int) {
+				if n == tt.dropAt {
+					dropped, _ = s.dropOldest(tt.limit)
+				}
+			}}
+			s.replay(r.send, func() bool { return false })
+			if !reflect.DeepEqual(r.sent, tt.sent) {
+				t.Errorf("sent %q, want %q", r.sent, tt.sent)
+			}
+			if dropped != tt.dropped {
+				t.Errorf("dropped %d records, want %d", dropped, tt.dropped)
+			}
+			// 每条记录要么发送成功，要么计为丢弃
+			if got := uint64(len(r.sent)) + dropped; got != 6 {
+				t.Errorf("sent + dropped = %d, want 6", got)
+			}
+			if st := s.status(); st.Pending != 0 || st.Segments != 0 {
+				t.Errorf("pending %d, segments %d, want 0, 0", st.Pending, st.Segments)
+			}
+		})
+	}
+}
+
+func TestSpoolTruncatedRecord(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestSpool(t, dir, false, 3)
+	path := s.segments[len(s.segments)-1].path
+	s.close()
+
+	// 模拟进程异常退出时写入了一部分的记录
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte{0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	s, err = openSpool(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+	if err = s.append(record(4)); err != nil {
+		t.Fatal(err)
+	}
+	r := &recorder{}
+	s.replay(r.send, func() bool { return false })
+	if !reflect.DeepEqual(r.sent, records(1, 4)) {
+		t.Errorf("sent %q, want %q", r.sent, records(1, 4))
+	}
+}
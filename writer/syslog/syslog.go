@@ -1,19 +1,15 @@
 package syslog
 
 import (
+	"crypto/tls"
 	"errors"
-	"fmt"
 	"io"
-	"io/ioutil"
-	"math/rand"
 	"net"
-	"os"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
-	"unsafe"
 
+	"github.com/weitrue/log/level"
 	"github.com/weitrue/log/utils"
 )
 
@@ -76,11 +72,11 @@ const (
 
 关于数据流向的理解:
 	在init()方法里调用S.scanBuffer()方法，S.scanBuffer()方法会进入死循环扫描缓存数据
-	每次扫描当数据量大于S.batchSize(1000)或者扫描时间大于S.linger(3)时退出扫描，
+	每次扫描当数据量大于S.settings.BatchSize(1000)或者扫描时间大于S.settings.Linger(3秒)时退出扫描，
 		在每次扫描期间从S.buff里拿数据，如果拿成功则往buff对象里写数据，并且将计数器(count)+1
 	每次扫描结束如果计数器(count)>0则调用S.emit(buff.Bytes())方法把数据发送到远端
-	如果处于空闲状态(count < S.batchSize),则会去扫描文件,因为在某些发送失败的情况下会把数据写入文件，
-	在scanFile()方法中也会调用S.emit()方法把数据发送到远端
+	如果处于空闲状态(count < S.settings.BatchSize),则会去扫描文件,因为在某些发送失败的情况下会把数据写入文件，
+	在scanFile()方法中按顺序重新发送本地缓存中的数据，发送成功后才会从缓存中删除，参考 spool
 	在emit()方法中调用conn := S.getConn()获得连接，然后调用_, err := conn.conn.Write(b)向连接传输数据
 
 关于连接池的理解：
@@ -88,57 +84,68 @@ const (
 		在Dial()函数中调用了init()函数，该函数调用createConn()建立第一个连接并且放到连接池中
 		在getConn函数中会判断当连接池数量为0时再次创立连接
 	关闭连接的两种情况
-		在getConn函数中会判断当连接是否超时(调用isOld()，目前超时时间是100毫秒，由lifeTime确定),超时则关闭连接
+		在getConn函数中会判断当连接是否超时(调用isOld()，默认超时时间是100秒，由 Settings.ConnLifeTime 确定),超时则关闭连接
 		在Close()中关闭连接，此方法一般不会调用到
-	综上所述连接数100毫秒更新一个连接
+	综上所述连接默认100秒更新一次
 */
 type SysLogHandle struct {
-	priority Priority //等级
-	// priorityPreStr syslog 前缀
-	// TODO: 多等级支持
-	priorityPreStr string
-	raddr          string       //连接地址
-	deamon         *utils.Int32 //后台
+	facility Priority // 设施，默认为 LOG_LOCAL0
+	severity Priority // 通过 Write 写入的日志使用的等级，WriteLevel 根据日志等级计算
+	framer   *framer  // 消息格式，init 时根据 format 和 network 生成
+	format   MessageFormat
+
+	raddr  string       //连接地址
+	deamon *utils.Int32 //后台
+
+	network         string      // 传输方式
+	tlsConfig       *tls.Config // tls 配置
+	maxDatagramSize int         // 数据报传输时单条消息的最大长度
+	settings        Settings    // 缓存目录、超时时间等设置，init 时补全
+	spool           *spool      // 本地缓存
+	overflow        *overflow   // 本地缓存大小限制
 
 	connPool      *connPool // 连接池
 	buff          *queue    //缓存队列
 	dialTimeoutFn dialFunc
 	limit         chan int
 	waitGroup     sync.WaitGroup //并发控制
-	filePath      string         //缓存文件
-	batchSize     int            // 批发条数
-	linger        int64          //延时等待时间
-	timeout       time.Duration  //发送超时时间
-	lifeTime      int64          //连接最大生存时间,默认是100毫秒
 
 	largeBuff chan bool // 标记当前有大量缓存(高并发状态,使其会迅速消耗缓存,直接写入log文件)
 }
 
 func (S *SysLogHandle) Write(b []byte) (n int, err error) {
-	return S.WriteString(*(*string)(unsafe.Pointer(&b)))
+	return S.write(S.facility+S.severity, time.Now(), b)
 }
 
-func (S *SysLogHandle) WriteString(msg string) (n int, err error) {
-	buf := GetStrBuf()
-	buf.WriteString(S.priorityPreStr)
-	buf.WriteString(msg)
-	if !strings.HasSuffix(msg, "\n") {
-		buf.WriteByte('\n')
+// WriteLevel 写入日志，消息的 severity 根据日志等级计算，参考 Severity，RFC 5424 消息的 TIMESTAMP 使用日志时间 t
+func (S *SysLogHandle) WriteLevel(lvl level.Level, t time.Time, b []byte) (n int, err error) {
+	return S.write(S.facility+Severity(lvl), t, b)
+}
+
+func (S *SysLogHandle) write(priority Priority, t time.Time, b []byte) (n int, err error) {
+	// 本地缓存即将写满，按 OverflowPolicy 拒绝或者等待
+	if err = S.overflow.admit(priority); err != nil {
+		return -1, err
 	}
+	bs := GetByte()
+	*bs = S.framer.appendMessage(*bs, priority, t, b)
+	buf := GetStrBuf()
+	buf.Write(*bs)
+	PutByte(bs)
 
-	//if !strings.HasSuffix(msg, "\n") {
-	//	msg = msg + "\n"
-	//}
-	//message := S.priorityPreStr+msg
 	if !S.buff.Put(buf) {
 		return -1, errors.New("syslog writer buf is full")
 	}
 	// 如果缓存的日志文件已经较多,则不等待检查,直接写入文件
-	if len(S.largeBuff) == 0 && S.buff.Size() > S.batchSize {
+	if len(S.largeBuff) == 0 && S.buff.Size() > S.settings.BatchSize {
 		S.largeBuff <- true
 	}
 
-	return len(msg), nil
+	return len(b), nil
+}
+
+func (S *SysLogHandle) WriteString(msg string) (n int, err error) {
+	return S.Write([]byte(msg))
 }
 
 func (S *SysLogHandle) Close() error {
@@ -148,6 +155,7 @@ func (S *SysLogHandle) Close() error {
 	S.waitGroup.Wait() //等待所有发送结束
 	S.buff.Close()
 	S.connPool.Close()
+	S.spool.close()
 	return nil
 }
 func (S *SysLogHandle) Sync() error {
@@ -173,7 +181,7 @@ func (S *SysLogHandle) scanBuffer() {
 	//defer func() {
 	//	_ = S.Close()
 	//}()
-	timeout := time.Millisecond * S.timeout
+	timeout := S.settings.Timeout
 	scanBufferTimer := time.NewTimer(timeout)
 
 	for S.deamon.Load() > 0 {
@@ -182,8 +190,10 @@ func (S *SysLogHandle) scanBuffer() {
 		case <-scanBufferTimer.C:
 			S.writeData()
 			// 超时的时候检查文件，超时意味着目前日志量不多
-			// 在程序空闲的时候再去做这些事情
-			S.scanFile()
+			// 在程序空闲的时候再去做这些事情，熔断时不重新发送缓存文件
+			if S.connPool.breaker.State() == BreakerClosed {
+				S.scanFile()
+			}
 		case <-S.largeBuff:
 			// 循环写文件
 			// 持续到buff内容小于最大限制
@@ -201,8 +211,8 @@ func (S *SysLogHandle) writeData() {
 	if bs > 0 {
 		S.waitGroup.Add(1)
 		//缓存信息超过1w,判断为高并发状态,此时需要检查目录下文件是否大于1000,且只取缓存中1w条日志
-		if bs > S.batchSize {
-			bs = S.batchSize
+		if bs > S.settings.BatchSize {
+			bs = S.settings.BatchSize
 		}
 
 		// 建立缓冲区,接收wh.buff
@@ -227,7 +237,6 @@ func (S *SysLogHandle) writeData() {
 //emit调用情况如下
 //1.init方法调用scanBuffer()方法时会进入一个死循环，里面一直扫描buffer,如果扫描到buffer里有数据的时候就会调用
 //2.调用CLOSE方法关闭的时候会查看buffer里有没有数据，有的话会调用
-//3.scanBuffer()方法中当空闲时候去调用scanFile方法扫描文件，当文件里有东西时调用
 func (S *SysLogHandle) emit(b []byte) {
 	defer utils.CatchPanic()
 	defer S.waitGroup.Add(-1)
@@ -236,21 +245,12 @@ func (S *SysLogHandle) emit(b []byte) {
 		defer func() {
 			<-S.limit
 		}()
-		conn := S.connPool.get()
-		if conn == nil {
-			S.writeFile(b)
-			return
-		}
-		conn.setTimeout()
-		_, err := conn.conn.Write(b)
-
-		if err != nil {
-			_, _ = utils.ErrorOutput("syslog send fail and write file:" + err.Error())
-			conn.conn.Close()
+		if err := S.connPool.write(b); err != nil {
+			if err != errNoAvailableConn {
+				_, _ = utils.ErrorOutput("syslog send fail and write file:" + err.Error())
+			}
 			S.writeFile(b)
-			return
 		}
-		S.connPool.put(conn)
 
 	case <-time.After(time.Millisecond * 10):
 		_, _ = utils.ErrorOutput("flow control write file")
@@ -261,75 +261,115 @@ func (S *SysLogHandle) emit(b []byte) {
 
 func (S *SysLogHandle) init() error {
 	S.largeBuff = make(chan bool, 20)
-	filePath, ok := os.LookupEnv("SYSLOG_BUFFER")
-	if !ok {
-		filePath = "/data/syslog_buffer"
-	}
-
-	S.filePath = strings.TrimSuffix(filePath, "/")
-	batchSize, ok := os.LookupEnv("BATCH_SIZE")
-	if !ok {
-		batchSize = "1000"
-	}
-	S.batchSize, _ = strconv.Atoi(batchSize)
-
-	Linger, ok := os.LookupEnv("Linger")
-	if !ok {
-		Linger = "3"
-	}
-	S.linger, _ = strconv.ParseInt(Linger, 10, 64)
-
-	timeOut, ok := os.LookupEnv("SYSLOG_TIMEOUT")
-	if !ok {
-		timeOut = "3000"
-	}
-	timeout, _ := strconv.Atoi(timeOut)
-	S.timeout = time.Duration(timeout)
-
-	lifeTime, ok := os.LookupEnv("SYSLOG_CONN_LIFE_TIME")
-	if !ok {
-		lifeTime = "100"
-	}
-	S.lifeTime, _ = strconv.ParseInt(lifeTime, 10, 64)
-	err := os.MkdirAll(S.filePath, os.ModePerm)
+	S.settings.resolve(S.defaultBufferDir())
+	var err error
+	S.spool, err = openSpool(S.settings.BufferDir, S.settings.Gzip)
 	if err != nil {
 		return err
 	}
-	S.connPool = &connPool{
-		connQueue:     NewQueue(30, time.Millisecond*10),
-		dialTimeoutFn: S.dialTimeoutFn,
-		raddr:         S.raddr,
-		lifeTime:      S.lifeTime,
-		timeout:       S.timeout,
-	}
+	S.framer = newNetworkFramer(S.format, S.network)
+	S.overflow = newOverflow(&S.settings, S.framer.Framing, S.spool)
+	S.connPool = newConnPool(S.network, S.raddr, &S.settings,
+		networkDialFunc(S.network, S.tlsConfig, S.dialTimeoutFn), S.maxDatagramSize)
 
 	err = S.connPool.createConn()
 	if err != nil {
+		S.release()
 		return err
 	}
 	go S.scanBuffer()
 	return nil
 }
 
+// release init 失败时释放已经创建的资源，此时 scanBuffer 没有启动
+func (S *SysLogHandle) release() {
+	S.deamon.Store(0)
+	S.buff.Close()
+	S.connPool.Close()
+	S.spool.close()
+}
+
 func (S *SysLogHandle) SetDialTimeoutFn(fn dialFunc) {
 	S.dialTimeoutFn = fn
 }
 
+func (S *SysLogHandle) SetMessageFormat(mf MessageFormat) {
+	S.format = mf
+}
+
+func (S *SysLogHandle) SetNetwork(network string) {
+	S.network = network
+}
+
+func (S *SysLogHandle) SetTLSConfig(cfg *tls.Config) {
+	S.tlsConfig = cfg
+}
+
+func (S *SysLogHandle) SetMaxDatagramSize(size int) {
+	S.maxDatagramSize = size
+}
+
+// Settings 返回生效的设置，BatchSize 以外的 V2 设置不生效
+func (S *SysLogHandle) Settings() Settings {
+	s := S.settings
+	s.Network = S.network
+	s.Raddr = S.raddr
+	s.Facility = S.facility
+	s.MaxDatagramSize = S.maxDatagramSize
+	return s
+}
+
+func (S *SysLogHandle) settingsRef() *Settings {
+	return &S.settings
+}
+
+func (S *SysLogHandle) Stats() []EndpointStats {
+	return S.connPool.Stats()
+}
+
+func (S *SysLogHandle) BreakerState() BreakerState {
+	return S.connPool.breaker.State()
+}
+
+func (S *SysLogHandle) SetFacility(facility Priority) {
+	S.facility = facility &^ 0x07
+}
+
 func NewSyslogHandle(raddr string, priority Priority, opts ...OptionFunc) (*SysLogHandle, error) {
+	w := newSyslogHandle(raddr, priority, opts...)
+	if err := w.init(); err != nil {
+		// init 失败时已经释放资源
+		return nil, err
+	}
+	return w, nil
+}
+
+// newSyslogHandle 创建 writer 并应用选项，init 之后才能使用
+func newSyslogHandle(raddr string, priority Priority, opts ...OptionFunc) *SysLogHandle {
 	w := &SysLogHandle{
-		priority:       LOG_LOCAL0 + priority,
-		priorityPreStr: fmt.Sprintf("<%d>", LOG_LOCAL0+priority),
-		raddr:          raddr,
-		buff:           NewQueue(100000, time.Millisecond*10),
-		deamon:         utils.NewInt32(1),
-		limit:          make(chan int, 30),
-		dialTimeoutFn:  net.DialTimeout,
+		facility:      LOG_LOCAL0,
+		severity:      priority,
+		network:       NetworkTCP,
+		raddr:         raddr,
+		buff:          NewQueue(100000, time.Millisecond*10),
+		deamon:        utils.NewInt32(1),
+		limit:         make(chan int, 30),
+		dialTimeoutFn: net.DialTimeout,
+
+		maxDatagramSize: DefaultMaxDatagramSize,
 	}
 	for _, opt := range opts {
 		opt(w)
 	}
-	err := w.init()
-	return w, err
+	return w
+}
+
+func (S *SysLogHandle) identity() string {
+	return identity(S.Settings(), S.format, S.tlsConfig, S.dialTimeoutFn)
+}
+
+func (S *SysLogHandle) defaultBufferDir() string {
+	return "/data/syslog_buffer"
 }
 
 //写入文件的几种情况
@@ -337,39 +377,34 @@ func NewSyslogHandle(raddr string, priority Priority, opts ...OptionFunc) (*SysL
 //2.使用链接发送日志到远端失败时
 //3.使用链接发送日志到远端超时时
 func (S *SysLogHandle) writeFile(data []byte) {
-	//f := getRandomString(32)
-	fileName := fmt.Sprintf("%s/%d", S.filePath, time.Now().UnixNano())
-
-	err := ioutil.WriteFile(fileName, data, os.ModePerm)
-	if err != nil {
-		_, _ = utils.ErrorOutput(err.Error())
-	}
-	return
+	S.overflow.store(data)
 }
 
-//在空闲时候会扫描文件
+//在空闲时候按顺序重新发送本地缓存中的数据，发送成功后才会删除
 func (S *SysLogHandle) scanFile() {
-	filePath := S.filePath
-	files, err := ioutil.ReadDir(filePath)
-	if err != nil {
-		_, _ = utils.ErrorOutput(err.Error())
-		return
-	}
-	if len(files) == 0 {
+	if !S.spool.pending() {
 		return
 	}
-	n := rand.Intn(len(files))
-	name := files[n].Name()
-	fileName := filePath + "/" + name
-	content, err := ioutil.ReadFile(fileName)
-	if err != nil {
-		_, _ = utils.ErrorOutput(err.Error())
-	}
-	if len(content) > 0 {
-		S.waitGroup.Add(1)
-		go S.emit(content)
-	}
-	os.Remove(fileName)
+	S.waitGroup.Add(1)
+	go func() {
+		defer S.waitGroup.Add(-1)
+		defer utils.CatchPanic()
+		S.spool.replay(S.connPool.write, func() bool { return S.deamon.Load() == 0 })
+	}()
+}
+
+func (S *SysLogHandle) adoptSpool(dir string) {
+	S.spool.adopt(dir)
+}
+
+// ReplayStatus 返回本地缓存的状态
+func (S *SysLogHandle) ReplayStatus() ReplayStatus {
+	return S.spool.status()
+}
+
+// DropStats 返回因为本地缓存大小限制丢弃的数据
+func (S *SysLogHandle) DropStats() DropStats {
+	return S.overflow.stats()
 }
 
 //func getRandomString(length int) string {
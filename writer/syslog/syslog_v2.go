@@ -1,18 +1,12 @@
 package syslog
 
 import (
+	"crypto/tls"
 	"errors"
-	"fmt"
+	"github.com/weitrue/log/level"
 	"github.com/weitrue/log/utils"
-	"go.uber.org/atomic"
-	"io"
-	"io/ioutil"
 	"net"
-	"os"
-	"strconv"
-	"strings"
 	"sync"
-	"syscall"
 	"time"
 )
 
@@ -33,45 +27,54 @@ var (
   4、性能有所提升，主要是减少了内存拷贝和内存碎片，减少gc的压力。
 */
 type SysLogHandleV2 struct {
-	priority Priority //等级
-	// TODO: 多等级支持
-	priorityPreStr string
-	raddr          string       //连接地址
-	deamon         *utils.Int32 //后台
+	facility Priority     // 设施，默认为 LOG_LOCAL0
+	severity Priority     // 通过 Write 写入的日志使用的等级，WriteLevel 根据日志等级计算
+	framer   *framer      // 消息格式，init 时根据 format 和 network 生成
+	format   MessageFormat
+	raddr    string       //连接地址
+	deamon   *utils.Int32 //后台
+
+	network         string      // 传输方式
+	tlsConfig       *tls.Config // tls 配置
+	maxDatagramSize int         // 数据报传输时单条消息的最大长度
+	settings        Settings    // 缓存目录、超时时间等设置，init 时补全
+	spool           *spool      // 本地缓存
+	overflow        *overflow   // 本地缓存大小限制
 
 	connPool      *connPool   // 连接池
-	logChan       chan []byte //缓存队列
+	logChan       chan []byte //缓存队列，存放已经按 syslog 格式生成的消息
 	limit         chan int
 	dialTimeoutFn dialFunc
 	waitGroup     sync.WaitGroup //并发控制
-	cacheDir      string         //缓存文件
-	timeout       time.Duration  //发送超时时间
-	lifeTime      int64          //连接最大生存时间,默认是100毫秒
-
-	buffer           *[]byte // 发送缓冲区
-	commitBufferSize int64   // 缓冲区发送的大小
-	stopLoop         chan struct{}
-	cacheQuota       int64        // 本地缓存配额
-	cacheSize        atomic.Int64 // 当前本地缓存总大小
-}
 
-func (S *SysLogHandleV2) isNearFull() bool {
-	return S.cacheSize.Load() > (S.cacheQuota * 90 / 100)
+	buffer   *[]byte // 发送缓冲区
+	stopLoop chan struct{}
 }
 
 func (S *SysLogHandleV2) Write(b []byte) (n int, err error) {
+	return S.write(S.facility+S.severity, time.Now(), b)
+}
+
+// WriteLevel 写入日志，消息的 severity 根据日志等级计算，参考 Severity，RFC 5424 消息的 TIMESTAMP 使用日志时间 t
+func (S *SysLogHandleV2) WriteLevel(lvl level.Level, t time.Time, b []byte) (n int, err error) {
+	return S.write(S.facility+Severity(lvl), t, b)
+}
+
+func (S *SysLogHandleV2) write(priority Priority, t time.Time, b []byte) (n int, err error) {
 	if S.deamon.Load() == 0 {
 		return -1, ErrLoggerStopped
 	}
-	// 本地缓存即将写满，说明写速度过快，或者远程syslog故障，直接返回错误
-	if S.isNearFull() {
-		return -1, ErrCacheNearFull
+	// 本地缓存即将写满，说明写速度过快，或者远程syslog故障，按 OverflowPolicy 拒绝或者等待
+	if err = S.overflow.admit(priority); err != nil {
+		return -1, err
 	}
+	// b 在返回后可能会被调用方复用，这里生成消息的同时完成拷贝
+	msg := S.framer.appendMessage(make([]byte, 0, len(b)+64), priority, t, b)
 	select {
-	case S.logChan <- b:
+	case S.logChan <- msg:
 	default:
 		// 直接发送到远程，不一定能成功
-		S.emit(b)
+		S.emit(msg)
 		return -1, ErrLoggerBusyNow
 	}
 
@@ -87,6 +90,7 @@ func (S *SysLogHandleV2) Close() error {
 	//等待所有发送结束
 	S.waitGroup.Wait()
 	S.connPool.Close()
+	S.spool.close()
 
 	return nil
 }
@@ -110,7 +114,7 @@ func (S *SysLogHandleV2) Sync() error {
 func (S *SysLogHandleV2) loopWrite() {
 	defer close(S.stopLoop)
 	defer utils.CatchPanic()
-	timeout := time.Millisecond * S.timeout
+	timeout := S.settings.Timeout
 	scanBufferTimer := time.NewTimer(timeout)
 
 	for S.deamon.Load() > 0 {
@@ -120,7 +124,10 @@ func (S *SysLogHandleV2) loopWrite() {
 			// 超时的时候检查文件，超时意味着目前日志量不多
 			// 在程序空闲的时候再去做这些事情
 			S.flushBuffer()
-			S.scanCache(false)
+			// 熔断时不重新发送缓存文件，避免反复读写
+			if S.connPool.breaker.State() == BreakerClosed {
+				S.replayCache()
+			}
 
 		case msg := <-S.logChan:
 			S.writeBuffer(msg)
@@ -129,14 +136,10 @@ func (S *SysLogHandleV2) loopWrite() {
 	}
 }
 
-func (S *SysLogHandleV2) writeBuffer(msg []byte) {
-	*S.buffer = append(*S.buffer, S.priorityPreStr...)
-	*S.buffer = append(*S.buffer, msg...)
-	if msg[len(msg)-1] != '\n' {
-		*S.buffer = append(*S.buffer, '\n')
-	}
-
-	if len(*S.buffer) > int(S.commitBufferSize) {
+// writeBuffer 写入已经按 syslog 格式生成的数据，包括 logChan 中的消息以及本地缓存文件中的数据
+func (S *SysLogHandleV2) writeBuffer(data []byte) {
+	*S.buffer = append(*S.buffer, data...)
+	if len(*S.buffer) > int(S.settings.CommitBufferSize) {
 		S.flushBuffer()
 	}
 }
@@ -155,26 +158,15 @@ func (S *SysLogHandleV2) flushBuffer() {
 //emit调用情况如下
 //1.init方法调用scanBuffer()方法时会进入一个死循环，里面一直扫描buffer,如果扫描到buffer里有数据的时候就会调用
 //2.调用CLOSE方法关闭的时候会查看buffer里有没有数据，有的话会调用
-//3.scanBuffer()方法中当空闲时候去调用scanFile方法扫描文件，当文件里有东西时调用
 func (S *SysLogHandleV2) emit(b []byte) {
 	defer utils.CatchPanic()
 
-	conn := S.connPool.get()
-	if conn == nil {
-		S.writeFile(b)
-		return
-	}
-	conn.setTimeout()
-	_, err := conn.conn.Write(b)
-
-	if err != nil {
-		_, _ = utils.ErrorOutput("syslog send fail and write file:" + err.Error())
-		conn.conn.Close()
+	if err := S.connPool.write(b); err != nil {
+		if err != errNoAvailableConn {
+			_, _ = utils.ErrorOutput("syslog send fail and write file:" + err.Error())
+		}
 		S.writeFile(b)
-		return
 	}
-
-	S.connPool.put(conn)
 	return
 }
 
@@ -183,160 +175,148 @@ func (S *SysLogHandleV2) emit(b []byte) {
 //2.使用链接发送日志到远端失败时
 //3.使用链接发送日志到远端超时时
 func (S *SysLogHandleV2) writeFile(data []byte) {
-	if S.cacheSize.Load()+int64(len(data)) > S.cacheQuota {
-		_, _ = utils.ErrorOutput(ErrCacheFull.Error())
-	}
-	var err error
-	defer func() {
-		if err != nil {
-			_, _ = utils.ErrorOutput(err.Error())
-		} else {
-			S.cacheSize.Add(int64(len(data)))
-		}
-	}()
-	now := time.Now().Unix()
-	dirName := fmt.Sprintf("%s/%d", S.cacheDir, now%1000)
-	_, err = os.Open(dirName)
-	if err != nil {
-		os.Mkdir(dirName, os.ModePerm)
-	}
-	fileName := fmt.Sprintf("%s/%d", dirName, now)
-	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND|syscall.O_ASYNC, os.ModePerm)
-	if err != nil {
-		return
-	}
-	n, err := f.Write(data)
-	if err == nil && n < len(data) {
-		err = io.ErrShortWrite
-	}
-	if err1 := f.Close(); err == nil {
-		err = err1
-	}
-	return
+	S.overflow.store(data)
 }
 
-func (S *SysLogHandleV2) loopCacheDir(dirName string, isGetSize bool) {
-	subDirs, err := ioutil.ReadDir(dirName)
-	if err != nil {
-		_, _ = utils.ErrorOutput(err.Error())
-		return
-	}
-	if len(subDirs) == 0 {
-		if dirName != S.cacheDir {
-			os.Remove(dirName)
-		}
+//在空闲时候按顺序重新发送本地缓存中的数据，发送成功后才会删除
+func (S *SysLogHandleV2) replayCache() {
+	if !S.spool.pending() {
 		return
 	}
-	for _, f := range subDirs {
-		fileName := dirName + "/" + f.Name()
-		if f.IsDir() {
-			S.loopCacheDir(dirName+"/"+f.Name(), isGetSize)
-			continue
-		}
-		if isGetSize {
-			info, err := os.Stat(fileName)
-			if err != nil {
-				_, _ = utils.ErrorOutput(err.Error())
-				continue
-			}
-			S.cacheSize.Add(info.Size())
+	S.waitGroup.Add(1)
+	go func() {
+		defer S.waitGroup.Add(-1)
+		defer utils.CatchPanic()
+		S.spool.replay(S.connPool.write, func() bool { return S.deamon.Load() == 0 })
+	}()
+}
 
-		} else {
-			content, err := ioutil.ReadFile(fileName)
-			if err != nil {
-				_, _ = utils.ErrorOutput(err.Error())
-				continue
-			}
-			if len(content) > 0 {
-				S.writeBuffer(content)
-				S.cacheSize.Add(-int64(len(content)))
-			}
-			os.Remove(fileName)
-		}
+func (S *SysLogHandleV2) adoptSpool(dir string) {
+	S.spool.adopt(dir)
+}
 
-	}
+// ReplayStatus 返回本地缓存的状态
+func (S *SysLogHandleV2) ReplayStatus() ReplayStatus {
+	return S.spool.status()
+}
+
+// DropStats 返回因为本地缓存大小限制丢弃的数据
+func (S *SysLogHandleV2) DropStats() DropStats {
+	return S.overflow.stats()
 }
 
 func (S *SysLogHandleV2) SetDialTimeoutFn(fn dialFunc) {
 	S.dialTimeoutFn = fn
 }
 
-//在空闲时候会扫描文件
-func (S *SysLogHandleV2) scanCache(isGetSize bool) {
-	S.loopCacheDir(S.cacheDir, isGetSize)
+func (S *SysLogHandleV2) SetMessageFormat(mf MessageFormat) {
+	S.format = mf
 }
 
-func (S *SysLogHandleV2) init() error {
-	filePath, ok := os.LookupEnv("SYSLOG_BUFFER")
-	if !ok {
-		filePath = "/data/syslog_buffer2"
-	}
+func (S *SysLogHandleV2) SetNetwork(network string) {
+	S.network = network
+}
 
-	S.cacheDir = strings.TrimSuffix(filePath, "/")
+func (S *SysLogHandleV2) SetTLSConfig(cfg *tls.Config) {
+	S.tlsConfig = cfg
+}
 
-	timeOut, ok := os.LookupEnv("SYSLOG_TIMEOUT")
-	if !ok {
-		timeOut = "3000"
-	}
-	timeout, _ := strconv.Atoi(timeOut)
-	S.timeout = time.Duration(timeout)
+func (S *SysLogHandleV2) SetMaxDatagramSize(size int) {
+	S.maxDatagramSize = size
+}
 
-	lifeTime, ok := os.LookupEnv("SYSLOG_CONN_LIFE_TIME")
-	if !ok {
-		lifeTime = "100"
-	}
-	S.lifeTime, _ = strconv.ParseInt(lifeTime, 10, 64)
-	err := os.MkdirAll(S.cacheDir, os.ModePerm)
-	if err != nil {
-		return err
-	}
-	// scan and init current cache size
-	S.scanCache(true)
+// Settings 返回生效的设置，V2 不使用 BatchSize 和 Linger
+func (S *SysLogHandleV2) Settings() Settings {
+	s := S.settings
+	s.Network = S.network
+	s.Raddr = S.raddr
+	s.Facility = S.facility
+	s.MaxDatagramSize = S.maxDatagramSize
+	return s
+}
 
-	commitBufferSize, ok := os.LookupEnv("SYSLOG_COMMIT_BUFFER_SIZE")
-	if !ok {
-		commitBufferSize = "1048576"
-	}
-	S.commitBufferSize, _ = strconv.ParseInt(commitBufferSize, 10, 64)
+func (S *SysLogHandleV2) settingsRef() *Settings {
+	return &S.settings
+}
 
-	cacheQuota, ok := os.LookupEnv("SYSLOG_CACHE_QUOTA")
-	if !ok {
-		S.cacheQuota = 10 * 1024 * 1024 * 1024 // 10GB
-	} else {
-		S.cacheQuota, _ = strconv.ParseInt(cacheQuota, 10, 64)
-	}
+func (S *SysLogHandleV2) Stats() []EndpointStats {
+	return S.connPool.Stats()
+}
 
-	S.connPool = &connPool{
-		connQueue:     NewQueue(30, time.Millisecond*10),
-		dialTimeoutFn: S.dialTimeoutFn,
-		raddr:         S.raddr,
-		lifeTime:      S.lifeTime,
-		timeout:       S.timeout,
+func (S *SysLogHandleV2) BreakerState() BreakerState {
+	return S.connPool.breaker.State()
+}
+
+func (S *SysLogHandleV2) SetFacility(facility Priority) {
+	S.facility = facility &^ 0x07
+}
+
+func (S *SysLogHandleV2) init() error {
+	S.settings.resolve(S.defaultBufferDir())
+	var err error
+	S.spool, err = openSpool(S.settings.BufferDir, S.settings.Gzip)
+	if err != nil {
+		return err
 	}
 
+	S.framer = newNetworkFramer(S.format, S.network)
+	S.overflow = newOverflow(&S.settings, S.framer.Framing, S.spool)
+	S.connPool = newConnPool(S.network, S.raddr, &S.settings,
+		networkDialFunc(S.network, S.tlsConfig, S.dialTimeoutFn), S.maxDatagramSize)
+
 	err = S.connPool.createConn()
 	if err != nil {
+		S.release()
 		return err
 	}
 	go S.loopWrite()
 	return nil
 }
 
+// release init 失败时释放已经创建的资源，此时 loopWrite 没有启动，不能调用 Close
+func (S *SysLogHandleV2) release() {
+	S.deamon.Store(0)
+	close(S.stopLoop)
+	S.connPool.Close()
+	S.spool.close()
+	PutByte(S.buffer)
+}
+
 func NewSyslogHandleV2(raddr string, priority Priority, opts ...OptionFunc) (*SysLogHandleV2, error) {
+	w := newSyslogHandleV2(raddr, priority, opts...)
+	if err := w.init(); err != nil {
+		// init 失败时已经释放资源
+		return nil, err
+	}
+	return w, nil
+}
+
+// newSyslogHandleV2 创建 writer 并应用选项，init 之后才能使用
+func newSyslogHandleV2(raddr string, priority Priority, opts ...OptionFunc) *SysLogHandleV2 {
 	w := &SysLogHandleV2{
-		priority:       LOG_LOCAL0 + priority,
-		priorityPreStr: fmt.Sprintf("<%d>", LOG_LOCAL0+priority),
-		raddr:          raddr,
-		deamon:         utils.NewInt32(1),
-		limit:          make(chan int, 30),
-		logChan:        make(chan []byte, 100000),
-		buffer:         GetByte(),
-		dialTimeoutFn:  net.DialTimeout,
-		stopLoop:       make(chan struct{}),
+		facility:      LOG_LOCAL0,
+		severity:      priority,
+		network:       NetworkTCP,
+		raddr:         raddr,
+		deamon:        utils.NewInt32(1),
+		limit:         make(chan int, 30),
+		logChan:       make(chan []byte, 100000),
+		buffer:        GetByte(),
+		dialTimeoutFn: net.DialTimeout,
+		stopLoop:      make(chan struct{}),
+
+		maxDatagramSize: DefaultMaxDatagramSize,
 	}
 	for _, opt := range opts {
 		opt(w)
 	}
-	err := w.init()
-	return w, err
+	return w
+}
+
+func (S *SysLogHandleV2) identity() string {
+	return identity(S.Settings(), S.format, S.tlsConfig, S.dialTimeoutFn)
+}
+
+func (S *SysLogHandleV2) defaultBufferDir() string {
+	return "/data/syslog_buffer2"
 }
@@ -0,0 +1,165 @@
+package syslog
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"time"
+)
+
+// 支持的传输方式
+const (
+	NetworkTCP      = "tcp"
+	NetworkUDP      = "udp"
+	NetworkUnix     = "unix"
+	NetworkUnixgram = "unixgram"
+	// NetworkTLS tcp + tls，需要通过 TLS 设置证书，未设置时使用系统根证书校验服务端
+	NetworkTLS = "tls"
+)
+
+// DefaultMaxDatagramSize udp、unixgram 默认的单条消息最大长度，超过时截断
+const DefaultMaxDatagramSize = 8192
+
+var (
+	ErrUnsupportedNetwork = errors.New("log/syslog: unsupported network")
+	errInvalidFrame       = errors.New("log/syslog: invalid octet-counting frame")
+)
+
+// TLS 设置 tls 配置，设置后 tcp 连接也会使用 tls
+func TLS(cfg *tls.Config) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		handle.SetTLSConfig(cfg)
+	}
+}
+
+// MaxDatagramSize 设置 udp、unixgram 的单条消息最大长度，超过时截断，默认为 DefaultMaxDatagramSize
+func MaxDatagramSize(size int) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		handle.SetMaxDatagramSize(size)
+	}
+}
+
+// withNetwork 设置传输方式，由 Dial 设置
+func withNetwork(network string) OptionFunc {
+	return func(handle SyslogHandleWriter) {
+		handle.SetNetwork(network)
+	}
+}
+
+// NewTLSConfig 根据证书文件创建 tls 配置。
+// caFile 不为空时，只信任该 CA 签发的服务端证书；certFile、keyFile 不为空时，使用客户端证书进行双向认证。
+func NewTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("log/syslog: no certificate found in %s", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// checkNetwork 检查传输方式是否支持
+func checkNetwork(network string) error {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", NetworkUnix, NetworkUnixgram, NetworkTLS:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrUnsupportedNetwork, network)
+}
+
+// isDatagram 是否为数据报传输，每条消息单独发送
+func isDatagram(network string) bool {
+	switch network {
+	case "udp", "udp4", "udp6", NetworkUnixgram:
+		return true
+	}
+	return false
+}
+
+// newNetworkFramer 数据报传输时，缓存中的数据固定使用 octet-counting 分帧，发送时再拆分为单条消息
+func newNetworkFramer(mf MessageFormat, network string) *framer {
+	if isDatagram(network) {
+		mf.Framing = FramingOctetCounting
+	}
+	return newFramer(mf)
+}
+
+// networkDialFunc 使用 tls 传输，或者 tcp 传输并设置了 tls 配置时，在 fn 建立连接后进行 tls 握手
+func networkDialFunc(network string, cfg *tls.Config, fn dialFunc) dialFunc {
+	switch {
+	case network == NetworkTLS:
+		return tlsDialFunc(fn, cfg)
+	case cfg != nil && (network == "tcp" || network == "tcp4" || network == "tcp6"):
+		return tlsDialFunc(fn, cfg)
+	}
+	return fn
+}
+
+// tlsDialFunc 使用 fn 建立 tcp 连接后进行 tls 握手
+func tlsDialFunc(fn dialFunc, cfg *tls.Config) dialFunc {
+	return func(network, address string, timeout time.Duration) (net.Conn, error) {
+		conn, err := fn(network, address, timeout)
+		if err != nil {
+			return nil, err
+		}
+		c := cfg
+		if c == nil {
+			c = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		if c.ServerName == "" {
+			c = c.Clone()
+			if host, _, err := net.SplitHostPort(address); err == nil {
+				c.ServerName = host
+			}
+		}
+		tlsConn := tls.Client(conn, c)
+		_ = tlsConn.SetDeadline(time.Now().Add(timeout))
+		if err = tlsConn.Handshake(); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		_ = tlsConn.SetDeadline(time.Time{})
+		return tlsConn, nil
+	}
+}
+
+// writeDatagrams 数据报传输时，缓存中的数据使用 octet-counting 分帧，发送时拆分为单条消息，超过 maxSize 的消息会被截断
+func writeDatagrams(conn net.Conn, b []byte, maxSize int) error {
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, ' ')
+		if i <= 0 {
+			return errInvalidFrame
+		}
+		n, err := strconv.Atoi(string(b[:i]))
+		if err != nil || n < 0 || n > len(b)-i-1 {
+			return errInvalidFrame
+		}
+		msg := b[i+1 : i+1+n]
+		b = b[i+1+n:]
+		if maxSize > 0 && len(msg) > maxSize {
+			msg = msg[:maxSize]
+		}
+		if _, err = conn.Write(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package syslog
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// selfSignedTLS 创建 127.0.0.1 的自签名证书，返回服务端配置以及信任该证书的客户端配置
+func selfSignedTLS(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "syslog test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return server, client
+}
+
+// receiver 在本地监听，返回地址以及收到的消息
+type receiver func(t *testing.T) (addr string, msgs <-chan string, opts []OptionFunc)
+
+func datagramReceiver(network string) receiver {
+	return func(t *testing.T) (string, <-chan string, []OptionFunc) {
+		addr := "127.0.0.1:0"
+		if network == NetworkUnixgram {
+			// unix socket 路径长度有限制，不使用 t.TempDir
+			dir, err := ioutil.TempDir("", "syslog")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = os.RemoveAll(dir) })
+			addr = filepath.Join(dir, "s")
+		}
+		pc, err := net.ListenPacket(network, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = pc.Close() })
+		msgs := make(chan string, 16)
+		go func() {
+			buf := make([]byte, 65536)
+			for {
+				n, _, err := pc.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				msgs <- string(buf[:n])
+			}
+		}()
+		return pc.LocalAddr().String(), msgs, nil
+	}
+}
+
+// tlsReceiver 使用自签名证书监听，按 octet-counting 分帧读取消息
+func tlsReceiver(t *testing.T) (string, <-chan string, []OptionFunc) {
+	serverCfg, clientCfg := selfSignedTLS(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", serverCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	msgs := make(chan string, 16)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					length, err := r.ReadString(' ')
+					if err != nil {
+						return
+					}
+					n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+					if err != nil {
+						msgs <- "invalid length " + length
+						return
+					}
+					msg := make([]byte, n)
+					if _, err = io.ReadFull(r, msg); err != nil {
+						return
+					}
+					msgs <- string(msg)
+				}
+			}()
+		}
+	}()
+	opts := []OptionFunc{TLS(clientCfg), WithMessageFormat(MessageFormat{Format: FormatRFC5424, Framing: FramingOctetCounting})}
+	return ln.Addr().String(), msgs, opts
+}
+
+func TestTransports(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	tests := []struct {
+		name     string
+		network  string
+		receiver receiver
+		opts     []OptionFunc
+		msgs     []string
+		want     []string // 收到的消息需要以 want 结尾
+	}{
+		{"udp", NetworkUDP, datagramReceiver(NetworkUDP), nil, []string{"hello", "world"}, []string{"hello", "world"}},
+		{"unixgram", NetworkUnixgram, datagramReceiver(NetworkUnixgram), nil, []string{"hello"}, []string{"hello"}},
+		{"udp truncate", NetworkUDP, datagramReceiver(NetworkUDP), []OptionFunc{MaxDatagramSize(64)}, []string{long}, nil},
+		{"tls octet-counting", NetworkTLS, tlsReceiver, nil, []string{"hello\nworld", "second"}, []string{"hello\nworld", "second"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, msgs, opts := tt.receiver(t)
+			opts = append(opts, tt.opts...)
+			opts = append(opts, BufferDir(t.TempDir()), Timeout(200*time.Millisecond))
+			w, err := Dial(2, tt.network, addr, LOG_INFO, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+			for _, m := range tt.msgs {
+				if _, err = w.Write([]byte(m)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// 后台每隔 Timeout 发送一次缓冲区中的数据
+
+			for i := range tt.msgs {
+				var got string
+				select {
+				case got = <-msgs:
+				case <-time.After(2 * time.Second):
+					t.Fatalf("message %d not received", i)
+				}
+				if tt.want == nil {
+					// 截断为 MaxDatagramSize，默认 facility 为 local0
+					if len(got) != 64 || !strings.HasPrefix(got, "<134>") {
+						t.Errorf("got %d bytes %q, want a truncated message of 64 bytes", len(got), got)
+					}
+					continue
+				}
+				if !strings.HasPrefix(got, "<134>") || !strings.HasSuffix(got, tt.want[i]) {
+					t.Errorf("got %q, want a message ending with %q", got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// recordConn 记录每次 Write 的数据
+type recordConn struct {
+	net.Conn
+	writes []string
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	c.writes = append(c.writes, string(b))
+	return len(b), nil
+}
+
+func TestWriteDatagrams(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		maxSize int
+		want    []string
+		wantErr bool
+	}{
+		{"single", "5 hello", 0, []string{"hello"}, false},
+		{"multiple", "5 hello5 world", 0, []string{"hello", "world"}, false},
+		{"truncate", "11 hello world5 again", 5, []string{"hello", "again"}, false},
+		{"empty message", "0 5 hello", 0, []string{"", "hello"}, false},
+		{"missing length", "hello", 0, nil, true},
+		{"short frame", "10 hello", 0, nil, true},
+		{"valid before invalid", "5 hello-1 x", 0, []string{"hello"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &recordConn{}
+			err := writeDatagrams(c, []byte(tt.in), tt.maxSize)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(c.writes, "|") != strings.Join(tt.want, "|") || len(c.writes) != len(tt.want) {
+				t.Errorf("got %q, want %q", c.writes, tt.want)
+			}
+		})
+	}
+}
@@ -1,16 +1,106 @@
 package writer
 
 import (
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/weitrue/log/level"
     "go.uber.org/multierr"
     "go.uber.org/zap/zapcore"
-    "io"
 )
 
 type WriteSyncer = zapcore.WriteSyncer
 // sync 需要忽略错误
 // https://github.com/uber-go/zap/issues/328
 var AddSync = zapcore.AddSync
-var Lock = zapcore.Lock
+
+// LevelWriteSyncer 可以根据日志等级写入数据的 WriteSyncer，比如 syslog 根据日志等级设置每条消息的 severity。
+// core.NewCore 的 ws 实现了该接口时，每条日志都通过 WriteLevel 写入，t 为日志的生成时间，用于 syslog RFC 5424 消息的 TIMESTAMP 等。
+type LevelWriteSyncer interface {
+    WriteSyncer
+    WriteLevel(lvl level.Level, t time.Time, p []byte) (int, error)
+}
+
+// WriteLevel w 实现了 LevelWriteSyncer 时使用 WriteLevel 写入数据，否则使用 Write
+func WriteLevel(w io.Writer, lvl level.Level, t time.Time, p []byte) (int, error) {
+    if lw, ok := w.(LevelWriteSyncer); ok {
+        return lw.WriteLevel(lvl, t, p)
+    }
+    return w.Write(p)
+}
+
+// Lock wraps a WriteSyncer in a mutex to make it safe for concurrent use.
+// 返回的 WriteSyncer 保留了原 WriteSyncer 的描述信息，参考 Describe；
+// ws 实现了 LevelWriteSyncer 时，返回的对象同样实现 LevelWriteSyncer，WriteLevel 也在锁内调用
+func Lock(ws WriteSyncer) WriteSyncer {
+    locked := &lockedWriteSyncer{ws: ws, name: Describe(ws)}
+    if lws, ok := ws.(LevelWriteSyncer); ok {
+        return &lockedLevelWriteSyncer{lockedWriteSyncer: locked, lws: lws}
+    }
+    return locked
+}
+
+type lockedWriteSyncer struct {
+    sync.Mutex
+    ws   WriteSyncer
+    name string
+}
+
+func (s *lockedWriteSyncer) Write(p []byte) (int, error) {
+    s.Lock()
+    n, err := s.ws.Write(p)
+    s.Unlock()
+    return n, err
+}
+
+func (s *lockedWriteSyncer) Sync() error {
+    s.Lock()
+    err := s.ws.Sync()
+    s.Unlock()
+    return err
+}
+
+func (s *lockedWriteSyncer) String() string {
+    return s.name
+}
+
+type lockedLevelWriteSyncer struct {
+    *lockedWriteSyncer
+    lws LevelWriteSyncer
+}
+
+func (s *lockedLevelWriteSyncer) WriteLevel(lvl level.Level, t time.Time, p []byte) (int, error) {
+    s.Lock()
+    n, err := s.lws.WriteLevel(lvl, t, p)
+    s.Unlock()
+    return n, err
+}
+
+// Describe 返回 writer 的描述信息，用于展示 logger 的输出源。
+// 实现了 fmt.Stringer 的 writer 使用 String 的返回值，标准输出、标准错误输出返回 stdout、stderr，
+// 其他文件返回 file:文件名，否则返回 writer 的类型。
+func Describe(w io.Writer) string {
+    switch v := w.(type) {
+    case nil:
+        return ""
+    case fmt.Stringer:
+        return v.String()
+    case *os.File:
+        switch v {
+        case os.Stdout:
+            return "stdout"
+        case os.Stderr:
+            return "stderr"
+        }
+        return "file:" + v.Name()
+    default:
+        return fmt.Sprintf("%T", w)
+    }
+}
 
 
 
@@ -49,6 +139,30 @@ func (ws multiWriteSyncer) Write(p []byte) (n int, err error) {
     return len(p), nil
 }
 
+// WriteLevel 与 Write 相同，实现了 LevelWriteSyncer 的 writer 使用 WriteLevel 写入
+func (ws multiWriteSyncer) WriteLevel(lvl level.Level, t time.Time, p []byte) (n int, err error) {
+    for _, w := range ws {
+        n, err = WriteLevel(w, lvl, t, p)
+
+        if err != nil {
+            return
+        }
+        if n < len(p) {
+            err = io.ErrShortWrite
+            return
+        }
+    }
+    return len(p), nil
+}
+
+func (ws multiWriteSyncer) String() string {
+    names := make([]string, 0, len(ws))
+    for _, w := range ws {
+        names = append(names, Describe(w))
+    }
+    return strings.Join(names, ",")
+}
+
 func (ws multiWriteSyncer) Sync() error {
     var err error
     for _, w := range ws {
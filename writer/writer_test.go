@@ -0,0 +1,70 @@
+package writer
+
+import (
+    "bytes"
+    "testing"
+    "time"
+
+    "github.com/weitrue/log/level"
+)
+
+// levelRecorder 记录 WriteLevel 收到的日志等级和时间
+type levelRecorder struct {
+    bytes.Buffer
+    levels []level.Level
+    times  []time.Time
+}
+
+func (r *levelRecorder) WriteLevel(lvl level.Level, t time.Time, p []byte) (int, error) {
+    r.levels = append(r.levels, lvl)
+    r.times = append(r.times, t)
+    return r.Write(p)
+}
+
+func (r *levelRecorder) Sync() error {
+    return nil
+}
+
+func TestLockForwardsWriteLevel(t *testing.T) {
+    tests := []struct {
+        name string
+        wrap func(ws WriteSyncer) WriteSyncer
+    }{
+        {"Lock", Lock},
+        {"Lock twice", func(ws WriteSyncer) WriteSyncer { return Lock(Lock(ws)) }},
+        {"MultiWriteSyncer", func(ws WriteSyncer) WriteSyncer { return NewMultiWriteSyncer(Lock(ws)) }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            r := &levelRecorder{}
+            ts := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+            ws := tt.wrap(r)
+            if _, ok := ws.(LevelWriteSyncer); !ok {
+                t.Fatalf("%T should implement LevelWriteSyncer", ws)
+            }
+            if _, err := WriteLevel(ws, level.ErrorLevel, ts, []byte("a")); err != nil {
+                t.Fatal(err)
+            }
+            if len(r.levels) != 1 || r.levels[0] != level.ErrorLevel || !r.times[0].Equal(ts) {
+                t.Errorf("got levels %v times %v, want [%v] [%v]", r.levels, r.times, level.ErrorLevel, ts)
+            }
+            if r.String() != "a" {
+                t.Errorf("got %q, want %q", r.String(), "a")
+            }
+        })
+    }
+}
+
+func TestLockPlainWriter(t *testing.T) {
+    var buf bytes.Buffer
+    ws := Lock(AddSync(&buf))
+    if _, ok := ws.(LevelWriteSyncer); ok {
+        t.Errorf("%T should not implement LevelWriteSyncer", ws)
+    }
+    if _, err := WriteLevel(ws, level.ErrorLevel, time.Now(), []byte("a")); err != nil {
+        t.Fatal(err)
+    }
+    if buf.String() != "a" {
+        t.Errorf("got %q, want %q", buf.String(), "a")
+    }
+}